@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+const adminUsage = "usage: tasklist_backendGo admin [grant | revoke] <email>"
+
+// Handles the admin subcommand, which gives the first admin their role.
+// Later admins can be made through the API by an existing admin.
+func runAdmin(store db.Storage, args []string) error {
+	if len(args) != 2 {
+		return errors.New(adminUsage)
+	}
+
+	var role utils.UserRole
+	switch args[0] {
+	case "grant":
+		role = utils.UserAdmin
+	case "revoke":
+		role = utils.UserRegular
+	default:
+		return errors.New(adminUsage)
+	}
+
+	// The schema may be older than the role column
+	if err := store.InitDB(); err != nil {
+		return err
+	}
+
+	user, err := store.GetUserByEmail(args[1])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user with the email %s", args[1])
+	} else if err != nil {
+		return err
+	}
+
+	if err := store.SetUserRole(user.ID, role); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s) is now %s\n", user.Name, user.Email, role)
+	return nil
+}
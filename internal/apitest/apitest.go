@@ -0,0 +1,123 @@
+// Package apitest serves the whole HTTP API from an httptest server backed by
+// db.MemoryStore, so the endpoints can be tested end to end without a database.
+//
+//	h := apitest.New(t)
+//	user, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+//	status := h.Do("GET", "/tasks/"+user.ID.String(), token, nil, &tasks)
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/routes"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+type Harness struct {
+	t      testing.TB
+	Server *httptest.Server
+	Store  db.Storage
+}
+
+// Starts the API on a fresh in-memory store, the server is closed when the test ends
+func New(t testing.TB) *Harness {
+	t.Helper()
+
+	return NewWithStore(t, db.NewMemoryStore())
+}
+
+// Starts the API on the given store, e.g. a SQLite database in t.TempDir()
+func NewWithStore(t testing.TB, store db.Storage) *Harness {
+	t.Helper()
+
+	if err := store.InitDB(); err != nil {
+		t.Fatalf("initializing store: %v", err)
+	}
+
+	server := httptest.NewServer(routes.NewAPIServer("", store).Handler())
+	t.Cleanup(server.Close)
+
+	return &Harness{
+		t:      t,
+		Server: server,
+		Store:  store,
+	}
+}
+
+// Sends a request to the API and returns the response, body is encoded as
+// JSON unless it is nil. The token is sent in the Authorization header when set.
+func (h *Harness) Request(method, path, token string, body any) *http.Response {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+path, reader)
+	if err != nil {
+		h.t.Fatalf("creating request: %v", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "JWT "+token)
+	}
+
+	res, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+
+	return res
+}
+
+// Like Request, but decodes the JSON response into out (if not nil) and returns the status code
+func (h *Harness) Do(method, path, token string, body, out any) int {
+	h.t.Helper()
+
+	res := h.Request(method, path, token, body)
+	defer res.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			h.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+// Registers a new user through the API, logs them in and returns the user with their token
+func (h *Harness) RegisterAndLogin(username, email, password string) (utils.User, string) {
+	h.t.Helper()
+
+	register := utils.RegisterUserRequest{Username: username, Email: email, Password: password}
+	if status := h.Do("POST", "/register", "", register, nil); status != http.StatusOK {
+		h.t.Fatalf("register %s: status %d", email, status)
+	}
+
+	var login utils.LoginResponse
+	if status := h.Do("POST", "/login", "", utils.LoginRequest{Email: email, Password: password}, &login); status != http.StatusOK {
+		h.t.Fatalf("login %s: status %d", email, status)
+	}
+
+	user, err := h.Store.GetUserByEmail(email)
+	if err != nil {
+		h.t.Fatalf("looking up %s: %v", email, err)
+	}
+
+	return user, login.Token
+}
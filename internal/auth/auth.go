@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,23 +15,55 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/policy"
 	"github.com/sunikka/tasklist-backendGo/internal/utils"
 )
 
-type AuthHandler func(http.ResponseWriter, *http.Request, utils.User)
+type contextKey int
 
-var jwtKey = os.Getenv("JWT_KEY")
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+)
+
+// Returns the user MiddlewareJWT authenticated the request as
+func UserFromContext(ctx context.Context) (utils.User, bool) {
+	user, ok := ctx.Value(userContextKey).(utils.User)
+	return user, ok
+}
+
+// Returns the session of the access token the request was authenticated with
+func SessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(sessionContextKey).(uuid.UUID)
+	return sessionID, ok
+}
+
+const (
+	// Access tokens are short-lived, clients renew them with their refresh token
+	AccessTokenTTL = 15 * time.Minute
+	// A session ends this long after login unless it is revoked earlier
+	SessionTTL = 30 * 24 * time.Hour
+)
+
+// Read on use rather than at package init, main loads the .env file after
+// the packages have been initialized
+func jwtKey() []byte {
+	return []byte(os.Getenv("JWT_KEY"))
+}
 
-func GenerateToken(userID uuid.UUID) (string, error) {
+// Issues an access token for the user, sid ties it to a server-side session
+func GenerateToken(userID, sessionID uuid.UUID) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
 		&jwt.MapClaims{
 			"user_id": userID,
-			"exp":     time.Now().Add(time.Hour * 24).Unix(),
+			"sid":     sessionID,
+			"exp":     time.Now().Add(AccessTokenTTL).Unix(),
 		})
 
-	tokenString, err := token.SignedString([]byte(jwtKey))
+	tokenString, err := token.SignedString(jwtKey())
 	if err != nil {
 		return "", err
 	}
@@ -34,6 +71,79 @@ func GenerateToken(userID uuid.UUID) (string, error) {
 	return tokenString, nil
 }
 
+// Creates a random opaque refresh token, only its hash is stored in the database
+func NewRefreshToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Starts a new session for the user and returns its access and refresh tokens
+func StartSession(s db.Storage, user utils.User, userAgent string) (utils.TokenResponse, error) {
+	refreshToken, refreshHash, err := NewRefreshToken()
+	if err != nil {
+		return utils.TokenResponse{}, err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	session := utils.Session{
+		UserID:    user.ID,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().UTC().Add(SessionTTL).Truncate(time.Second),
+	}
+	if err := s.CreateSession(&session, refreshHash); err != nil {
+		return utils.TokenResponse{}, err
+	}
+
+	token, err := GenerateToken(user.ID, session.ID)
+	if err != nil {
+		return utils.TokenResponse{}, err
+	}
+
+	return utils.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Rotates the refresh token and issues a new access token for its session.
+// Reusing an already rotated refresh token revokes the whole session.
+func RefreshSession(s db.Storage, refreshToken string) (utils.TokenResponse, error) {
+	newToken, newHash, err := NewRefreshToken()
+	if err != nil {
+		return utils.TokenResponse{}, err
+	}
+
+	session, err := s.RotateRefreshToken(HashRefreshToken(refreshToken), newHash)
+	if err != nil {
+		return utils.TokenResponse{}, err
+	}
+
+	token, err := GenerateToken(session.UserID, session.ID)
+	if err != nil {
+		return utils.TokenResponse{}, err
+	}
+
+	return utils.TokenResponse{
+		Token:        token,
+		RefreshToken: newToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
 func validateJWT(tokenString string) (*jwt.Token, error) {
 	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 
@@ -41,46 +151,110 @@ func validateJWT(tokenString string) (*jwt.Token, error) {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		return []byte(jwtKey), nil
+		return jwtKey(), nil
 	})
 }
 
-// JWT auth middleware
+// Validates the access token of the request and returns the user and
+// session it was issued for. Tokens of revoked or expired sessions are rejected.
+func ParseRequestToken(r *http.Request, s db.Storage) (userID uuid.UUID, sessionID uuid.UUID, err error) {
+	tokenStr, err := GetTokenString(r)
+	if err != nil {
+		return userID, sessionID, err
+	}
+
+	token, err := validateJWT(tokenStr)
+	if err != nil {
+		return userID, sessionID, err
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	userIDStr, _ := claims["user_id"].(string)
+	sessionIDStr, _ := claims["sid"].(string)
+
+	userID, err = uuid.Parse(userIDStr)
+	if err != nil {
+		return userID, sessionID, errors.New("authentication failed")
+	}
+
+	sessionID, err = uuid.Parse(sessionIDStr)
+	if err != nil {
+		return userID, sessionID, errors.New("authentication failed")
+	}
+
+	session, err := s.GetSession(sessionID)
+	if err != nil || session.UserID != userID || !session.Active() {
+		return userID, sessionID, errors.New("authentication failed")
+	}
+
+	return userID, sessionID, nil
+}
+
+// JWT auth middleware, places the authenticated user into the request context.
+// On routes with a {user_id} path variable it must match the token's user.
 func MiddlewareJWT(handlerFunc http.HandlerFunc, s db.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tokenStr, err := GetTokenString(r)
+		tokenUserID, sessionID, err := ParseRequestToken(r, s)
 		if err != nil {
-			utils.ResponsePermDenied(w)
+			utils.ResponseUnauthorized(w)
 			return
 		}
 
-		token, err := validateJWT(tokenStr)
+		user, err := s.GetUserById(tokenUserID)
 		if err != nil {
-			utils.ResponsePermDenied(w)
+			utils.ResponseUnauthorized(w)
 			return
 		}
 
-		userID, err := utils.GetUserID(r)
-		if err != nil {
-			utils.ResponsePermDenied(w)
-			return
+		if _, hasUserID := mux.Vars(r)["user_id"]; hasUserID {
+			userID, err := utils.GetUserID(r)
+			if err != nil || userID != user.ID {
+				utils.ResponsePermDenied(w)
+				return
+			}
 		}
 
-		user, err := s.GetUserById(userID)
-		if err != nil {
-			utils.ResponsePermDenied(w)
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, sessionContextKey, sessionID)
+
+		handlerFunc(w, r.WithContext(ctx))
+
+	}
+}
+
+// Policy auth middleware, authenticates the request like MiddlewareJWT and
+// lets it through only when the policy grants the user the permission the
+// route requires for the request method
+func MiddlewarePolicy(handlerFunc http.HandlerFunc, s db.Storage, p *policy.Policy, need policy.Requirement) http.HandlerFunc {
+	return MiddlewareJWT(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := UserFromContext(r.Context())
+		if !ok {
+			utils.ResponseUnauthorized(w)
 			return
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-
-		if user.ID.String() != claims["user_id"].(string) {
-			utils.ResponsePermDenied(w)
+		permission := need.For(r.Method)
+		if !p.Allows(policy.UserSubject(user), permission) {
+			utils.WriteError(w, utils.Forbidden("permission denied: "+string(permission)+" is required"))
 			return
 		}
 
 		handlerFunc(w, r)
+	}, s)
+}
 
+// Accepts the access token from the access_token query parameter when the
+// request has no Authorization header. Browsers cannot set headers on
+// EventSource and WebSocket connections, other routes only take the header
+// so that tokens do not end up in URLs.
+func QueryToken(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "JWT "+token)
+		}
+
+		handlerFunc(w, r)
 	}
 }
 
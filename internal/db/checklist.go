@@ -0,0 +1,503 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Returned when a new checklist order does not list every item of the task exactly once
+var ErrChecklistMismatch = errors.New("item IDs do not match the checklist")
+
+// Column order expected by scanChecklistItem
+const checklistColumns = "item_id, task_id, title, done, position, created_at, updated_at"
+
+func scanChecklistItem(row rowScanner) (utils.ChecklistItem, error) {
+	var item utils.ChecklistItem
+
+	err := row.Scan(
+		&item.ID,
+		&item.TaskID,
+		&item.Title,
+		&item.Done,
+		&item.Position,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+
+	return item, err
+}
+
+// Checks that the task exists and belongs to the user, sql.ErrNoRows if not
+func checkTaskOwner(q dbtx, userIDBin, taskIDBin []byte) error {
+	var found int
+	return q.QueryRow("SELECT 1 FROM tasks WHERE task_id = ? AND user_id = ?", taskIDBin, userIDBin).Scan(&found)
+}
+
+func queryChecklist(q dbtx, taskIDBin []byte) ([]utils.ChecklistItem, error) {
+	rows, err := q.Query("SELECT "+checklistColumns+" FROM checklist_items WHERE task_id = ? ORDER BY position, item_id", taskIDBin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []utils.ChecklistItem{}
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (s *sqlStore) GetChecklist(userID, taskID uuid.UUID) ([]utils.ChecklistItem, error) {
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTaskOwner(s.db, userIDBin, taskIDBin); err != nil {
+		return nil, err
+	}
+
+	return queryChecklist(s.db, taskIDBin)
+}
+
+// Appends the item to the checklist of its task, the generated ID,
+// position and timestamps are set on item
+func (s *sqlStore) AddChecklistItem(userID uuid.UUID, item *utils.ChecklistItem) error {
+	taskIDBin, err := item.TaskID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	itemID := uuid.New()
+	itemIDBin, err := itemID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := checkTaskOwner(tx, userIDBin, taskIDBin); err != nil {
+		return err
+	}
+
+	var position int
+	err = tx.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM checklist_items WHERE task_id = ?", taskIDBin).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = tx.Exec("INSERT INTO checklist_items (item_id, task_id, title, done, position, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		itemIDBin, taskIDBin, item.Title, item.Done, position, createdAt, createdAt)
+	if err != nil {
+		return err
+	}
+
+	// The checklist is part of the task for syncing
+	if err := touchTasks(tx, version, userIDBin, "task_id = ?", taskIDBin); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	item.ID = itemID
+	item.Position = position
+	item.CreatedAt = createdAt
+	item.UpdatedAt = createdAt
+
+	return nil
+}
+
+func (s *sqlStore) GetChecklistItem(userID, taskID, id uuid.UUID) (utils.ChecklistItem, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.ChecklistItem{}, err
+	}
+
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return utils.ChecklistItem{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.ChecklistItem{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+checklistColumns+" FROM checklist_items WHERE item_id = ? AND task_id = ? AND task_id IN (SELECT task_id FROM tasks WHERE user_id = ?)",
+		idBin, taskIDBin, userIDBin)
+
+	return scanChecklistItem(row)
+}
+
+// Saves the title and done state of the item
+func (s *sqlStore) UpdateChecklistItem(userID, taskID, id uuid.UUID, item utils.ChecklistItem) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE checklist_items SET title = ?, done = ?, updated_at = ? WHERE item_id = ? AND task_id = ? AND task_id IN (SELECT task_id FROM tasks WHERE user_id = ?)",
+		item.Title, item.Done, now(), idBin, taskIDBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	if err := touchTasks(tx, version, userIDBin, "task_id = ?", taskIDBin); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteChecklistItem(userID, taskID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM checklist_items WHERE item_id = ? AND task_id = ? AND task_id IN (SELECT task_id FROM tasks WHERE user_id = ?)",
+		idBin, taskIDBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	if err := touchTasks(tx, version, userIDBin, "task_id = ?", taskIDBin); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Puts the checklist in the order of itemIDs, which must list every item of the task once
+func (s *sqlStore) ReorderChecklist(userID, taskID uuid.UUID, itemIDs []uuid.UUID) error {
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := checkTaskOwner(tx, userIDBin, taskIDBin); err != nil {
+		return err
+	}
+
+	items, err := queryChecklist(tx, taskIDBin)
+	if err != nil {
+		return err
+	}
+
+	if !sameItems(items, itemIDs) {
+		return ErrChecklistMismatch
+	}
+
+	updatedAt := now()
+	for position, id := range itemIDs {
+		idBin, err := id.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE checklist_items SET position = ?, updated_at = ? WHERE item_id = ?", position, updatedAt, idBin)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := touchTasks(tx, version, userIDBin, "task_id = ?", taskIDBin); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reports whether ids lists every item exactly once
+func sameItems(items []utils.ChecklistItem, ids []uuid.UUID) bool {
+	if len(items) != len(ids) {
+		return false
+	}
+
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+
+	for _, item := range items {
+		if !seen[item.ID] {
+			return false
+		}
+	}
+
+	return len(seen) == len(items)
+}
+
+// Fills in the checklists and progress of the tasks with a single query
+func loadTaskChecklists(q dbtx, tasks []utils.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	index := make(map[uuid.UUID]int, len(tasks))
+	checklists := make([][]utils.ChecklistItem, len(tasks))
+	args := make([]any, 0, len(tasks))
+	for i := range tasks {
+		index[tasks[i].ID] = i
+
+		idBin, err := tasks[i].ID.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		args = append(args, idBin)
+	}
+
+	rows, err := q.Query("SELECT "+checklistColumns+" FROM checklist_items WHERE task_id IN (?"+
+		strings.Repeat(", ?", len(args)-1)+") ORDER BY position, item_id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return err
+		}
+
+		i := index[item.TaskID]
+		checklists[i] = append(checklists[i], item)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		tasks[i].SetChecklist(checklists[i])
+	}
+
+	return nil
+}
+
+// Returns the checklist of a task in position order
+func (m *MemoryStore) checklistOf(taskID uuid.UUID) []utils.ChecklistItem {
+	items := []utils.ChecklistItem{}
+	for _, item := range m.checklistItems {
+		if item.TaskID == taskID {
+			items = append(items, item)
+		}
+	}
+
+	slices.SortFunc(items, func(a, b utils.ChecklistItem) int {
+		if a.Position != b.Position {
+			return a.Position - b.Position
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	return items
+}
+
+// Reports whether the task exists and belongs to the user
+func (m *MemoryStore) ownsTask(userID, taskID uuid.UUID) bool {
+	task, ok := m.tasks[taskID]
+	return ok && task.UserID == userID
+}
+
+func (m *MemoryStore) GetChecklist(userID, taskID uuid.UUID) ([]utils.ChecklistItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.ownsTask(userID, taskID) {
+		return nil, sql.ErrNoRows
+	}
+
+	return m.checklistOf(taskID), nil
+}
+
+func (m *MemoryStore) AddChecklistItem(userID uuid.UUID, item *utils.ChecklistItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.ownsTask(userID, item.TaskID) {
+		return sql.ErrNoRows
+	}
+
+	created := *item
+	created.ID = uuid.New()
+	created.Position = 0
+	for _, existing := range m.checklistOf(item.TaskID) {
+		created.Position = max(created.Position, existing.Position+1)
+	}
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+
+	m.checklistItems[created.ID] = created
+	m.touchTask(created.TaskID, m.nextSyncVersion(userID))
+
+	*item = created
+	return nil
+}
+
+func (m *MemoryStore) GetChecklistItem(userID, taskID, id uuid.UUID) (utils.ChecklistItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.checklistItems[id]
+	if !ok || item.TaskID != taskID || !m.ownsTask(userID, taskID) {
+		return utils.ChecklistItem{}, sql.ErrNoRows
+	}
+
+	return item, nil
+}
+
+func (m *MemoryStore) UpdateChecklistItem(userID, taskID, id uuid.UUID, item utils.ChecklistItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.checklistItems[id]
+	if !ok || existing.TaskID != taskID || !m.ownsTask(userID, taskID) {
+		return sql.ErrNoRows
+	}
+
+	existing.Title = item.Title
+	existing.Done = item.Done
+	existing.UpdatedAt = now()
+
+	m.checklistItems[id] = existing
+	m.touchTask(taskID, m.nextSyncVersion(userID))
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteChecklistItem(userID, taskID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.checklistItems[id]
+	if !ok || existing.TaskID != taskID || !m.ownsTask(userID, taskID) {
+		return sql.ErrNoRows
+	}
+
+	delete(m.checklistItems, id)
+	m.touchTask(taskID, m.nextSyncVersion(userID))
+
+	return nil
+}
+
+func (m *MemoryStore) ReorderChecklist(userID, taskID uuid.UUID, itemIDs []uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.ownsTask(userID, taskID) {
+		return sql.ErrNoRows
+	}
+
+	if !sameItems(m.checklistOf(taskID), itemIDs) {
+		return ErrChecklistMismatch
+	}
+
+	updatedAt := now()
+	for position, id := range itemIDs {
+		item := m.checklistItems[id]
+		item.Position = position
+		item.UpdatedAt = updatedAt
+		m.checklistItems[id] = item
+	}
+	m.touchTask(taskID, m.nextSyncVersion(userID))
+
+	return nil
+}
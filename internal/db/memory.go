@@ -0,0 +1,499 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Thread-safe Storage kept in process memory, used by tests and demos.
+// Lookups of missing rows return sql.ErrNoRows like the SQL stores do.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]utils.User
+	tasks map[uuid.UUID]utils.Task
+
+	projects map[uuid.UUID]utils.Project
+	tags     map[uuid.UUID]utils.Tag
+	// Tag IDs of each task
+	taskTags map[uuid.UUID][]uuid.UUID
+
+	checklistItems map[uuid.UUID]utils.ChecklistItem
+
+	reminders map[uuid.UUID]utils.Reminder
+	// Claimed or retried reminders are not due again before this time
+	reminderLocks map[uuid.UUID]time.Time
+
+	webhooks   map[uuid.UUID]utils.Webhook
+	deliveries map[uuid.UUID]utils.WebhookDelivery
+
+	shares map[uuid.UUID]utils.Share
+
+	workspaces  map[uuid.UUID]utils.Workspace
+	members     map[memoryMember]utils.WorkspaceMembership
+	invitations map[uuid.UUID]utils.WorkspaceInvitation
+
+	// Last sync version of each user
+	syncVersions map[uuid.UUID]int64
+	tombstones   map[uuid.UUID]memoryTombstone
+
+	sessions map[uuid.UUID]utils.Session
+	// Keyed by token hash
+	refreshTokens map[string]memoryRefreshToken
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users: make(map[uuid.UUID]utils.User),
+		tasks: make(map[uuid.UUID]utils.Task),
+
+		projects: make(map[uuid.UUID]utils.Project),
+		tags:     make(map[uuid.UUID]utils.Tag),
+		taskTags: make(map[uuid.UUID][]uuid.UUID),
+
+		checklistItems: make(map[uuid.UUID]utils.ChecklistItem),
+
+		reminders:     make(map[uuid.UUID]utils.Reminder),
+		reminderLocks: make(map[uuid.UUID]time.Time),
+
+		webhooks:   make(map[uuid.UUID]utils.Webhook),
+		deliveries: make(map[uuid.UUID]utils.WebhookDelivery),
+
+		shares: make(map[uuid.UUID]utils.Share),
+
+		workspaces:  make(map[uuid.UUID]utils.Workspace),
+		members:     make(map[memoryMember]utils.WorkspaceMembership),
+		invitations: make(map[uuid.UUID]utils.WorkspaceInvitation),
+
+		syncVersions: make(map[uuid.UUID]int64),
+		tombstones:   make(map[uuid.UUID]memoryTombstone),
+
+		sessions:      make(map[uuid.UUID]utils.Session),
+		refreshTokens: make(map[string]memoryRefreshToken),
+	}
+}
+
+func (m *MemoryStore) InitDB() error {
+	return nil
+}
+
+// Returns the tasks with their tags and checklists, ordered by creation time so that listings are stable
+func (m *MemoryStore) sortedTasks(match func(utils.Task) bool) []utils.Task {
+	var tasks []utils.Task
+	for _, task := range m.tasks {
+		if match(task) {
+			tasks = append(tasks, m.withDetails(task))
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID.String() < tasks[j].ID.String()
+	})
+
+	return tasks
+}
+
+func (m *MemoryStore) GetTasks() ([]utils.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sortedTasks(func(utils.Task) bool { return true }), nil
+}
+
+func (m *MemoryStore) GetTasksByUserID(userID uuid.UUID, filter utils.TaskFilter) (utils.TaskPage, error) {
+	return m.taskPage(func(task utils.Task) bool {
+		return task.UserID == userID && task.WorkspaceID == nil
+	}, filter)
+}
+
+// Lists one page of the tasks in scope like sqlStore.queryTaskPage
+func (m *MemoryStore) taskPage(scope func(utils.Task) bool, filter utils.TaskFilter) (utils.TaskPage, error) {
+	filter = normalizeTaskFilter(filter)
+
+	cursor, err := decodeTaskCursor(filter)
+	if err != nil {
+		return utils.TaskPage{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	search := strings.ToLower(filter.Search)
+	tasks := m.sortedTasks(func(task utils.Task) bool {
+		if !scope(task) {
+			return false
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, task.Status) {
+			return false
+		}
+		if filter.ProjectID != nil && (task.ProjectID == nil || *task.ProjectID != *filter.ProjectID) {
+			return false
+		}
+		if len(filter.Tags) > 0 && !m.hasTags(task, filter.Tags, filter.TagMatchAll) {
+			return false
+		}
+		if filter.DeadlineBefore != nil && !task.Deadline.Before(*filter.DeadlineBefore) {
+			return false
+		}
+		if filter.DeadlineAfter != nil && !task.Deadline.After(*filter.DeadlineAfter) {
+			return false
+		}
+		if search != "" && !strings.Contains(strings.ToLower(task.Title), search) && !strings.Contains(strings.ToLower(task.Description), search) {
+			return false
+		}
+		if cursor != nil && !cursor.before(task) {
+			return false
+		}
+		return true
+	})
+
+	slices.SortFunc(tasks, func(a, b utils.Task) int {
+		if filter.Descending {
+			return compareTasks(b, a, filter.Sort)
+		}
+		return compareTasks(a, b, filter.Sort)
+	})
+
+	if len(tasks) > filter.Limit+1 {
+		tasks = tasks[:filter.Limit+1]
+	}
+
+	return newTaskPage(tasks, filter), nil
+}
+
+func (m *MemoryStore) GetTaskById(userID, id uuid.UUID) (utils.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, ok := m.tasks[id]
+	if !ok || task.UserID != userID {
+		return utils.Task{}, sql.ErrNoRows
+	}
+
+	return m.withDetails(task), nil
+}
+
+func (m *MemoryStore) CreateTask(task *utils.Task) (*utils.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[task.UserID]; !ok {
+		return nil, errors.New("foreign key constraint failed: unknown user")
+	}
+	if task.ProjectID != nil {
+		if _, ok := m.projects[*task.ProjectID]; !ok {
+			return nil, errors.New("foreign key constraint failed: unknown project")
+		}
+	}
+	if task.WorkspaceID != nil {
+		if _, ok := m.workspaces[*task.WorkspaceID]; !ok {
+			return nil, errors.New("foreign key constraint failed: unknown workspace")
+		}
+	}
+
+	if task.SeriesID != nil {
+		for _, existing := range m.tasks {
+			if existing.SeriesID != nil && *existing.SeriesID == *task.SeriesID && existing.Occurrence == task.Occurrence {
+				return nil, ErrDuplicateOccurrence
+			}
+		}
+	}
+
+	created := *task
+	created.ID = uuid.New()
+	created.Deadline = created.Deadline.UTC()
+	if created.Status == "" {
+		created.Status = utils.StatusTodo
+	}
+	if created.Occurrence == 0 {
+		created.Occurrence = 1
+	}
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+	created.Version = m.nextSyncVersion(created.UserID)
+
+	m.tasks[created.ID] = created
+	m.setTaskTags(created)
+
+	created = m.withDetails(created)
+	return &created, nil
+}
+
+func (m *MemoryStore) DeleteTask(userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[id]
+	if !ok || task.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	version := m.nextSyncVersion(userID)
+	if task.WorkspaceID == nil {
+		m.tombstoneTask(task, version)
+	}
+	m.deleteTask(id)
+
+	return nil
+}
+
+func (m *MemoryStore) UpdateTask(userID, id uuid.UUID, task utils.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tasks[id]
+	if !ok || existing.UserID != userID {
+		return sql.ErrNoRows
+	}
+	if task.Version != existing.Version {
+		return ErrVersionConflict
+	}
+	if task.ProjectID != nil {
+		if _, ok := m.projects[*task.ProjectID]; !ok {
+			return errors.New("foreign key constraint failed: unknown project")
+		}
+	}
+
+	existing.Title = task.Title
+	existing.Description = task.Description
+	existing.Deadline = task.Deadline.UTC()
+	existing.AllDay = task.AllDay
+	existing.Status = task.Status
+	existing.CompletedAt = task.CompletedAt
+	existing.ProjectID = task.ProjectID
+	existing.Recurrence = task.Recurrence
+	existing.SeriesID = task.SeriesID
+	existing.Occurrence = task.Occurrence
+	existing.UpdatedAt = now()
+	existing.Version = m.nextSyncVersion(userID)
+
+	m.tasks[id] = existing
+	if task.Tags != nil {
+		existing.Tags = task.Tags
+		m.setTaskTags(existing)
+	}
+	m.rescheduleReminders(id, existing.Deadline)
+
+	return nil
+}
+
+func (m *MemoryStore) GetUsers() ([]utils.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []utils.User
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID.String() < users[j].ID.String()
+	})
+
+	return users, nil
+}
+
+// Reports whether the email belongs to a user other than exceptID
+func (m *MemoryStore) emailTaken(email string, exceptID uuid.UUID) bool {
+	for _, user := range m.users {
+		if user.Email == email && user.ID != exceptID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *MemoryStore) CreateUser(user *utils.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(user.Email, uuid.Nil) {
+		return ErrDuplicateEmail
+	}
+
+	created := *user
+	created.ID = uuid.New()
+	if created.TimeZone == "" {
+		created.TimeZone = "UTC"
+	}
+	if created.Role == "" {
+		created.Role = utils.UserRegular
+	}
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+	created.Version = 1
+
+	m.users[created.ID] = created
+
+	return nil
+}
+
+func (m *MemoryStore) GetUserById(id uuid.UUID) (utils.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return utils.User{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (m *MemoryStore) GetUserByEmail(email string) (utils.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return utils.User{}, sql.ErrNoRows
+}
+
+// Deletes the user along with their tasks, projects, tags, memberships and sessions, like ON DELETE CASCADE in the SQL schema.
+// Their tasks and projects in workspaces are handed over to another member first.
+func (m *MemoryStore) DeleteUser(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.users, id)
+	m.deleteUserMemberships(id)
+	for taskID, task := range m.tasks {
+		if task.UserID == id {
+			m.deleteTask(taskID)
+		}
+	}
+	for projectID, project := range m.projects {
+		if project.UserID == id {
+			delete(m.projects, projectID)
+		}
+	}
+	for tagID, tag := range m.tags {
+		if tag.UserID == id {
+			delete(m.tags, tagID)
+		}
+	}
+	// Handed over workspace tasks lose the tags of the user
+	for taskID, tagIDs := range m.taskTags {
+		m.taskTags[taskID] = slices.DeleteFunc(tagIDs, func(tagID uuid.UUID) bool {
+			_, ok := m.tags[tagID]
+			return !ok
+		})
+	}
+	for webhookID, webhook := range m.webhooks {
+		if webhook.UserID == id {
+			m.deleteWebhook(webhookID)
+		}
+	}
+	m.deleteUserShares(id)
+	delete(m.syncVersions, id)
+	for taskID, tombstone := range m.tombstones {
+		if tombstone.userID == id {
+			delete(m.tombstones, taskID)
+		}
+	}
+	for sessionID, session := range m.sessions {
+		if session.UserID == id {
+			delete(m.sessions, sessionID)
+		}
+	}
+	for hash, token := range m.refreshTokens {
+		if _, ok := m.sessions[token.sessionID]; !ok {
+			delete(m.refreshTokens, hash)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) UpdateUser(id uuid.UUID, user utils.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	if user.Version != existing.Version {
+		return ErrVersionConflict
+	}
+
+	if m.emailTaken(user.Email, id) {
+		return ErrDuplicateEmail
+	}
+
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.HashedPw = user.HashedPw
+	existing.TimeZone = user.TimeZone
+	existing.UpdatedAt = now()
+	existing.Version++
+
+	m.users[id] = existing
+
+	return nil
+}
+
+func (m *MemoryStore) SetUserRole(id uuid.UUID, role utils.UserRole) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	existing.Role = role
+	existing.UpdatedAt = now()
+	existing.Version++
+
+	m.users[id] = existing
+
+	return nil
+}
+
+// Deletes the task along with its tag associations and checklist,
+// like ON DELETE CASCADE in the SQL schema
+func (m *MemoryStore) deleteTask(id uuid.UUID) {
+	delete(m.tasks, id)
+	delete(m.taskTags, id)
+	for itemID, item := range m.checklistItems {
+		if item.TaskID == id {
+			delete(m.checklistItems, itemID)
+		}
+	}
+	for reminderID, reminder := range m.reminders {
+		if reminder.TaskID == id {
+			delete(m.reminders, reminderID)
+			delete(m.reminderLocks, reminderID)
+		}
+	}
+	for shareID, share := range m.shares {
+		if share.TaskID != nil && *share.TaskID == id {
+			delete(m.shares, shareID)
+		}
+	}
+}
+
+// Current time in the precision the SQL schema stores timestamps with
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
@@ -0,0 +1,360 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema changes live in migrations/<dialect>/<version>_<name>.up.sql and a
+// matching .down.sql file. Every change to the schema gets a new version,
+// applied migrations are recorded in the schema_migrations table.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Name of the MySQL advisory lock held while migrating, so that several
+// instances starting at the same time don't run the same migrations
+const migrationLockName = "tasklist_schema_migrations"
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Implemented by the stores that keep their schema in versioned migrations
+type Migrator interface {
+	MigrateUp() error
+	MigrateDown(steps int) error
+	MigrationStatus() ([]MigrationStatus, error)
+}
+
+// Statement runner shared by *sql.Conn and *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Reads the migrations of a dialect ordered by version
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file: %s", fileName)
+		}
+
+		versionStr, name, found := strings.Cut(strings.TrimSuffix(fileName, "."+direction+".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("migration file without a name: %s", fileName)
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", fileName)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Splits a migration file into statements, the MySQL driver only runs one
+// statement per Exec. Statements end with a semicolon outside of quotes,
+// -- starts a comment that runs to the end of the line. Backslashes escape
+// quotes in MySQL strings but not in SQLite ones.
+func splitStatements(dialect, content string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" && statement != ";" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	// The quote character of the string or identifier being read, 0 outside of one
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case quote != 0:
+			current.WriteByte(c)
+			if c == '\\' && quote != '`' && dialect == "mysql" && i+1 < len(content) {
+				// A backslash escape, the next character does not end the string
+				i++
+				current.WriteByte(content[i])
+			} else if c == quote {
+				// A doubled quote is read as closing and reopening the string
+				quote = 0
+			}
+
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteByte(c)
+
+		case c == '-' && strings.HasPrefix(content[i:], "--"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end - 1
+			}
+
+		case c == ';':
+			current.WriteByte(c)
+			flush()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// Runs fn while holding the migration lock. MySQL uses an advisory lock bound
+// to a single connection, SQLite an immediate transaction which also makes
+// the migrations atomic.
+func (s *sqlStore) withMigrationLock(fn func(q queryer) error) error {
+	ctx := context.Background()
+
+	if s.dialect == "sqlite" {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", migrationLockName).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return errors.New("timed out waiting for the schema migration lock")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	return fn(conn)
+}
+
+func createMigrationsTable(q queryer) error {
+	_, err := q.ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+func appliedMigrations(q queryer) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func runMigrationSQL(q queryer, dialect, content string) error {
+	for _, statement := range splitStatements(dialect, content) {
+		if _, err := q.ExecContext(context.Background(), statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Applies every pending migration in version order
+func (s *sqlStore) MigrateUp() error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func(q queryer) error {
+		if err := createMigrationsTable(q); err != nil {
+			return err
+		}
+
+		applied, err := appliedMigrations(q)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := runMigrationSQL(q, s.dialect, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err := q.ExecContext(context.Background(), "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, now())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Reverts the given number of most recently applied migrations
+func (s *sqlStore) MigrateDown(steps int) error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func(q queryer) error {
+		if err := createMigrationsTable(q); err != nil {
+			return err
+		}
+
+		applied, err := appliedMigrations(q)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if err := runMigrationSQL(q, s.dialect, migration.Down); err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err := q.ExecContext(context.Background(), "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				return err
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Lists every known migration and when it was applied, nil if it is pending
+func (s *sqlStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = s.withMigrationLock(func(q queryer) error {
+		if err := createMigrationsTable(q); err != nil {
+			return err
+		}
+
+		applied, err := appliedMigrations(q)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
@@ -0,0 +1,274 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		content string
+		want    []string
+	}{
+		{
+			name:    "one per line",
+			content: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:    []string{"CREATE TABLE a (id INT);", "CREATE TABLE b (id INT);"},
+		},
+		{
+			name:    "statement over several lines",
+			content: "CREATE TABLE a (\n\tid INT\n);\n",
+			want:    []string{"CREATE TABLE a (\n\tid INT\n);"},
+		},
+		{
+			name:    "several on one line",
+			content: "DROP TABLE a; DROP TABLE b;",
+			want:    []string{"DROP TABLE a;", "DROP TABLE b;"},
+		},
+		{
+			name:    "semicolon in a string",
+			content: "INSERT INTO a VALUES ('x; y');\nDROP TABLE b;",
+			want:    []string{"INSERT INTO a VALUES ('x; y');", "DROP TABLE b;"},
+		},
+		{
+			name:    "semicolon at the end of a line in a string",
+			content: "INSERT INTO a VALUES ('first;\nsecond');\n",
+			want:    []string{"INSERT INTO a VALUES ('first;\nsecond');"},
+		},
+		{
+			name:    "doubled quote in a string",
+			content: "UPDATE a SET s = 'it''s; fine';\nDROP TABLE b;",
+			want:    []string{"UPDATE a SET s = 'it''s; fine';", "DROP TABLE b;"},
+		},
+		{
+			name:    "semicolon in quoted identifiers",
+			content: "CREATE TABLE \"a;b\" (`c;d` INT);",
+			want:    []string{"CREATE TABLE \"a;b\" (`c;d` INT);"},
+		},
+		{
+			name:    "comments",
+			content: "-- Creates a; then b\nCREATE TABLE a (id INT); -- trailing; comment\n\n-- b\nCREATE TABLE b (s VARCHAR(8) DEFAULT '--');\n",
+			want:    []string{"CREATE TABLE a (id INT);", "CREATE TABLE b (s VARCHAR(8) DEFAULT '--');"},
+		},
+		{
+			name:    "last statement without a semicolon",
+			content: "DROP TABLE a;\nDROP TABLE b\n",
+			want:    []string{"DROP TABLE a;", "DROP TABLE b"},
+		},
+		{
+			name:    "empty statements",
+			content: ";\n\n;DROP TABLE a;;",
+			want:    []string{"DROP TABLE a;"},
+		},
+		{
+			name:    "backslash escape in MySQL",
+			dialect: "mysql",
+			content: "INSERT INTO a VALUES ('it\\'s; fine');\nDROP TABLE b;",
+			want:    []string{"INSERT INTO a VALUES ('it\\'s; fine');", "DROP TABLE b;"},
+		},
+		{
+			name:    "no backslash escape in SQLite",
+			dialect: "sqlite",
+			content: "INSERT INTO a VALUES ('C:\\');\nDROP TABLE b;",
+			want:    []string{"INSERT INTO a VALUES ('C:\\');", "DROP TABLE b;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect := tt.dialect
+			if dialect == "" {
+				dialect = "sqlite"
+			}
+
+			if got := splitStatements(dialect, tt.content); !slices.Equal(got, tt.want) {
+				t.Errorf("splitStatements(%q)\n got %q\nwant %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []string{"mysql", "sqlite"} {
+		migrations, err := loadMigrations(dialect)
+		if err != nil {
+			t.Fatalf("%s: %v", dialect, err)
+		}
+
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("%s: migration %d_%s, want version %d", dialect, migration.Version, migration.Name, i+1)
+			}
+		}
+	}
+
+	mysql, _ := loadMigrations("mysql")
+	sqlite, _ := loadMigrations("sqlite")
+	if len(mysql) != len(sqlite) {
+		t.Errorf("%d MySQL migrations and %d SQLite migrations", len(mysql), len(sqlite))
+	}
+}
+
+func newTestSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.db.Close() })
+
+	return store
+}
+
+// Lists the tables of the database besides the internal ones of SQLite
+func sqliteTables(t *testing.T, store *SQLiteStore) []string {
+	t.Helper()
+
+	rows, err := store.db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+
+	return tables
+}
+
+func appliedCount(t *testing.T, store *SQLiteStore) int {
+	t.Helper()
+
+	statuses, err := store.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := 0
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			applied++
+		}
+	}
+
+	return applied
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "tasklist.db"))
+
+	if err := store.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedCount(t, store); got != len(migrations) {
+		t.Fatalf("%d of %d migrations applied", got, len(migrations))
+	}
+	upTables := sqliteTables(t, store)
+	if !slices.Contains(upTables, "tasks") || !slices.Contains(upTables, "task_tombstones") || !slices.Contains(upTables, "workspaces") {
+		t.Fatalf("tables after migrating up %v", upTables)
+	}
+
+	// Applied migrations are not run again
+	if err := store.MigrateUp(); err != nil {
+		t.Fatalf("migrating up again: %v", err)
+	}
+
+	// One step back reverts only the latest migration
+	if err := store.MigrateDown(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedCount(t, store); got != len(migrations)-1 {
+		t.Fatalf("%d migrations applied after one step down, want %d", got, len(migrations)-1)
+	}
+
+	if err := store.MigrateDown(len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedCount(t, store); got != 0 {
+		t.Fatalf("%d migrations applied after migrating all the way down", got)
+	}
+	if tables := sqliteTables(t, store); !slices.Equal(tables, []string{"schema_migrations"}) {
+		t.Errorf("tables after migrating down %v, want only schema_migrations", tables)
+	}
+
+	// More steps than applied migrations is not an error
+	if err := store.MigrateDown(1); err != nil {
+		t.Errorf("migrating down an empty database: %v", err)
+	}
+
+	if err := store.MigrateUp(); err != nil {
+		t.Fatalf("migrating up after down: %v", err)
+	}
+	if tables := sqliteTables(t, store); !slices.Equal(tables, upTables) {
+		t.Errorf("tables after the round trip %v, want %v", tables, upTables)
+	}
+}
+
+// The SQLite migration lock is a transaction, a failed migration leaves nothing behind
+func TestMigrationLockRollsBack(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "tasklist.db"))
+
+	errFailed := errors.New("migration failed")
+	err := store.withMigrationLock(func(q queryer) error {
+		if err := runMigrationSQL(q, "sqlite", "CREATE TABLE half_done (id INT);"); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("error %v, want %v", err, errFailed)
+	}
+
+	if tables := sqliteTables(t, store); slices.Contains(tables, "half_done") {
+		t.Errorf("table of the failed migration was kept, tables %v", tables)
+	}
+}
+
+// Instances starting at the same time apply every migration once
+func TestConcurrentMigrateUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasklist.db")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		store := newTestSQLiteStore(t, path)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = store.MigrateUp()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("instance %d: %v", i, err)
+		}
+	}
+
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedCount(t, newTestSQLiteStore(t, path)); got != len(migrations) {
+		t.Errorf("%d of %d migrations applied", got, len(migrations))
+	}
+}
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS users (
+	user_id BINARY(16) NOT NULL PRIMARY KEY,
+	username VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	password VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+	task_id BINARY(16) NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	deadline DATE NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id BINARY(16) NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'todo',
+	completed_at TIMESTAMP NULL DEFAULT NULL,
+
+	PRIMARY KEY(task_id),
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Databases created before migrations existed may have a tasks table
+-- without the status columns, CREATE TABLE IF NOT EXISTS leaves those as is
+SET @add_task_status = (
+	SELECT IF(COUNT(*) = 0,
+		'ALTER TABLE tasks ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT ''todo'', ADD COLUMN completed_at TIMESTAMP NULL DEFAULT NULL',
+		'SELECT 1')
+	FROM information_schema.columns
+	WHERE table_schema = DATABASE() AND table_name = 'tasks' AND column_name = 'status'
+);
+PREPARE add_task_status FROM @add_task_status;
+EXECUTE add_task_status;
+DEALLOCATE PREPARE add_task_status;
@@ -0,0 +1,23 @@
+CREATE TABLE sessions (
+	session_id BINARY(16) NOT NULL PRIMARY KEY,
+	user_id BINARY(16) NOT NULL,
+	user_agent VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP NULL DEFAULT NULL,
+
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Only a hash of each refresh token is stored. Rotated tokens are kept with
+-- used_at set so that presenting one again can be detected as token reuse.
+CREATE TABLE refresh_tokens (
+	token_hash CHAR(64) NOT NULL PRIMARY KEY,
+	session_id BINARY(16) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL DEFAULT NULL,
+
+	FOREIGN KEY(session_id) REFERENCES sessions(session_id) ON DELETE CASCADE
+);
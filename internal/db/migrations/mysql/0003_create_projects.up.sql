@@ -0,0 +1,17 @@
+CREATE TABLE projects (
+	project_id BINARY(16) NOT NULL PRIMARY KEY,
+	user_id BINARY(16) NOT NULL,
+	name VARCHAR(50) NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+
+	UNIQUE KEY projects_user_name (user_id, name),
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Deleting a project leaves its tasks without a project unless
+-- they are deleted first, see DeleteProject
+ALTER TABLE tasks
+	ADD COLUMN project_id BINARY(16) NULL DEFAULT NULL,
+	ADD CONSTRAINT tasks_project FOREIGN KEY(project_id) REFERENCES projects(project_id) ON DELETE SET NULL;
@@ -0,0 +1,19 @@
+CREATE TABLE tags (
+	tag_id BINARY(16) NOT NULL PRIMARY KEY,
+	user_id BINARY(16) NOT NULL,
+	name VARCHAR(30) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	UNIQUE KEY tags_user_name (user_id, name),
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE task_tags (
+	task_id BINARY(16) NOT NULL,
+	tag_id BINARY(16) NOT NULL,
+
+	PRIMARY KEY(task_id, tag_id),
+	KEY task_tags_tag (tag_id),
+	FOREIGN KEY(task_id) REFERENCES tasks(task_id) ON DELETE CASCADE,
+	FOREIGN KEY(tag_id) REFERENCES tags(tag_id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Steps of a task, listed in position order
+CREATE TABLE checklist_items (
+	item_id BINARY(16) NOT NULL PRIMARY KEY,
+	task_id BINARY(16) NOT NULL,
+	title VARCHAR(100) NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	position INT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+
+	KEY checklist_items_task (task_id, position),
+	FOREIGN KEY(task_id) REFERENCES tasks(task_id) ON DELETE CASCADE
+);
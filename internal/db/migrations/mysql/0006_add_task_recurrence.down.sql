@@ -0,0 +1,5 @@
+ALTER TABLE tasks
+	DROP INDEX tasks_series_occurrence,
+	DROP COLUMN occurrence,
+	DROP COLUMN series_id,
+	DROP COLUMN recurrence;
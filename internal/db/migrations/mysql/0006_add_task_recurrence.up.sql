@@ -0,0 +1,7 @@
+-- Occurrences of a recurring task share a series_id and are numbered from 1,
+-- the unique key keeps an occurrence from being generated twice
+ALTER TABLE tasks
+	ADD COLUMN recurrence VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN series_id BINARY(16) NULL DEFAULT NULL,
+	ADD COLUMN occurrence INT NOT NULL DEFAULT 1,
+	ADD UNIQUE KEY tasks_series_occurrence (series_id, occurrence);
@@ -0,0 +1,4 @@
+ALTER TABLE tasks
+	DROP COLUMN all_day,
+	MODIFY COLUMN deadline DATE NOT NULL;
+ALTER TABLE users DROP COLUMN timezone;
@@ -0,0 +1,11 @@
+-- IANA time zone the users deadlines are entered and shown in
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Deadlines get a time of day. all_day marks deadlines given as a date,
+-- those are due at 23:59 in the time zone of the user.
+ALTER TABLE tasks
+	MODIFY COLUMN deadline DATETIME NOT NULL,
+	ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The DATE column dropped the 23:59 UTC time of existing deadlines
+UPDATE tasks SET deadline = TIMESTAMPADD(MINUTE, 23 * 60 + 59, deadline), all_day = TRUE;
@@ -0,0 +1,21 @@
+-- Reminders of a task. Offset reminders follow the deadline of the task,
+-- locked_until keeps a claimed reminder from being sent by another instance
+-- and delays the retry of a failed delivery.
+CREATE TABLE reminders (
+	reminder_id BINARY(16) NOT NULL PRIMARY KEY,
+	task_id BINARY(16) NOT NULL,
+	user_id BINARY(16) NOT NULL,
+	remind_at DATETIME NOT NULL,
+	offset_minutes INT NULL DEFAULT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error VARCHAR(255) NOT NULL DEFAULT '',
+	locked_until DATETIME NULL DEFAULT NULL,
+	sent_at DATETIME NULL DEFAULT NULL,
+	failed_at DATETIME NULL DEFAULT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	KEY reminders_task (task_id),
+	KEY reminders_due (remind_at),
+	FOREIGN KEY(task_id) REFERENCES tasks(task_id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
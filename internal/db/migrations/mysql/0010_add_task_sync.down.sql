@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS task_tombstones;
+ALTER TABLE tasks
+	DROP INDEX tasks_user_version,
+	DROP COLUMN version;
+ALTER TABLE users DROP COLUMN sync_version;
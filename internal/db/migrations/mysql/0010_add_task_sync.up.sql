@@ -0,0 +1,17 @@
+-- Every change to a task takes the next sync_version of its user, clients
+-- of the sync API fetch the changes after the last version they have seen.
+-- Deleted tasks leave a tombstone so that the deletion can be synced too.
+ALTER TABLE users ADD COLUMN sync_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE tasks
+	ADD COLUMN version BIGINT NOT NULL DEFAULT 0,
+	ADD KEY tasks_user_version (user_id, version);
+
+CREATE TABLE task_tombstones (
+	task_id BINARY(16) NOT NULL PRIMARY KEY,
+	user_id BINARY(16) NOT NULL,
+	version BIGINT NOT NULL,
+	deleted_at DATETIME NOT NULL,
+
+	KEY task_tombstones_user_version (user_id, version),
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
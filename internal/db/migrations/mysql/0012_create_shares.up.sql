@@ -0,0 +1,24 @@
+-- Tasks and projects shared with other users. An invitation is sent to an
+-- email, user_id is set when the invited user accepts it. Either task_id or
+-- project_id is set, sharing a project shares every task in it.
+CREATE TABLE shares (
+	share_id BINARY(16) NOT NULL PRIMARY KEY,
+	owner_id BINARY(16) NOT NULL,
+	task_id BINARY(16) NULL DEFAULT NULL,
+	project_id BINARY(16) NULL DEFAULT NULL,
+	email VARCHAR(255) NOT NULL,
+	user_id BINARY(16) NULL DEFAULT NULL,
+	role VARCHAR(20) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+
+	UNIQUE KEY shares_task_email (task_id, email),
+	UNIQUE KEY shares_project_email (project_id, email),
+	KEY shares_email (email, status),
+	KEY shares_user (user_id, status),
+	FOREIGN KEY(owner_id) REFERENCES users(user_id) ON DELETE CASCADE,
+	FOREIGN KEY(task_id) REFERENCES tasks(task_id) ON DELETE CASCADE,
+	FOREIGN KEY(project_id) REFERENCES projects(project_id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+ALTER TABLE projects
+	DROP FOREIGN KEY projects_workspace,
+	DROP COLUMN workspace_id;
+ALTER TABLE tasks
+	DROP FOREIGN KEY tasks_workspace,
+	DROP COLUMN workspace_id;
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,42 @@
+-- Workspaces keep the tasks and projects of a group apart from those of
+-- other groups. Every member has a role in the workspace, invitations are
+-- sent to an email and removed when they are accepted or declined.
+CREATE TABLE workspaces (
+	workspace_id BINARY(16) NOT NULL PRIMARY KEY,
+	name VARCHAR(50) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE workspace_members (
+	workspace_id BINARY(16) NOT NULL,
+	user_id BINARY(16) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (workspace_id, user_id),
+	KEY workspace_members_user (user_id),
+	FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE workspace_invitations (
+	invitation_id BINARY(16) NOT NULL PRIMARY KEY,
+	workspace_id BINARY(16) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	UNIQUE KEY workspace_invitations_email (workspace_id, email),
+	KEY workspace_invitations_invitee (email),
+	FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE
+);
+
+-- Tasks and projects without a workspace are personal. The ones in a
+-- workspace are deleted with it.
+ALTER TABLE tasks
+	ADD COLUMN workspace_id BINARY(16) NULL DEFAULT NULL,
+	ADD CONSTRAINT tasks_workspace FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE;
+ALTER TABLE projects
+	ADD COLUMN workspace_id BINARY(16) NULL DEFAULT NULL,
+	ADD CONSTRAINT projects_workspace FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- Role of the user on the whole API, admins can manage every user and task
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
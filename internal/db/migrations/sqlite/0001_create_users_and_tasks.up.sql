@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users (
+	user_id BLOB NOT NULL PRIMARY KEY,
+	username VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	password VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+	task_id BLOB NOT NULL PRIMARY KEY,
+	title VARCHAR(255) NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	deadline DATE NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	status VARCHAR(16) NOT NULL DEFAULT 'todo',
+	completed_at TIMESTAMP NULL DEFAULT NULL
+);
@@ -0,0 +1,15 @@
+CREATE TABLE projects (
+	project_id BLOB NOT NULL PRIMARY KEY,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	name VARCHAR(50) NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+
+	UNIQUE(user_id, name)
+);
+
+-- Deleting a project leaves its tasks without a project unless
+-- they are deleted first, see DeleteProject
+ALTER TABLE tasks ADD COLUMN project_id BLOB NULL DEFAULT NULL REFERENCES projects(project_id) ON DELETE SET NULL;
+CREATE INDEX tasks_project ON tasks(project_id);
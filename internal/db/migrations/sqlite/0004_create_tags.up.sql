@@ -0,0 +1,16 @@
+CREATE TABLE tags (
+	tag_id BLOB NOT NULL PRIMARY KEY,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	name VARCHAR(30) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	UNIQUE(user_id, name)
+);
+
+CREATE TABLE task_tags (
+	task_id BLOB NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+	tag_id BLOB NOT NULL REFERENCES tags(tag_id) ON DELETE CASCADE,
+
+	PRIMARY KEY(task_id, tag_id)
+);
+CREATE INDEX task_tags_tag ON task_tags(tag_id);
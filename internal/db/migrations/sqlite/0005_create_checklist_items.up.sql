@@ -0,0 +1,11 @@
+-- Steps of a task, listed in position order
+CREATE TABLE checklist_items (
+	item_id BLOB NOT NULL PRIMARY KEY,
+	task_id BLOB NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+	title VARCHAR(100) NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	position INT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX checklist_items_task ON checklist_items(task_id, position);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS tasks_series_occurrence;
+ALTER TABLE tasks DROP COLUMN occurrence;
+ALTER TABLE tasks DROP COLUMN series_id;
+ALTER TABLE tasks DROP COLUMN recurrence;
@@ -0,0 +1,6 @@
+-- Occurrences of a recurring task share a series_id and are numbered from 1,
+-- the unique index keeps an occurrence from being generated twice
+ALTER TABLE tasks ADD COLUMN recurrence VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN series_id BLOB NULL DEFAULT NULL;
+ALTER TABLE tasks ADD COLUMN occurrence INT NOT NULL DEFAULT 1;
+CREATE UNIQUE INDEX tasks_series_occurrence ON tasks(series_id, occurrence);
@@ -0,0 +1,8 @@
+-- IANA time zone the users deadlines are entered and shown in
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Deadlines get a time of day. all_day marks deadlines given as a date,
+-- those are due at 23:59 in the time zone of the user. SQLite kept the
+-- time of existing deadlines, which were all created at 23:59 UTC.
+ALTER TABLE tasks ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE tasks SET all_day = TRUE WHERE strftime('%H:%M', deadline) = '23:59';
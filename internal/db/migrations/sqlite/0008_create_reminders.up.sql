@@ -0,0 +1,18 @@
+-- Reminders of a task. Offset reminders follow the deadline of the task,
+-- locked_until keeps a claimed reminder from being sent by another instance
+-- and delays the retry of a failed delivery.
+CREATE TABLE reminders (
+	reminder_id BLOB NOT NULL PRIMARY KEY,
+	task_id BLOB NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	remind_at DATETIME NOT NULL,
+	offset_minutes INT NULL DEFAULT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error VARCHAR(255) NOT NULL DEFAULT '',
+	locked_until DATETIME NULL DEFAULT NULL,
+	sent_at DATETIME NULL DEFAULT NULL,
+	failed_at DATETIME NULL DEFAULT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX reminders_task ON reminders(task_id);
+CREATE INDEX reminders_due ON reminders(remind_at);
@@ -0,0 +1,31 @@
+-- Webhook subscriptions of users, events is a comma separated list of event types
+CREATE TABLE webhooks (
+	webhook_id BLOB NOT NULL PRIMARY KEY,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	url VARCHAR(2048) NOT NULL,
+	events VARCHAR(255) NOT NULL,
+	secret VARCHAR(100) NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX webhooks_user ON webhooks(user_id);
+
+-- Every event sent to a webhook. A pending delivery is sent at next_attempt_at,
+-- which is also pushed forward while an instance is sending it.
+CREATE TABLE webhook_deliveries (
+	delivery_id BLOB NOT NULL PRIMARY KEY,
+	webhook_id BLOB NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+	user_id BLOB NOT NULL,
+	event VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	response_status INT NULL DEFAULT NULL,
+	last_error VARCHAR(255) NOT NULL DEFAULT '',
+	next_attempt_at DATETIME NULL DEFAULT NULL,
+	delivered_at DATETIME NULL DEFAULT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at);
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
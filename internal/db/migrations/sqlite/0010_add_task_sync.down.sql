@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS task_tombstones;
+DROP INDEX IF EXISTS tasks_user_version;
+ALTER TABLE tasks DROP COLUMN version;
+ALTER TABLE users DROP COLUMN sync_version;
@@ -0,0 +1,14 @@
+-- Every change to a task takes the next sync_version of its user, clients
+-- of the sync API fetch the changes after the last version they have seen.
+-- Deleted tasks leave a tombstone so that the deletion can be synced too.
+ALTER TABLE users ADD COLUMN sync_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX tasks_user_version ON tasks(user_id, version);
+
+CREATE TABLE task_tombstones (
+	task_id BLOB NOT NULL PRIMARY KEY,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	version BIGINT NOT NULL,
+	deleted_at DATETIME NOT NULL
+);
+CREATE INDEX task_tombstones_user_version ON task_tombstones(user_id, version);
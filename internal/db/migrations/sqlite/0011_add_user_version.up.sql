@@ -0,0 +1,2 @@
+-- Grows with every update of the user, sent as the ETag of the user
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,20 @@
+-- Tasks and projects shared with other users. An invitation is sent to an
+-- email, user_id is set when the invited user accepts it. Either task_id or
+-- project_id is set, sharing a project shares every task in it.
+CREATE TABLE shares (
+	share_id BLOB NOT NULL PRIMARY KEY,
+	owner_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	task_id BLOB NULL DEFAULT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+	project_id BLOB NULL DEFAULT NULL REFERENCES projects(project_id) ON DELETE CASCADE,
+	email VARCHAR(255) NOT NULL,
+	user_id BLOB NULL DEFAULT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	role VARCHAR(20) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+
+	UNIQUE(task_id, email),
+	UNIQUE(project_id, email)
+);
+CREATE INDEX shares_email ON shares(email, status);
+CREATE INDEX shares_user ON shares(user_id, status);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS projects_workspace;
+ALTER TABLE projects DROP COLUMN workspace_id;
+DROP INDEX IF EXISTS tasks_workspace;
+ALTER TABLE tasks DROP COLUMN workspace_id;
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,37 @@
+-- Workspaces keep the tasks and projects of a group apart from those of
+-- other groups. Every member has a role in the workspace, invitations are
+-- sent to an email and removed when they are accepted or declined.
+CREATE TABLE workspaces (
+	workspace_id BLOB NOT NULL PRIMARY KEY,
+	name VARCHAR(50) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE workspace_members (
+	workspace_id BLOB NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+	user_id BLOB NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	role VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX workspace_members_user ON workspace_members(user_id);
+
+CREATE TABLE workspace_invitations (
+	invitation_id BLOB NOT NULL PRIMARY KEY,
+	workspace_id BLOB NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+	email VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+
+	UNIQUE(workspace_id, email)
+);
+CREATE INDEX workspace_invitations_invitee ON workspace_invitations(email);
+
+-- Tasks and projects without a workspace are personal. The ones in a
+-- workspace are deleted with it.
+ALTER TABLE tasks ADD COLUMN workspace_id BLOB NULL DEFAULT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE;
+CREATE INDEX tasks_workspace ON tasks(workspace_id);
+ALTER TABLE projects ADD COLUMN workspace_id BLOB NULL DEFAULT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE;
+CREATE INDEX projects_workspace ON projects(workspace_id);
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type MySQLStore struct {
+	sqlStore
+}
+
+func NewStore() (*MySQLStore, error) {
+	// Database config, NewConfig fills in the driver defaults
+	DBconf := mysql.NewConfig()
+	DBconf.User = os.Getenv("DBUSER")
+	DBconf.Passwd = os.Getenv("DBPASS")
+	DBconf.Net = "tcp"
+	DBconf.Addr = os.Getenv("DBSERVER")
+	DBconf.DBName = os.Getenv("DBNAME")
+	DBconf.ParseTime = true
+	// Report matched instead of changed rows, an update that changes nothing
+	// must not look like a missing row
+	DBconf.ClientFoundRows = true
+
+	db, err := sql.Open("mysql", DBconf.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return &MySQLStore{
+		sqlStore{db: db, dialect: "mysql"},
+	}, nil
+}
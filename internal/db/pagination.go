@@ -0,0 +1,163 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Returned for cursors that are malformed or belong to a listing with another order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Task listings use keyset pagination: the cursor holds the sort value and
+// ID of the last task on the page, the next page starts right after it.
+// Unlike offsets this stays correct when tasks are added or removed between pages.
+type taskCursor struct {
+	Sort       utils.TaskSort `json:"s"`
+	Descending bool           `json:"d"`
+	Value      string         `json:"v"`
+	ID         uuid.UUID      `json:"id"`
+}
+
+// Fills in the defaults and checks the limits of a listing request
+func normalizeTaskFilter(filter utils.TaskFilter) utils.TaskFilter {
+	if filter.Sort == "" {
+		filter.Sort = utils.SortCreatedAt
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = utils.DefaultTaskLimit
+	} else if filter.Limit > utils.MaxTaskLimit {
+		filter.Limit = utils.MaxTaskLimit
+	}
+
+	return filter
+}
+
+// The value of the field the listing is sorted by, times are in a format that sorts as text
+func taskSortValue(task utils.Task, sort utils.TaskSort) string {
+	switch sort {
+	case utils.SortTitle:
+		return task.Title
+	case utils.SortDeadline:
+		return task.Deadline.UTC().Format(time.RFC3339Nano)
+	case utils.SortUpdatedAt:
+		return task.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return task.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+func encodeTaskCursor(task utils.Task, filter utils.TaskFilter) string {
+	b, _ := json.Marshal(taskCursor{
+		Sort:       filter.Sort,
+		Descending: filter.Descending,
+		Value:      taskSortValue(task, filter.Sort),
+		ID:         task.ID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decodes the cursor of the filter, nil if the first page is requested
+func decodeTaskCursor(filter utils.TaskFilter) (*taskCursor, error) {
+	if filter.Cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor taskCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort != filter.Sort || cursor.Descending != filter.Descending {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort != utils.SortTitle {
+		if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return &cursor, nil
+}
+
+// The cursor value as a query argument, a time for the timestamp columns
+func (c *taskCursor) sqlValue() any {
+	if c.Sort == utils.SortTitle {
+		return c.Value
+	}
+
+	t, _ := time.Parse(time.RFC3339Nano, c.Value)
+	return t
+}
+
+// Cuts the extra row fetched past the limit and sets the cursor for the next page
+func newTaskPage(tasks []utils.Task, filter utils.TaskFilter) utils.TaskPage {
+	page := utils.TaskPage{Tasks: tasks}
+	if page.Tasks == nil {
+		page.Tasks = []utils.Task{}
+	}
+
+	if len(page.Tasks) > filter.Limit {
+		page.Tasks = page.Tasks[:filter.Limit]
+		page.NextCursor = encodeTaskCursor(page.Tasks[len(page.Tasks)-1], filter)
+	}
+
+	return page
+}
+
+// Compares two tasks in the order of the listing, ties are broken by ID
+// like in the ORDER BY of the SQL stores
+func compareTasks(a, b utils.Task, sort utils.TaskSort) int {
+	var c int
+	switch sort {
+	case utils.SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	case utils.SortDeadline:
+		c = a.Deadline.Compare(b.Deadline)
+	case utils.SortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// Reports whether the task comes after the cursor position in the listing order
+func (c *taskCursor) before(task utils.Task) bool {
+	cursorTask := utils.Task{ID: c.ID}
+	switch c.Sort {
+	case utils.SortTitle:
+		cursorTask.Title = c.Value
+	case utils.SortDeadline:
+		cursorTask.Deadline, _ = time.Parse(time.RFC3339Nano, c.Value)
+	case utils.SortUpdatedAt:
+		cursorTask.UpdatedAt, _ = time.Parse(time.RFC3339Nano, c.Value)
+	default:
+		cursorTask.CreatedAt, _ = time.Parse(time.RFC3339Nano, c.Value)
+	}
+
+	cmp := compareTasks(cursorTask, task, c.Sort)
+	if c.Descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
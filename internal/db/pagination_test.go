@@ -0,0 +1,84 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func TestTaskCursorRoundTrip(t *testing.T) {
+	task := utils.Task{
+		ID:        uuid.New(),
+		Title:     "Math homework",
+		Deadline:  time.Date(2030, 12, 1, 12, 0, 0, 0, time.FixedZone("EET", 2*60*60)),
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC),
+	}
+
+	for _, sort := range []utils.TaskSort{utils.SortCreatedAt, utils.SortUpdatedAt, utils.SortDeadline, utils.SortTitle} {
+		for _, descending := range []bool{false, true} {
+			filter := utils.TaskFilter{Sort: sort, Descending: descending}
+			filter.Cursor = encodeTaskCursor(task, filter)
+
+			cursor, err := decodeTaskCursor(filter)
+			if err != nil {
+				t.Fatalf("%s descending=%v: %v", sort, descending, err)
+			}
+			if cursor.ID != task.ID || cursor.Value != taskSortValue(task, sort) {
+				t.Errorf("%s descending=%v: decoded %+v", sort, descending, cursor)
+			}
+
+			// The task the cursor was made from is not on the next page, the ones around it are on one side
+			if cursor.before(task) {
+				t.Errorf("%s descending=%v: the task of the cursor comes after it", sort, descending)
+			}
+			later := task
+			later.ID = uuid.Max
+			if cursor.before(later) == descending {
+				t.Errorf("%s descending=%v: task with the same value and a greater ID is on the wrong side", sort, descending)
+			}
+		}
+	}
+}
+
+func rawCursor(t *testing.T, cursor taskCursor) string {
+	t.Helper()
+
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestDecodeTaskCursorErrors(t *testing.T) {
+	task := utils.Task{ID: uuid.New(), CreatedAt: time.Now()}
+	cursor := encodeTaskCursor(task, utils.TaskFilter{Sort: utils.SortCreatedAt})
+
+	tests := []struct {
+		name   string
+		filter utils.TaskFilter
+	}{
+		{"not base64", utils.TaskFilter{Sort: utils.SortCreatedAt, Cursor: "%%%"}},
+		{"not JSON", utils.TaskFilter{Sort: utils.SortCreatedAt, Cursor: "bm90IGpzb24"}},
+		{"other sort", utils.TaskFilter{Sort: utils.SortTitle, Cursor: cursor}},
+		{"other order", utils.TaskFilter{Sort: utils.SortCreatedAt, Descending: true, Cursor: cursor}},
+		{"value is not a time", utils.TaskFilter{Sort: utils.SortDeadline, Cursor: rawCursor(t, taskCursor{Sort: utils.SortDeadline, Value: "2030-12-01"})}},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeTaskCursor(tt.filter); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: error %v, want ErrInvalidCursor", tt.name, err)
+		}
+	}
+
+	if cursor, err := decodeTaskCursor(utils.TaskFilter{Sort: utils.SortCreatedAt}); cursor != nil || err != nil {
+		t.Errorf("first page: cursor %+v, error %v", cursor, err)
+	}
+}
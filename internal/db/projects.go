@@ -0,0 +1,338 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Returned when a project is created or renamed with the name of another project of the user
+var ErrDuplicateProject = errors.New("project name already in use")
+
+// Column order expected by scanProject
+const projectColumns = "project_id, user_id, name, description, created_at, updated_at, workspace_id"
+
+func scanProject(row rowScanner) (utils.Project, error) {
+	var project utils.Project
+	var workspaceID uuid.NullUUID
+
+	err := row.Scan(
+		&project.ID,
+		&project.UserID,
+		&project.Name,
+		&project.Description,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+		&workspaceID,
+	)
+
+	if workspaceID.Valid {
+		project.WorkspaceID = &workspaceID.UUID
+	}
+
+	return project, err
+}
+
+func (s *sqlStore) queryProjects(query string, args ...any) ([]utils.Project, error) {
+	rows, err := s.db.Query("SELECT "+projectColumns+" FROM projects WHERE "+query+" ORDER BY name, project_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []utils.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+
+	return projects, rows.Err()
+}
+
+func (s *sqlStore) GetProjects(userID uuid.UUID) ([]utils.Project, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryProjects("user_id = ? AND workspace_id IS NULL", userIDBin)
+}
+
+// Projects are scoped to the owning user like tasks, the projects of
+// workspaces are looked up with GetWorkspaceProject
+func (s *sqlStore) GetProject(userID, id uuid.UUID) (utils.Project, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Project{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.Project{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE project_id = ? AND user_id = ? AND workspace_id IS NULL", idBin, userIDBin)
+
+	return scanProject(row)
+}
+
+// Stores a new project, the generated ID and timestamps are set on project
+func (s *sqlStore) CreateProject(project *utils.Project) error {
+	projectID := uuid.New()
+	projectIDBin, err := projectID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := project.UserID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	workspaceIDBin, err := nullableUUID(project.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = s.db.Exec("INSERT INTO projects (project_id, user_id, name, description, created_at, updated_at, workspace_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		projectIDBin, userIDBin, project.Name, project.Description, createdAt, createdAt, workspaceIDBin)
+	if isUniqueViolation(err) {
+		return ErrDuplicateProject
+	} else if err != nil {
+		return err
+	}
+
+	project.ID = projectID
+	project.CreatedAt = createdAt
+	project.UpdatedAt = createdAt
+
+	return nil
+}
+
+func (s *sqlStore) UpdateProject(userID, id uuid.UUID, project utils.Project) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE projects SET name = ?, description = ?, updated_at = ? WHERE project_id = ? AND user_id = ?",
+		project.Name, project.Description, now(), idBin, userIDBin)
+	if isUniqueViolation(err) {
+		return ErrDuplicateProject
+	} else if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Deletes the personal project. Its tasks are deleted with it when
+// deleteTasks is set, otherwise the foreign key leaves them without a project.
+func (s *sqlStore) DeleteProject(userID, id uuid.UUID, deleteTasks bool) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if deleteTasks {
+		if err := tombstoneTasks(tx, version, userIDBin, "project_id = ?", idBin); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("DELETE FROM tasks WHERE project_id = ? AND user_id = ?", idBin, userIDBin)
+		if err != nil {
+			return err
+		}
+	} else if err := touchTasks(tx, version, userIDBin, "project_id = ?", idBin); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM projects WHERE project_id = ? AND user_id = ? AND workspace_id IS NULL", idBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Name of a project with a number added, shortened to fit the longest allowed name
+func numberedProjectName(name string, n int) string {
+	const maxLength = 50
+
+	suffix := fmt.Sprintf(" (%d)", n)
+	runes := []rune(name)
+	if keep := maxLength - len(suffix); len(runes) > keep {
+		runes = runes[:keep]
+	}
+
+	return string(runes) + suffix
+}
+
+// Reports whether the name belongs to a project of the user other than exceptID
+func (m *MemoryStore) projectNameTaken(userID uuid.UUID, name string, exceptID uuid.UUID) bool {
+	for _, project := range m.projects {
+		if project.UserID == userID && project.Name == name && project.ID != exceptID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *MemoryStore) GetProjects(userID uuid.UUID) ([]utils.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterProjects(func(project utils.Project) bool {
+		return project.UserID == userID && project.WorkspaceID == nil
+	}), nil
+}
+
+// Returns the matching projects ordered by name
+func (m *MemoryStore) filterProjects(match func(utils.Project) bool) []utils.Project {
+	projects := []utils.Project{}
+	for _, project := range m.projects {
+		if match(project) {
+			projects = append(projects, project)
+		}
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Name != projects[j].Name {
+			return projects[i].Name < projects[j].Name
+		}
+		return projects[i].ID.String() < projects[j].ID.String()
+	})
+
+	return projects
+}
+
+func (m *MemoryStore) GetProject(userID, id uuid.UUID) (utils.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	project, ok := m.projects[id]
+	if !ok || project.UserID != userID || project.WorkspaceID != nil {
+		return utils.Project{}, sql.ErrNoRows
+	}
+
+	return project, nil
+}
+
+func (m *MemoryStore) CreateProject(project *utils.Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[project.UserID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+	if project.WorkspaceID != nil {
+		if _, ok := m.workspaces[*project.WorkspaceID]; !ok {
+			return errors.New("foreign key constraint failed: unknown workspace")
+		}
+	}
+
+	if m.projectNameTaken(project.UserID, project.Name, uuid.Nil) {
+		return ErrDuplicateProject
+	}
+
+	created := *project
+	created.ID = uuid.New()
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+
+	m.projects[created.ID] = created
+
+	*project = created
+	return nil
+}
+
+func (m *MemoryStore) UpdateProject(userID, id uuid.UUID, project utils.Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.projects[id]
+	if !ok || existing.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	if m.projectNameTaken(userID, project.Name, id) {
+		return ErrDuplicateProject
+	}
+
+	existing.Name = project.Name
+	existing.Description = project.Description
+	existing.UpdatedAt = now()
+
+	m.projects[id] = existing
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteProject(userID, id uuid.UUID, deleteTasks bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	project, ok := m.projects[id]
+	if !ok || project.UserID != userID || project.WorkspaceID != nil {
+		return sql.ErrNoRows
+	}
+
+	version := m.nextSyncVersion(userID)
+	for taskID, task := range m.tasks {
+		if task.ProjectID == nil || *task.ProjectID != id {
+			continue
+		}
+
+		if deleteTasks {
+			m.tombstoneTask(task, version)
+			m.deleteTask(taskID)
+		} else {
+			task.ProjectID = nil
+			task.Version = version
+			m.tasks[taskID] = task
+		}
+	}
+
+	for shareID, share := range m.shares {
+		if share.ProjectID != nil && *share.ProjectID == id {
+			delete(m.shares, shareID)
+		}
+	}
+	delete(m.projects, id)
+
+	return nil
+}
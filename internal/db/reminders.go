@@ -0,0 +1,460 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Length of the last_error columns
+const maxLastError = 255
+
+// Column order expected by scanReminder
+const reminderColumns = "reminder_id, task_id, user_id, remind_at, offset_minutes, attempts, last_error, sent_at, failed_at, created_at"
+
+func scanReminder(row rowScanner) (utils.Reminder, error) {
+	var reminder utils.Reminder
+
+	err := row.Scan(
+		&reminder.ID,
+		&reminder.TaskID,
+		&reminder.UserID,
+		&reminder.RemindAt,
+		&reminder.OffsetMinutes,
+		&reminder.Attempts,
+		&reminder.LastError,
+		&reminder.SentAt,
+		&reminder.FailedAt,
+		&reminder.CreatedAt,
+	)
+
+	return reminder, err
+}
+
+func queryReminders(q dbtx, query string, args ...any) ([]utils.Reminder, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []utils.Reminder{}
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+// Error message cut to fit the last_error column
+func lastError(msg string) string {
+	if len(msg) <= maxLastError {
+		return msg
+	}
+
+	// Drops the rune cut in half, if any
+	return strings.ToValidUTF8(msg[:maxLastError], "")
+}
+
+func (s *sqlStore) GetReminders(userID, taskID uuid.UUID) ([]utils.Reminder, error) {
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTaskOwner(s.db, userIDBin, taskIDBin); err != nil {
+		return nil, err
+	}
+
+	return queryReminders(s.db, "SELECT "+reminderColumns+" FROM reminders WHERE task_id = ? ORDER BY remind_at, reminder_id", taskIDBin)
+}
+
+func (s *sqlStore) GetReminder(userID, taskID, id uuid.UUID) (utils.Reminder, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Reminder{}, err
+	}
+
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return utils.Reminder{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.Reminder{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+reminderColumns+" FROM reminders WHERE reminder_id = ? AND task_id = ? AND user_id = ?",
+		idBin, taskIDBin, userIDBin)
+
+	return scanReminder(row)
+}
+
+// Stores a new reminder for a task of reminder.UserID, the generated ID and
+// creation time are set on reminder
+func (s *sqlStore) CreateReminder(reminder *utils.Reminder) error {
+	taskIDBin, err := reminder.TaskID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := reminder.UserID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	reminderID := uuid.New()
+	reminderIDBin, err := reminderID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTaskOwner(tx, userIDBin, taskIDBin); err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = tx.Exec("INSERT INTO reminders (reminder_id, task_id, user_id, remind_at, offset_minutes, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		reminderIDBin, taskIDBin, userIDBin, reminder.RemindAt.UTC(), reminder.OffsetMinutes, createdAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	reminder.ID = reminderID
+	reminder.CreatedAt = createdAt
+
+	return nil
+}
+
+func (s *sqlStore) DeleteReminder(userID, taskID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("DELETE FROM reminders WHERE reminder_id = ? AND task_id = ? AND user_id = ?", idBin, taskIDBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Moves the offset reminders of a task along with its deadline
+func rescheduleReminders(q dbtx, taskIDBin []byte, deadline time.Time) error {
+	reminders, err := queryReminders(q, "SELECT "+reminderColumns+" FROM reminders WHERE task_id = ? AND offset_minutes IS NOT NULL", taskIDBin)
+	if err != nil {
+		return err
+	}
+
+	for _, reminder := range reminders {
+		if !reminder.FollowDeadline(deadline) {
+			continue
+		}
+
+		idBin, err := reminder.ID.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec("UPDATE reminders SET remind_at = ?, attempts = ?, last_error = ?, sent_at = ?, failed_at = ?, locked_until = NULL WHERE reminder_id = ?",
+			reminder.RemindAt.UTC(), reminder.Attempts, reminder.LastError, utcTime(reminder.SentAt), utcTime(reminder.FailedAt), idBin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Claims up to limit due reminders for delivery. A claimed reminder is not
+// returned again, also by other instances sharing the database, until the
+// lease has passed, so that it is retried if the instance dies while sending it.
+func (s *sqlStore) ClaimDueReminders(limit int, lease time.Duration) ([]utils.Reminder, error) {
+	claimedAt := now()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets other instances claim the remaining reminders instead of
+	// waiting for this transaction. SQLite transactions lock the whole database.
+	var lock string
+	if s.dialect == "mysql" {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+
+	reminders, err := queryReminders(tx, "SELECT "+reminderColumns+" FROM reminders WHERE sent_at IS NULL AND failed_at IS NULL AND remind_at <= ? AND (locked_until IS NULL OR locked_until <= ?) ORDER BY remind_at LIMIT ?"+lock,
+		claimedAt, claimedAt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedUntil := claimedAt.Add(lease)
+	for _, reminder := range reminders {
+		idBin, err := reminder.ID.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec("UPDATE reminders SET locked_until = ? WHERE reminder_id = ?", lockedUntil, idBin); err != nil {
+			return nil, err
+		}
+	}
+
+	return reminders, tx.Commit()
+}
+
+// Records a delivered reminder
+func (s *sqlStore) MarkReminderSent(id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE reminders SET attempts = attempts + 1, last_error = '', sent_at = ?, locked_until = NULL WHERE reminder_id = ?", now(), idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Records a failed delivery, the reminder is claimable again at retryAt
+func (s *sqlStore) RetryReminder(id uuid.UUID, errMsg string, retryAt time.Time) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE reminders SET attempts = attempts + 1, last_error = ?, locked_until = ? WHERE reminder_id = ?",
+		lastError(errMsg), retryAt.UTC(), idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Records the last failed delivery of a reminder that is not retried anymore
+func (s *sqlStore) FailReminder(id uuid.UUID, errMsg string) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE reminders SET attempts = attempts + 1, last_error = ?, failed_at = ?, locked_until = NULL WHERE reminder_id = ?",
+		lastError(errMsg), now(), idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+func (m *MemoryStore) GetReminders(userID, taskID uuid.UUID) ([]utils.Reminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.ownsTask(userID, taskID) {
+		return nil, sql.ErrNoRows
+	}
+
+	reminders := []utils.Reminder{}
+	for _, reminder := range m.reminders {
+		if reminder.TaskID == taskID {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	sortReminders(reminders)
+	return reminders, nil
+}
+
+func sortReminders(reminders []utils.Reminder) {
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].RemindAt.Equal(reminders[j].RemindAt) {
+			return reminders[i].RemindAt.Before(reminders[j].RemindAt)
+		}
+		return reminders[i].ID.String() < reminders[j].ID.String()
+	})
+}
+
+func (m *MemoryStore) GetReminder(userID, taskID, id uuid.UUID) (utils.Reminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok || reminder.TaskID != taskID || reminder.UserID != userID {
+		return utils.Reminder{}, sql.ErrNoRows
+	}
+
+	return reminder, nil
+}
+
+func (m *MemoryStore) CreateReminder(reminder *utils.Reminder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.ownsTask(reminder.UserID, reminder.TaskID) {
+		return sql.ErrNoRows
+	}
+
+	created := *reminder
+	created.ID = uuid.New()
+	created.RemindAt = created.RemindAt.UTC()
+	created.CreatedAt = now()
+
+	m.reminders[created.ID] = created
+
+	*reminder = created
+	return nil
+}
+
+func (m *MemoryStore) DeleteReminder(userID, taskID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok || reminder.TaskID != taskID || reminder.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	delete(m.reminders, id)
+	delete(m.reminderLocks, id)
+
+	return nil
+}
+
+// Moves the offset reminders of a task like rescheduleReminders does
+func (m *MemoryStore) rescheduleReminders(taskID uuid.UUID, deadline time.Time) {
+	for id, reminder := range m.reminders {
+		if reminder.TaskID == taskID && reminder.FollowDeadline(deadline) {
+			m.reminders[id] = reminder
+			delete(m.reminderLocks, id)
+		}
+	}
+}
+
+func (m *MemoryStore) ClaimDueReminders(limit int, lease time.Duration) ([]utils.Reminder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claimedAt := now()
+
+	reminders := []utils.Reminder{}
+	for id, reminder := range m.reminders {
+		if reminder.SentAt != nil || reminder.FailedAt != nil || reminder.RemindAt.After(claimedAt) {
+			continue
+		}
+		if lockedUntil, ok := m.reminderLocks[id]; ok && lockedUntil.After(claimedAt) {
+			continue
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	sortReminders(reminders)
+	if len(reminders) > limit {
+		reminders = reminders[:limit]
+	}
+
+	for _, reminder := range reminders {
+		m.reminderLocks[reminder.ID] = claimedAt.Add(lease)
+	}
+
+	return reminders, nil
+}
+
+func (m *MemoryStore) MarkReminderSent(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	sentAt := now()
+	reminder.Attempts++
+	reminder.LastError = ""
+	reminder.SentAt = &sentAt
+
+	m.reminders[id] = reminder
+	delete(m.reminderLocks, id)
+
+	return nil
+}
+
+func (m *MemoryStore) RetryReminder(id uuid.UUID, errMsg string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	reminder.Attempts++
+	reminder.LastError = lastError(errMsg)
+
+	m.reminders[id] = reminder
+	m.reminderLocks[id] = retryAt
+
+	return nil
+}
+
+func (m *MemoryStore) FailReminder(id uuid.UUID, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	failedAt := now()
+	reminder.Attempts++
+	reminder.LastError = lastError(errMsg)
+	reminder.FailedAt = &failedAt
+
+	m.reminders[id] = reminder
+	delete(m.reminderLocks, id)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func createTestReminder(t *testing.T, store Storage, task utils.Task, remindAt time.Time) uuid.UUID {
+	t.Helper()
+
+	reminder := utils.Reminder{TaskID: task.ID, UserID: task.UserID, RemindAt: remindAt}
+	if err := store.CreateReminder(&reminder); err != nil {
+		t.Fatal(err)
+	}
+
+	return reminder.ID
+}
+
+func claimReminders(t *testing.T, store Storage, lease time.Duration) []uuid.UUID {
+	t.Helper()
+
+	reminders, err := store.ClaimDueReminders(10, lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]uuid.UUID, len(reminders))
+	for i, reminder := range reminders {
+		ids[i] = reminder.ID
+	}
+	return ids
+}
+
+func TestClaimDueRemindersLease(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		userID := createTestUser(t, store, "example@tasklist.com")
+		task := createTestTask(t, store, userID, "Remind me")
+
+		due := createTestReminder(t, store, task, time.Now().Add(-time.Minute))
+		createTestReminder(t, store, task, time.Now().Add(time.Hour))
+
+		if got := claimReminders(t, store, time.Second); !slices.Equal(got, []uuid.UUID{due}) {
+			t.Fatalf("first claim %v, want the due reminder %s", got, due)
+		}
+		if got := claimReminders(t, store, time.Second); len(got) != 0 {
+			t.Fatalf("reminders claimed again during the lease %v", got)
+		}
+
+		// A sender that died without marking the reminder leaves it for the next claim
+		time.Sleep(time.Second)
+		if got := claimReminders(t, store, time.Hour); !slices.Equal(got, []uuid.UUID{due}) {
+			t.Fatalf("claim after the lease %v, want the due reminder %s", got, due)
+		}
+		if got := claimReminders(t, store, time.Hour); len(got) != 0 {
+			t.Fatalf("reminders claimed again during the lease %v", got)
+		}
+
+		// A retried reminder waits until its retry time
+		if err := store.RetryReminder(due, "smtp timeout", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if got := claimReminders(t, store, time.Hour); len(got) != 0 {
+			t.Fatalf("retried reminder claimed before its retry time %v", got)
+		}
+		if err := store.RetryReminder(due, "smtp timeout", time.Now().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if got := claimReminders(t, store, time.Hour); !slices.Equal(got, []uuid.UUID{due}) {
+			t.Fatalf("claim after the retry time %v, want the due reminder %s", got, due)
+		}
+	})
+}
+
+func TestClaimDueRemindersSkipsFinished(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		userID := createTestUser(t, store, "example@tasklist.com")
+		task := createTestTask(t, store, userID, "Remind me")
+
+		sent := createTestReminder(t, store, task, time.Now().Add(-time.Minute))
+		failed := createTestReminder(t, store, task, time.Now().Add(-time.Minute))
+		pending := createTestReminder(t, store, task, time.Now().Add(-time.Minute))
+
+		if err := store.MarkReminderSent(sent); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.FailReminder(failed, "mailbox unavailable"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Expired leases do not bring back finished reminders
+		if got := claimReminders(t, store, 0); !slices.Equal(got, []uuid.UUID{pending}) {
+			t.Fatalf("claimed %v, want only the pending reminder %s", got, pending)
+		}
+
+		reminder, err := store.GetReminder(userID, task.ID, failed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reminder.FailedAt == nil || reminder.LastError != "mailbox unavailable" {
+			t.Errorf("failed reminder %+v", reminder)
+		}
+	})
+}
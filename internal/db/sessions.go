@@ -0,0 +1,326 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+var (
+	// A refresh token that was already rotated was presented again, the
+	// session it belongs to is revoked since the token has probably leaked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	ErrSessionExpired     = errors.New("session expired or revoked")
+)
+
+// Column order expected by scanSession
+const sessionColumns = "session_id, user_id, user_agent, created_at, last_used_at, expires_at, revoked_at"
+
+func scanSession(row rowScanner) (utils.Session, error) {
+	var session utils.Session
+	var revokedAt sql.NullTime
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return session, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return session, nil
+}
+
+// Stores a new session along with its first refresh token, the generated ID is set on session
+func (s *sqlStore) CreateSession(session *utils.Session, refreshTokenHash string) error {
+	sessionID := uuid.New()
+	sessionIDBin, err := sessionID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := session.UserID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	createdAt := now()
+	_, err = tx.Exec("INSERT INTO sessions (session_id, user_id, user_agent, created_at, last_used_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		sessionIDBin, userIDBin, session.UserAgent, createdAt, createdAt, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO refresh_tokens (token_hash, session_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		refreshTokenHash, sessionIDBin, createdAt, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	session.ID = sessionID
+	session.CreatedAt = createdAt
+	session.LastUsedAt = createdAt
+
+	return nil
+}
+
+func (s *sqlStore) GetSession(id uuid.UUID) (utils.Session, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Session{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE session_id = ?", idBin)
+
+	return scanSession(row)
+}
+
+// Lists the sessions of the user that are neither revoked nor expired
+func (s *sqlStore) GetSessionsByUserID(userID uuid.UUID) ([]utils.Session, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_used_at DESC",
+		userIDBin, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []utils.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (s *sqlStore) RevokeSession(userID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE sessions SET revoked_at = ? WHERE session_id = ? AND user_id = ? AND revoked_at IS NULL",
+		now(), idBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Exchanges a refresh token for a new one in the same session. The old token
+// is marked used in the same statement that checks it, so of two concurrent
+// refreshes with one token only one succeeds and the other counts as reuse.
+func (s *sqlStore) RotateRefreshToken(oldHash, newHash string) (utils.Session, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return utils.Session{}, err
+	}
+	defer tx.Rollback()
+
+	usedAt := now()
+	result, err := tx.Exec("UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		usedAt, oldHash, usedAt)
+	if err != nil {
+		return utils.Session{}, err
+	}
+
+	var sessionID uuid.UUID
+	var previousUse sql.NullTime
+	err = tx.QueryRow("SELECT session_id, used_at FROM refresh_tokens WHERE token_hash = ?", oldHash).Scan(&sessionID, &previousUse)
+	if err != nil {
+		return utils.Session{}, err
+	}
+
+	if err := expectAffected(result); err != nil {
+		if !previousUse.Valid {
+			return utils.Session{}, ErrSessionExpired
+		}
+
+		sessionIDBin, err := sessionID.MarshalBinary()
+		if err != nil {
+			return utils.Session{}, err
+		}
+		if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE session_id = ? AND revoked_at IS NULL", usedAt, sessionIDBin); err != nil {
+			return utils.Session{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return utils.Session{}, err
+		}
+		return utils.Session{}, ErrRefreshTokenReused
+	}
+
+	sessionIDBin, err := sessionID.MarshalBinary()
+	if err != nil {
+		return utils.Session{}, err
+	}
+
+	session, err := scanSession(tx.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE session_id = ?", sessionIDBin))
+	if err != nil {
+		return utils.Session{}, err
+	}
+	if !session.Active() {
+		return utils.Session{}, ErrSessionExpired
+	}
+
+	_, err = tx.Exec("INSERT INTO refresh_tokens (token_hash, session_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		newHash, sessionIDBin, usedAt, session.ExpiresAt)
+	if err != nil {
+		return utils.Session{}, err
+	}
+
+	_, err = tx.Exec("UPDATE sessions SET last_used_at = ? WHERE session_id = ?", usedAt, sessionIDBin)
+	if err != nil {
+		return utils.Session{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.Session{}, err
+	}
+
+	session.LastUsedAt = usedAt
+	return session, nil
+}
+
+type memoryRefreshToken struct {
+	sessionID uuid.UUID
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+func (m *MemoryStore) CreateSession(session *utils.Session, refreshTokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[session.UserID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+
+	created := *session
+	created.ID = uuid.New()
+	created.CreatedAt = now()
+	created.LastUsedAt = created.CreatedAt
+	created.RevokedAt = nil
+
+	m.sessions[created.ID] = created
+	m.refreshTokens[refreshTokenHash] = memoryRefreshToken{sessionID: created.ID, expiresAt: created.ExpiresAt}
+
+	*session = created
+	return nil
+}
+
+func (m *MemoryStore) GetSession(id uuid.UUID) (utils.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return utils.Session{}, sql.ErrNoRows
+	}
+
+	return session, nil
+}
+
+func (m *MemoryStore) GetSessionsByUserID(userID uuid.UUID) ([]utils.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := []utils.Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.Active() {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+func (m *MemoryStore) RevokeSession(userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	revokedAt := now()
+	session.RevokedAt = &revokedAt
+	m.sessions[id] = session
+
+	return nil
+}
+
+func (m *MemoryStore) RotateRefreshToken(oldHash, newHash string) (utils.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[oldHash]
+	if !ok {
+		return utils.Session{}, sql.ErrNoRows
+	}
+
+	session := m.sessions[token.sessionID]
+	usedAt := now()
+
+	if token.usedAt != nil {
+		if session.RevokedAt == nil {
+			session.RevokedAt = &usedAt
+			m.sessions[session.ID] = session
+		}
+		return utils.Session{}, ErrRefreshTokenReused
+	}
+
+	if !usedAt.Before(token.expiresAt) || !session.Active() {
+		return utils.Session{}, ErrSessionExpired
+	}
+
+	token.usedAt = &usedAt
+	m.refreshTokens[oldHash] = token
+	m.refreshTokens[newHash] = memoryRefreshToken{sessionID: session.ID, expiresAt: session.ExpiresAt}
+
+	session.LastUsedAt = usedAt
+	m.sessions[session.ID] = session
+
+	return session, nil
+}
@@ -0,0 +1,513 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Returned when the task or project has been shared with the email already
+var ErrDuplicateShare = errors.New("already shared with this email")
+
+// Column order expected by scanShare
+const shareColumns = "share_id, owner_id, task_id, project_id, email, user_id, role, status, created_at, updated_at"
+
+func scanShare(row rowScanner) (utils.Share, error) {
+	var share utils.Share
+	var taskID, projectID, userID uuid.NullUUID
+
+	err := row.Scan(
+		&share.ID,
+		&share.OwnerID,
+		&taskID,
+		&projectID,
+		&share.Email,
+		&userID,
+		&share.Role,
+		&share.Status,
+		&share.CreatedAt,
+		&share.UpdatedAt,
+	)
+	if err != nil {
+		return share, err
+	}
+
+	if taskID.Valid {
+		share.TaskID = &taskID.UUID
+	}
+	if projectID.Valid {
+		share.ProjectID = &projectID.UUID
+	}
+	if userID.Valid {
+		share.UserID = &userID.UUID
+	}
+
+	return share, nil
+}
+
+func (s *sqlStore) queryShares(query string, args ...any) ([]utils.Share, error) {
+	rows, err := s.db.Query("SELECT "+shareColumns+" FROM shares WHERE "+query+" ORDER BY created_at, share_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []utils.Share{}
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+// Stores a new invitation, the generated ID and timestamps are set on share
+func (s *sqlStore) CreateShare(share *utils.Share) error {
+	shareID := uuid.New()
+	shareIDBin, err := shareID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	ownerIDBin, err := share.OwnerID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	taskIDBin, err := nullableUUID(share.TaskID)
+	if err != nil {
+		return err
+	}
+
+	projectIDBin, err := nullableUUID(share.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = s.db.Exec("INSERT INTO shares (share_id, owner_id, task_id, project_id, email, role, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		shareIDBin, ownerIDBin, taskIDBin, projectIDBin, share.Email, share.Role, share.Status, createdAt, createdAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateShare
+	} else if err != nil {
+		return err
+	}
+
+	share.ID = shareID
+	share.CreatedAt = createdAt
+	share.UpdatedAt = createdAt
+
+	return nil
+}
+
+// Shares are looked up by ID alone, the handlers check whether the caller
+// is the owner or the invited user
+func (s *sqlStore) GetShare(id uuid.UUID) (utils.Share, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Share{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+shareColumns+" FROM shares WHERE share_id = ?", idBin)
+
+	return scanShare(row)
+}
+
+func (s *sqlStore) GetTaskShares(ownerID, taskID uuid.UUID) ([]utils.Share, error) {
+	ownerIDBin, err := ownerID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryShares("owner_id = ? AND task_id = ?", ownerIDBin, taskIDBin)
+}
+
+func (s *sqlStore) GetProjectShares(ownerID, projectID uuid.UUID) ([]utils.Share, error) {
+	ownerIDBin, err := ownerID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDBin, err := projectID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryShares("owner_id = ? AND project_id = ?", ownerIDBin, projectIDBin)
+}
+
+// Lists the pending invitations sent to the email
+func (s *sqlStore) GetInvitations(email string) ([]utils.Share, error) {
+	return s.queryShares("email = ? AND status = ?", utils.NormalizeEmail(email), utils.SharePending)
+}
+
+// Lists the shares the user has accepted
+func (s *sqlStore) GetSharedWithUser(userID uuid.UUID) ([]utils.Share, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryShares("user_id = ? AND status = ?", userIDBin, utils.ShareAccepted)
+}
+
+// Saves the role, status and user of the share
+func (s *sqlStore) UpdateShare(id uuid.UUID, share utils.Share) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := nullableUUID(share.UserID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE shares SET role = ?, status = ?, user_id = ?, updated_at = ? WHERE share_id = ?",
+		share.Role, share.Status, userIDBin, now(), idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+func (s *sqlStore) DeleteShare(ownerID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	ownerIDBin, err := ownerID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("DELETE FROM shares WHERE share_id = ? AND owner_id = ?", idBin, ownerIDBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Returns the task when the user owns it or has accepted a share of it or of
+// its project, with the role they have. Tasks in a workspace are only
+// accessible to its members, also to the one who created it. Other tasks
+// are sql.ErrNoRows.
+func (s *sqlStore) GetAccessibleTask(userID, taskID uuid.UUID) (utils.Task, utils.ShareRole, error) {
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return utils.Task{}, "", err
+	}
+
+	task, err := scanTask(s.db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE task_id = ?", taskIDBin))
+	if err != nil {
+		return task, "", err
+	}
+
+	role := utils.RoleOwner
+	if task.WorkspaceID != nil {
+		member, err := s.GetWorkspaceMember(*task.WorkspaceID, userID)
+		if err != nil {
+			return utils.Task{}, "", err
+		}
+
+		role = member.Role.TaskRole(task.UserID == userID)
+	} else if task.UserID != userID {
+		shares, err := s.GetSharedWithUser(userID)
+		if err != nil {
+			return task, "", err
+		}
+
+		if role = shareRole(shares, task); role == "" {
+			return utils.Task{}, "", sql.ErrNoRows
+		}
+	}
+
+	tasks := []utils.Task{task}
+	if err := loadTaskDetails(s.db, tasks); err != nil {
+		return task, "", err
+	}
+
+	return tasks[0], role, nil
+}
+
+// Lists the tasks of other users shared with the user directly or through
+// their project, ordered by deadline
+func (s *sqlStore) GetSharedTasks(userID uuid.UUID) ([]utils.SharedTask, error) {
+	shares, err := s.GetSharedWithUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	// The users own tasks in a project shared with them are not shared tasks
+	rows, err := s.db.Query("SELECT "+taskColumns+" FROM tasks WHERE user_id <> ? AND ("+
+		"task_id IN (SELECT task_id FROM shares WHERE user_id = ? AND status = ? AND task_id IS NOT NULL) OR "+
+		"project_id IN (SELECT project_id FROM shares WHERE user_id = ? AND status = ? AND project_id IS NOT NULL)) "+
+		"ORDER BY deadline, task_id",
+		userIDBin, userIDBin, utils.ShareAccepted, userIDBin, utils.ShareAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadTaskDetails(s.db, tasks); err != nil {
+		return nil, err
+	}
+
+	return sharedTasks(shares, tasks), nil
+}
+
+// The best role the accepted shares give on the task, empty for none
+func shareRole(shares []utils.Share, task utils.Task) utils.ShareRole {
+	var role utils.ShareRole
+	for _, share := range shares {
+		matches := (share.TaskID != nil && *share.TaskID == task.ID) ||
+			(share.ProjectID != nil && task.ProjectID != nil && *share.ProjectID == *task.ProjectID)
+
+		if matches && share.Role.Allows(role) {
+			role = share.Role
+		}
+	}
+
+	return role
+}
+
+func sharedTasks(shares []utils.Share, tasks []utils.Task) []utils.SharedTask {
+	shared := []utils.SharedTask{}
+	for _, task := range tasks {
+		shared = append(shared, utils.SharedTask{Task: task, Role: shareRole(shares, task)})
+	}
+
+	return shared
+}
+
+func (m *MemoryStore) shareTaken(share *utils.Share) bool {
+	for _, existing := range m.shares {
+		if existing.Email != share.Email {
+			continue
+		}
+		if share.TaskID != nil && existing.TaskID != nil && *existing.TaskID == *share.TaskID {
+			return true
+		}
+		if share.ProjectID != nil && existing.ProjectID != nil && *existing.ProjectID == *share.ProjectID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the matching shares ordered by creation time
+func (m *MemoryStore) filterShares(match func(utils.Share) bool) []utils.Share {
+	shares := []utils.Share{}
+	for _, share := range m.shares {
+		if match(share) {
+			shares = append(shares, share)
+		}
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		if !shares[i].CreatedAt.Equal(shares[j].CreatedAt) {
+			return shares[i].CreatedAt.Before(shares[j].CreatedAt)
+		}
+		return shares[i].ID.String() < shares[j].ID.String()
+	})
+
+	return shares
+}
+
+func (m *MemoryStore) CreateShare(share *utils.Share) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[share.OwnerID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+	if share.TaskID != nil {
+		if _, ok := m.tasks[*share.TaskID]; !ok {
+			return errors.New("foreign key constraint failed: unknown task")
+		}
+	}
+	if share.ProjectID != nil {
+		if _, ok := m.projects[*share.ProjectID]; !ok {
+			return errors.New("foreign key constraint failed: unknown project")
+		}
+	}
+
+	if m.shareTaken(share) {
+		return ErrDuplicateShare
+	}
+
+	created := *share
+	created.ID = uuid.New()
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+
+	m.shares[created.ID] = created
+
+	*share = created
+	return nil
+}
+
+func (m *MemoryStore) GetShare(id uuid.UUID) (utils.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	share, ok := m.shares[id]
+	if !ok {
+		return utils.Share{}, sql.ErrNoRows
+	}
+
+	return share, nil
+}
+
+func (m *MemoryStore) GetTaskShares(ownerID, taskID uuid.UUID) ([]utils.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterShares(func(share utils.Share) bool {
+		return share.OwnerID == ownerID && share.TaskID != nil && *share.TaskID == taskID
+	}), nil
+}
+
+func (m *MemoryStore) GetProjectShares(ownerID, projectID uuid.UUID) ([]utils.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterShares(func(share utils.Share) bool {
+		return share.OwnerID == ownerID && share.ProjectID != nil && *share.ProjectID == projectID
+	}), nil
+}
+
+func (m *MemoryStore) GetInvitations(email string) ([]utils.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	email = utils.NormalizeEmail(email)
+	return m.filterShares(func(share utils.Share) bool {
+		return share.Email == email && share.Status == utils.SharePending
+	}), nil
+}
+
+func (m *MemoryStore) GetSharedWithUser(userID uuid.UUID) ([]utils.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sharedWithUser(userID), nil
+}
+
+func (m *MemoryStore) sharedWithUser(userID uuid.UUID) []utils.Share {
+	return m.filterShares(func(share utils.Share) bool {
+		return share.UserID != nil && *share.UserID == userID && share.Status == utils.ShareAccepted
+	})
+}
+
+func (m *MemoryStore) UpdateShare(id uuid.UUID, share utils.Share) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.shares[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if share.UserID != nil {
+		if _, ok := m.users[*share.UserID]; !ok {
+			return errors.New("foreign key constraint failed: unknown user")
+		}
+	}
+
+	existing.Role = share.Role
+	existing.Status = share.Status
+	existing.UserID = share.UserID
+	existing.UpdatedAt = now()
+
+	m.shares[id] = existing
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteShare(ownerID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	share, ok := m.shares[id]
+	if !ok || share.OwnerID != ownerID {
+		return sql.ErrNoRows
+	}
+
+	delete(m.shares, id)
+
+	return nil
+}
+
+func (m *MemoryStore) GetAccessibleTask(userID, taskID uuid.UUID) (utils.Task, utils.ShareRole, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return utils.Task{}, "", sql.ErrNoRows
+	}
+
+	role := utils.RoleOwner
+	if task.WorkspaceID != nil {
+		member, ok := m.members[memoryMember{*task.WorkspaceID, userID}]
+		if !ok {
+			return utils.Task{}, "", sql.ErrNoRows
+		}
+
+		role = member.Role.TaskRole(task.UserID == userID)
+	} else if task.UserID != userID {
+		if role = shareRole(m.sharedWithUser(userID), task); role == "" {
+			return utils.Task{}, "", sql.ErrNoRows
+		}
+	}
+
+	return m.withDetails(task), role, nil
+}
+
+func (m *MemoryStore) GetSharedTasks(userID uuid.UUID) ([]utils.SharedTask, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shares := m.sharedWithUser(userID)
+	tasks := m.sortedTasks(func(task utils.Task) bool {
+		return task.UserID != userID && shareRole(shares, task) != ""
+	})
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Deadline.Before(tasks[j].Deadline)
+	})
+
+	return sharedTasks(shares, tasks), nil
+}
+
+// Removes the shares of the user, both their own and the ones they accepted
+func (m *MemoryStore) deleteUserShares(userID uuid.UUID) {
+	for id, share := range m.shares {
+		if share.OwnerID == userID || (share.UserID != nil && *share.UserID == userID) {
+			delete(m.shares, id)
+		}
+	}
+}
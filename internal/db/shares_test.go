@@ -0,0 +1,67 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func TestGetSharedTasksSkipsOwnTasks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		ownerID := createTestUser(t, store, "owner@tasklist.com")
+		userID := createTestUser(t, store, "user@tasklist.com")
+
+		project := utils.Project{Name: "School", UserID: ownerID}
+		if err := store.CreateProject(&project); err != nil {
+			t.Fatal(err)
+		}
+
+		shared := createTestTask(t, store, ownerID, "Shared task")
+		shared.ProjectID = &project.ID
+		if err := store.UpdateTask(ownerID, shared.ID, shared); err != nil {
+			t.Fatal(err)
+		}
+		createTestTask(t, store, ownerID, "Unshared task")
+
+		// A task of the user in the project shared with them is still their own
+		own := createTestTask(t, store, userID, "Own task")
+		own.ProjectID = &project.ID
+		if err := store.UpdateTask(userID, own.ID, own); err != nil {
+			t.Fatal(err)
+		}
+
+		share := utils.Share{OwnerID: ownerID, ProjectID: &project.ID, Email: "user@tasklist.com", Role: utils.RoleViewer, Status: utils.SharePending}
+		if err := store.CreateShare(&share); err != nil {
+			t.Fatal(err)
+		}
+
+		sharedIDs := func() []uuid.UUID {
+			tasks, err := store.GetSharedTasks(userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []uuid.UUID
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+			return ids
+		}
+
+		if got := sharedIDs(); len(got) != 0 {
+			t.Errorf("tasks shared by a pending invitation %v", got)
+		}
+
+		share.Status = utils.ShareAccepted
+		share.UserID = &userID
+		if err := store.UpdateShare(share.ID, share); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := sharedIDs(); !slices.Equal(got, []uuid.UUID{shared.ID}) {
+			t.Errorf("shared tasks %v, want only %s", got, shared.ID)
+		}
+	})
+}
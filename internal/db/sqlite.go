@@ -0,0 +1,39 @@
+package db
+
+import (
+	"database/sql"
+	"net/url"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Storage backed by a local SQLite database file, meant for development
+// and test environments where running a MySQL server is not practical
+type SQLiteStore struct {
+	sqlStore
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// Foreign keys are off by default in SQLite, they are needed for
+	// deleting a users tasks along with the user
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// A single connection avoids "database is locked" errors between writers
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStore{
+		sqlStore{db: db, dialect: "sqlite"},
+	}, nil
+}
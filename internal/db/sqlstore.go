@@ -0,0 +1,627 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Storage implementation shared by the database/sql backed stores,
+// the queries are written so that they run on both MySQL and SQLite
+type sqlStore struct {
+	db *sql.DB
+	// "mysql" or "sqlite", selects the migrations and locking strategy
+	dialect string
+}
+
+// Brings the schema up to date by applying the pending migrations
+func (s *sqlStore) InitDB() error {
+	return s.MigrateUp()
+}
+
+// Column order expected by scanTask
+const taskColumns = "task_id, title, description, deadline, all_day, created_at, updated_at, user_id, status, completed_at, project_id, recurrence, series_id, occurrence, version, workspace_id"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (utils.Task, error) {
+	var task utils.Task
+	var completedAt sql.NullTime
+	var projectID, seriesID, workspaceID uuid.NullUUID
+
+	err := row.Scan(
+		&task.ID,
+		&task.Title,
+		&task.Description,
+		&task.Deadline,
+		&task.AllDay,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+		&task.UserID,
+		&task.Status,
+		&completedAt,
+		&projectID,
+		&task.Recurrence,
+		&seriesID,
+		&task.Occurrence,
+		&task.Version,
+		&workspaceID,
+	)
+	if err != nil {
+		return task, err
+	}
+
+	if completedAt.Valid {
+		task.CompletedAt = &completedAt.Time
+	}
+	if projectID.Valid {
+		task.ProjectID = &projectID.UUID
+	}
+	if seriesID.Valid {
+		task.SeriesID = &seriesID.UUID
+	}
+	if workspaceID.Valid {
+		task.WorkspaceID = &workspaceID.UUID
+	}
+
+	return task, nil
+}
+
+func scanTasks(rows *sql.Rows) ([]utils.Task, error) {
+	defer rows.Close()
+
+	var tasks []utils.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (m *sqlStore) GetTasks() ([]utils.Task, error) {
+	rows, err := m.db.Query("SELECT " + taskColumns + " FROM tasks")
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, loadTaskDetails(m.db, tasks)
+}
+
+// Lists one page of the users personal tasks, the ones in workspaces are
+// listed with GetWorkspaceTasks
+func (m *sqlStore) GetTasksByUserID(userID uuid.UUID, filter utils.TaskFilter) (utils.TaskPage, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.TaskPage{}, err
+	}
+
+	return m.queryTaskPage("user_id = ? AND workspace_id IS NULL", []any{userIDBin}, filter)
+}
+
+// Lists one page of the tasks matching scope. Filtering, ordering and the
+// keyset pagination are all done in the query, one row past the limit is
+// fetched to know whether another page follows.
+func (m *sqlStore) queryTaskPage(scope string, scopeArgs []any, filter utils.TaskFilter) (utils.TaskPage, error) {
+	filter = normalizeTaskFilter(filter)
+
+	cursor, err := decodeTaskCursor(filter)
+	if err != nil {
+		return utils.TaskPage{}, err
+	}
+
+	queryStr := "SELECT " + taskColumns + " FROM tasks WHERE " + scope
+	args := scopeArgs
+
+	if len(filter.Statuses) > 0 {
+		queryStr += " AND status IN (?" + strings.Repeat(", ?", len(filter.Statuses)-1) + ")"
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	if filter.ProjectID != nil {
+		projectIDBin, err := filter.ProjectID.MarshalBinary()
+		if err != nil {
+			return utils.TaskPage{}, err
+		}
+		queryStr += " AND project_id = ?"
+		args = append(args, projectIDBin)
+	}
+
+	if len(filter.Tags) > 0 {
+		cond, tagArgs := taskTagCondition(filter.Tags, filter.TagMatchAll)
+		queryStr += " AND " + cond
+		args = append(args, tagArgs...)
+	}
+
+	if filter.DeadlineBefore != nil {
+		queryStr += " AND deadline < ?"
+		args = append(args, filter.DeadlineBefore.UTC())
+	}
+	if filter.DeadlineAfter != nil {
+		queryStr += " AND deadline > ?"
+		args = append(args, filter.DeadlineAfter.UTC())
+	}
+
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		queryStr += " AND (title LIKE ? ESCAPE '!' OR description LIKE ? ESCAPE '!')"
+		args = append(args, pattern, pattern)
+	}
+
+	// The sort field is one of the TaskSort constants, which are also the column names
+	column := string(filter.Sort)
+	comparison, direction := ">", "ASC"
+	if filter.Descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	if cursor != nil {
+		idBin, err := cursor.ID.MarshalBinary()
+		if err != nil {
+			return utils.TaskPage{}, err
+		}
+
+		queryStr += " AND (" + column + " " + comparison + " ? OR (" + column + " = ? AND task_id " + comparison + " ?))"
+		args = append(args, cursor.sqlValue(), cursor.sqlValue(), idBin)
+	}
+
+	queryStr += " ORDER BY " + column + " " + direction + ", task_id " + direction + " LIMIT ?"
+	args = append(args, filter.Limit+1)
+
+	rows, err := m.db.Query(queryStr, args...)
+	if err != nil {
+		return utils.TaskPage{}, err
+	}
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return utils.TaskPage{}, err
+	}
+
+	page := newTaskPage(tasks, filter)
+	return page, loadTaskDetails(m.db, page.Tasks)
+}
+
+// Escapes the LIKE wildcards of s, the queries use ! as the escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Task operations are scoped to the owning user, a task of another user
+// is reported as sql.ErrNoRows just like a task that does not exist
+func (m *sqlStore) GetTaskById(userID, id uuid.UUID) (utils.Task, error) {
+	var task utils.Task
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return task, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return task, err
+	}
+
+	row := m.db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE task_id = ? AND user_id = ?", idBin, userIDBin)
+
+	task, err = scanTask(row)
+	if err != nil {
+		return task, err
+	}
+
+	tasks := []utils.Task{task}
+	if err := loadTaskDetails(m.db, tasks); err != nil {
+		return task, err
+	}
+
+	return tasks[0], nil
+}
+
+func (m *sqlStore) CreateTask(task *utils.Task) (*utils.Task, error) {
+	queryStr := `INSERT INTO tasks (task_id, title, description, deadline, all_day, created_at, updated_at, user_id, status, completed_at, project_id, recurrence, series_id, occurrence, version, workspace_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	taskID := uuid.New()
+	taskIDBin, err := taskID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDBin, err := task.UserID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	projectIDBin, err := nullableUUID(task.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	seriesIDBin, err := nullableUUID(task.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceIDBin, err := nullableUUID(task.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Status == "" {
+		task.Status = utils.StatusTodo
+	}
+	if task.Occurrence == 0 {
+		task.Occurrence = 1
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("foreign key constraint failed: unknown user")
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Times are stored in UTC so that they compare correctly in SQLite too
+	_, err = tx.Exec(queryStr, taskIDBin, task.Title, task.Description, task.Deadline.UTC(), task.AllDay, now(), now(), userIDBin, task.Status, utcTime(task.CompletedAt), projectIDBin,
+		task.Recurrence, seriesIDBin, task.Occurrence, version, workspaceIDBin)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateOccurrence
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := setTaskTags(tx, userIDBin, taskIDBin, task.Tags); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	created, err := m.GetTaskById(task.UserID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (s *sqlStore) DeleteTask(userID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	// Tasks in workspaces are not synced, they leave no tombstone
+	if err := tombstoneTasks(tx, version, userIDBin, "task_id = ? AND workspace_id IS NULL", idBin); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM tasks WHERE task_id = ? AND user_id = ?", idBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Saves the task, its tags are replaced unless task.Tags is nil. task.Version
+// must be the version the task was read at, ErrVersionConflict is returned
+// if it has been changed since.
+func (s *sqlStore) UpdateTask(userID, id uuid.UUID, task utils.Task) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	projectIDBin, err := nullableUUID(task.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	seriesIDBin, err := nullableUUID(task.SeriesID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE tasks SET title = ?, description = ?, deadline = ?, all_day = ?, status = ?, completed_at = ?, project_id = ?, recurrence = ?, series_id = ?, occurrence = ?, updated_at = ?, version = ? WHERE task_id = ? AND user_id = ? AND version = ?",
+		task.Title, task.Description, task.Deadline.UTC(), task.AllDay, task.Status, utcTime(task.CompletedAt), projectIDBin, task.Recurrence, seriesIDBin, task.Occurrence, now(), version, idBin, userIDBin, task.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		// Either the task is gone or it was saved at another version
+		if err := checkTaskOwner(tx, userIDBin, idBin); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+
+	if task.Tags != nil {
+		if err := setTaskTags(tx, userIDBin, idBin, task.Tags); err != nil {
+			return err
+		}
+	}
+
+	if err := rescheduleReminders(tx, idBin, task.Deadline); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Fills in the tags and checklists of the tasks
+func loadTaskDetails(q dbtx, tasks []utils.Task) error {
+	if err := loadTaskTags(q, tasks); err != nil {
+		return err
+	}
+
+	return loadTaskChecklists(q, tasks)
+}
+
+// Optional time in UTC, nil is stored as NULL
+func utcTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+// Binary form of an optional UUID, nil is stored as NULL
+func nullableUUID(id *uuid.UUID) (any, error) {
+	if id == nil {
+		return nil, nil
+	}
+
+	return id.MarshalBinary()
+}
+
+// Reports whether err is a unique constraint violation of either database
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_DUP_ENTRY
+		return mysqlErr.Number == 1062
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+
+	return false
+}
+
+// Turns a statement that matched no rows into sql.ErrNoRows
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Column order expected by scanUser
+const userColumns = "user_id, username, email, password, timezone, role, created_at, updated_at, version"
+
+func scanUser(row rowScanner) (utils.User, error) {
+	var user utils.User
+
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.HashedPw,
+		&user.TimeZone,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+	)
+
+	return user, err
+}
+
+func (m *sqlStore) CreateUser(user *utils.User) error {
+	queryStr := `INSERT INTO users (user_id, username, email, password, timezone, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?) `
+
+	userID, err := uuid.New().MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if user.TimeZone == "" {
+		user.TimeZone = "UTC"
+	}
+	if user.Role == "" {
+		user.Role = utils.UserRegular
+	}
+
+	_, err = m.db.Exec(queryStr, userID, user.Name, user.Email, user.HashedPw, user.TimeZone, user.Role, now(), now())
+	if isUniqueViolation(err) {
+		return ErrDuplicateEmail
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *sqlStore) GetUsers() ([]utils.User, error) {
+	var users []utils.User
+
+	rows, err := m.db.Query("SELECT " + userColumns + " FROM users")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (m *sqlStore) GetUserById(id uuid.UUID) (utils.User, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.User{}, err
+	}
+
+	row := m.db.QueryRow("SELECT "+userColumns+" FROM users WHERE user_id = ?", idBin)
+
+	return scanUser(row)
+}
+
+func (m *sqlStore) GetUserByEmail(email string) (utils.User, error) {
+	row := m.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email)
+
+	return scanUser(row)
+}
+
+// Deletes the user, the foreign keys delete everything they own with them.
+// Their tasks and projects in workspaces are handed over to another member first.
+func (s *sqlStore) DeleteUser(id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := handOverWorkspaces(tx, idBin); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM users WHERE user_id = ?", idBin)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Saves the user, user.Version must be the version the user was read at.
+// ErrVersionConflict is returned if the user has been updated since.
+func (s *sqlStore) UpdateUser(id uuid.UUID, user utils.User) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE users SET username = ?, email = ?, password = ?, timezone = ?, updated_at = ?, version = version + 1 WHERE user_id = ? AND version = ?",
+		user.Name, user.Email, user.HashedPw, user.TimeZone, now(), idBin, user.Version)
+	if isUniqueViolation(err) {
+		return ErrDuplicateEmail
+	} else if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		// Either the user is gone or they were saved at another version
+		var found int
+		if err := s.db.QueryRow("SELECT 1 FROM users WHERE user_id = ?", idBin).Scan(&found); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// Changes the role of the user, which also changes their version
+func (s *sqlStore) SetUserRole(id uuid.UUID, role utils.UserRole) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE users SET role = ?, updated_at = ?, version = version + 1 WHERE user_id = ?", role, now(), idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
@@ -0,0 +1,300 @@
+package db
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+var (
+	// Returned for sync tokens that are malformed
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	// Returned when a task or user is saved with a version other than its
+	// current one, it was changed after it was read
+	ErrVersionConflict = errors.New("changed since it was read")
+)
+
+// Sync tokens hold the last version a client has received, clients treat them as opaque
+type syncToken struct {
+	Version int64 `json:"v"`
+}
+
+func encodeSyncToken(version int64) string {
+	b, _ := json.Marshal(syncToken{Version: version})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decodes the version after which changes are listed, ok is false for an
+// empty token which asks for every task
+func decodeSyncToken(token string) (version int64, ok bool, err error) {
+	if token == "" {
+		return 0, false, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, false, ErrInvalidSyncToken
+	}
+
+	var t syncToken
+	if err := json.Unmarshal(b, &t); err != nil || t.Version < 0 {
+		return 0, false, ErrInvalidSyncToken
+	}
+
+	return t.Version, true, nil
+}
+
+func normalizeSyncLimit(limit int) int {
+	if limit <= 0 {
+		return utils.DefaultSyncLimit
+	}
+	return min(limit, utils.MaxSyncLimit)
+}
+
+// The version the changes up to are returned for. When there are more than
+// limit changes the page ends at the version of the last one that fits, the
+// changes sharing that version are all included so that none is skipped.
+func syncPageEnd(versions []int64, limit int, current int64) (upTo int64, hasMore bool) {
+	if len(versions) > limit {
+		return versions[limit-1], true
+	}
+	return current, false
+}
+
+// Takes the next sync version of the user for the task changes of the
+// transaction. Called first in the transaction: the users row stays locked
+// until it ends, so the changes of a user commit in version order and
+// writers always lock the user before any of their tasks.
+func nextSyncVersion(q dbtx, userIDBin []byte) (int64, error) {
+	result, err := q.Exec("UPDATE users SET sync_version = sync_version + 1 WHERE user_id = ?", userIDBin)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return 0, err
+	}
+
+	var version int64
+	err = q.QueryRow("SELECT sync_version FROM users WHERE user_id = ?", userIDBin).Scan(&version)
+	return version, err
+}
+
+// Marks the tasks of the user matching cond as changed at version
+func touchTasks(q dbtx, version int64, userIDBin []byte, cond string, args ...any) error {
+	_, err := q.Exec("UPDATE tasks SET version = ? WHERE user_id = ? AND "+cond, append([]any{version, userIDBin}, args...)...)
+	return err
+}
+
+// Leaves a tombstone for the tasks of the user matching cond, called before they are deleted
+func tombstoneTasks(q dbtx, version int64, userIDBin []byte, cond string, args ...any) error {
+	_, err := q.Exec("INSERT INTO task_tombstones (task_id, user_id, version, deleted_at) SELECT task_id, user_id, ?, ? FROM tasks WHERE user_id = ? AND "+cond,
+		append([]any{version, now(), userIDBin}, args...)...)
+	return err
+}
+
+// Lists the changes to the personal tasks of the user after the since token,
+// every task when since is empty. Tasks in workspaces are not synced. Deleted tasks are only listed for a token, a
+// client without one has nothing to delete.
+func (s *sqlStore) GetTaskChanges(userID uuid.UUID, since string, limit int) (utils.SyncChanges, error) {
+	changes := utils.SyncChanges{Tasks: []utils.Task{}, Deleted: []utils.TaskTombstone{}}
+
+	sinceVersion, hasToken, err := decodeSyncToken(since)
+	if err != nil {
+		return changes, err
+	}
+	if !hasToken {
+		// Tasks that existed before syncing was added are at version 0
+		sinceVersion = -1
+	}
+	limit = normalizeSyncLimit(limit)
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return changes, err
+	}
+
+	// Read in one transaction so that the page and the token agree
+	tx, err := s.db.Begin()
+	if err != nil {
+		return changes, err
+	}
+	defer tx.Rollback()
+
+	var current int64
+	if err := tx.QueryRow("SELECT sync_version FROM users WHERE user_id = ?", userIDBin).Scan(&current); err != nil {
+		return changes, err
+	}
+
+	queryStr := "SELECT version FROM tasks WHERE user_id = ? AND workspace_id IS NULL AND version > ?"
+	args := []any{userIDBin, sinceVersion}
+	if hasToken {
+		queryStr += " UNION ALL SELECT version FROM task_tombstones WHERE user_id = ? AND version > ?"
+		args = append(args, userIDBin, sinceVersion)
+	}
+	queryStr += " ORDER BY version LIMIT ?"
+	args = append(args, limit+1)
+
+	versions, err := queryVersions(tx, queryStr, args...)
+	if err != nil {
+		return changes, err
+	}
+
+	upTo, hasMore := syncPageEnd(versions, limit, current)
+
+	rows, err := tx.Query("SELECT "+taskColumns+" FROM tasks WHERE user_id = ? AND workspace_id IS NULL AND version > ? AND version <= ? ORDER BY version, task_id",
+		userIDBin, sinceVersion, upTo)
+	if err != nil {
+		return changes, err
+	}
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return changes, err
+	}
+	if err := loadTaskDetails(tx, tasks); err != nil {
+		return changes, err
+	}
+	if tasks != nil {
+		changes.Tasks = tasks
+	}
+
+	if hasToken {
+		rows, err := tx.Query("SELECT task_id, version, deleted_at FROM task_tombstones WHERE user_id = ? AND version > ? AND version <= ? ORDER BY version, task_id",
+			userIDBin, sinceVersion, upTo)
+		if err != nil {
+			return changes, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tombstone utils.TaskTombstone
+			if err := rows.Scan(&tombstone.TaskID, &tombstone.Version, &tombstone.DeletedAt); err != nil {
+				return changes, err
+			}
+			changes.Deleted = append(changes.Deleted, tombstone)
+		}
+		if err := rows.Err(); err != nil {
+			return changes, err
+		}
+	}
+
+	changes.Token = encodeSyncToken(upTo)
+	changes.HasMore = hasMore
+
+	return changes, tx.Commit()
+}
+
+func queryVersions(q dbtx, query string, args ...any) ([]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// Tombstone of a task in the memory store
+type memoryTombstone struct {
+	userID uuid.UUID
+	utils.TaskTombstone
+}
+
+// Takes the next sync version of the user like nextSyncVersion does
+func (m *MemoryStore) nextSyncVersion(userID uuid.UUID) int64 {
+	m.syncVersions[userID]++
+	return m.syncVersions[userID]
+}
+
+func (m *MemoryStore) touchTask(id uuid.UUID, version int64) {
+	if task, ok := m.tasks[id]; ok {
+		task.Version = version
+		m.tasks[id] = task
+	}
+}
+
+func (m *MemoryStore) tombstoneTask(task utils.Task, version int64) {
+	m.tombstones[task.ID] = memoryTombstone{
+		userID:        task.UserID,
+		TaskTombstone: utils.TaskTombstone{TaskID: task.ID, Version: version, DeletedAt: now()},
+	}
+}
+
+func (m *MemoryStore) GetTaskChanges(userID uuid.UUID, since string, limit int) (utils.SyncChanges, error) {
+	changes := utils.SyncChanges{Tasks: []utils.Task{}, Deleted: []utils.TaskTombstone{}}
+
+	sinceVersion, hasToken, err := decodeSyncToken(since)
+	if err != nil {
+		return changes, err
+	}
+	if !hasToken {
+		sinceVersion = -1
+	}
+	limit = normalizeSyncLimit(limit)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return changes, sql.ErrNoRows
+	}
+
+	var versions []int64
+	for _, task := range m.tasks {
+		if task.UserID == userID && task.WorkspaceID == nil && task.Version > sinceVersion {
+			versions = append(versions, task.Version)
+		}
+	}
+	if hasToken {
+		for _, tombstone := range m.tombstones {
+			if tombstone.userID == userID && tombstone.Version > sinceVersion {
+				versions = append(versions, tombstone.Version)
+			}
+		}
+	}
+	slices.Sort(versions)
+
+	upTo, hasMore := syncPageEnd(versions, limit, m.syncVersions[userID])
+
+	changes.Tasks = m.sortedTasks(func(task utils.Task) bool {
+		return task.UserID == userID && task.WorkspaceID == nil && task.Version > sinceVersion && task.Version <= upTo
+	})
+	if changes.Tasks == nil {
+		changes.Tasks = []utils.Task{}
+	}
+	slices.SortStableFunc(changes.Tasks, func(a, b utils.Task) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	if hasToken {
+		for _, tombstone := range m.tombstones {
+			if tombstone.userID == userID && tombstone.Version > sinceVersion && tombstone.Version <= upTo {
+				changes.Deleted = append(changes.Deleted, tombstone.TaskTombstone)
+			}
+		}
+		slices.SortFunc(changes.Deleted, func(a, b utils.TaskTombstone) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+	}
+
+	changes.Token = encodeSyncToken(upTo)
+	changes.HasMore = hasMore
+
+	return changes, nil
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Runs the test against a fresh memory store and a fresh SQLite database
+func forEachStore(t *testing.T, test func(t *testing.T, store Storage)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasklist.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.db.Close() })
+
+		if err := store.InitDB(); err != nil {
+			t.Fatal(err)
+		}
+		test(t, store)
+	})
+}
+
+func createTestUser(t *testing.T, store Storage, email string) uuid.UUID {
+	t.Helper()
+
+	if err := store.CreateUser(&utils.User{Name: "example", Email: email, HashedPw: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := store.GetUserByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user.ID
+}
+
+func createTestTask(t *testing.T, store Storage, userID uuid.UUID, title string) utils.Task {
+	t.Helper()
+
+	task, err := store.CreateTask(&utils.Task{Title: title, Deadline: time.Date(2030, 12, 1, 0, 0, 0, 0, time.UTC), UserID: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *task
+}
+
+func getChanges(t *testing.T, store Storage, userID uuid.UUID, since string, limit int) utils.SyncChanges {
+	t.Helper()
+
+	changes, err := store.GetTaskChanges(userID, since, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return changes
+}
+
+func tokenVersion(t *testing.T, token string) int64 {
+	t.Helper()
+
+	version, ok, err := decodeSyncToken(token)
+	if err != nil || !ok {
+		t.Fatalf("token %q: %v", token, err)
+	}
+
+	return version
+}
+
+func changedIDs(changes utils.SyncChanges) (tasks, deleted []uuid.UUID) {
+	for _, task := range changes.Tasks {
+		tasks = append(tasks, task.ID)
+	}
+	for _, tombstone := range changes.Deleted {
+		deleted = append(deleted, tombstone.TaskID)
+	}
+	return tasks, deleted
+}
+
+func TestGetTaskChangesTombstones(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		userID := createTestUser(t, store, "example@tasklist.com")
+		kept := createTestTask(t, store, userID, "Kept task")
+		deleted := createTestTask(t, store, userID, "Deleted task")
+
+		first := getChanges(t, store, userID, "", 0)
+		if tasks, _ := changedIDs(first); len(tasks) != 2 || len(first.Deleted) != 0 || first.HasMore {
+			t.Fatalf("first sync %+v", first)
+		}
+
+		if err := store.DeleteTask(userID, deleted.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		second := getChanges(t, store, userID, first.Token, 0)
+		tasks, tombstones := changedIDs(second)
+		if len(tasks) != 0 || !slices.Equal(tombstones, []uuid.UUID{deleted.ID}) {
+			t.Fatalf("sync after delete lists tasks %v, deleted %v", tasks, tombstones)
+		}
+		if tombstone := second.Deleted[0]; tombstone.Version <= tokenVersion(t, first.Token) || tombstone.DeletedAt.IsZero() {
+			t.Errorf("tombstone %+v", tombstone)
+		}
+
+		// A client without a token has nothing to delete
+		full := getChanges(t, store, userID, "", 0)
+		if tasks, tombstones := changedIDs(full); !slices.Equal(tasks, []uuid.UUID{kept.ID}) || len(tombstones) != 0 {
+			t.Errorf("full sync lists tasks %v, deleted %v", tasks, tombstones)
+		}
+
+		// Deleting a task again leaves no second tombstone
+		if err := store.DeleteTask(userID, deleted.ID); err == nil {
+			t.Error("deleted task was deleted again")
+		}
+		if third := getChanges(t, store, userID, second.Token, 0); len(third.Tasks) != 0 || len(third.Deleted) != 0 {
+			t.Errorf("sync after deleting again %+v", third)
+		}
+	})
+}
+
+func TestGetTaskChangesPaging(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		userID := createTestUser(t, store, "example@tasklist.com")
+		otherID := createTestUser(t, store, "other@tasklist.com")
+
+		start := getChanges(t, store, userID, "", 0)
+
+		var tasks []utils.Task
+		for _, title := range []string{"First task", "Second task", "Third task", "Fourth task", "Fifth task"} {
+			tasks = append(tasks, createTestTask(t, store, userID, title))
+		}
+		createTestTask(t, store, otherID, "Other users task")
+
+		// The update moves the first task after the others, the second one leaves a tombstone
+		updated, err := store.GetTaskById(userID, tasks[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated.Title = "First task updated"
+		if err := store.UpdateTask(userID, updated.ID, updated); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.DeleteTask(userID, tasks[1].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		var gotTasks, gotDeleted []uuid.UUID
+		token := start.Token
+		for page := 0; ; page++ {
+			if page > 10 {
+				t.Fatal("paging does not end")
+			}
+
+			changes := getChanges(t, store, userID, token, 2)
+
+			since, upTo := tokenVersion(t, token), tokenVersion(t, changes.Token)
+			if upTo < since || (changes.HasMore && upTo == since) {
+				t.Fatalf("page %d: token went from version %d to %d", page, since, upTo)
+			}
+			for _, task := range changes.Tasks {
+				if task.Version <= since || task.Version > upTo {
+					t.Errorf("page %d: task at version %d, outside (%d, %d]", page, task.Version, since, upTo)
+				}
+			}
+			for _, tombstone := range changes.Deleted {
+				if tombstone.Version <= since || tombstone.Version > upTo {
+					t.Errorf("page %d: tombstone at version %d, outside (%d, %d]", page, tombstone.Version, since, upTo)
+				}
+			}
+			if n := len(changes.Tasks) + len(changes.Deleted); n > 2 {
+				t.Errorf("page %d has %d changes, limit 2", page, n)
+			}
+
+			pageTasks, pageDeleted := changedIDs(changes)
+			gotTasks = append(gotTasks, pageTasks...)
+			gotDeleted = append(gotDeleted, pageDeleted...)
+
+			token = changes.Token
+			if !changes.HasMore {
+				break
+			}
+		}
+
+		wantTasks := []uuid.UUID{tasks[2].ID, tasks[3].ID, tasks[4].ID, tasks[0].ID}
+		if !slices.Equal(gotTasks, wantTasks) {
+			t.Errorf("synced tasks %v, want %v", gotTasks, wantTasks)
+		}
+		if !slices.Equal(gotDeleted, []uuid.UUID{tasks[1].ID}) {
+			t.Errorf("synced deletions %v, want %v", gotDeleted, tasks[1].ID)
+		}
+
+		// Without new changes the token stays where it is
+		last := getChanges(t, store, userID, token, 2)
+		if len(last.Tasks) != 0 || len(last.Deleted) != 0 || last.HasMore || last.Token != token {
+			t.Errorf("sync without changes %+v, token %q", last, token)
+		}
+
+		// The changes of other users do not move the token
+		createTestTask(t, store, otherID, "Another task")
+		if changes := getChanges(t, store, userID, token, 2); changes.Token != token {
+			t.Errorf("token moved from %q to %q on another users change", token, changes.Token)
+		}
+	})
+}
+
+func TestGetTaskChangesInvalidToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		userID := createTestUser(t, store, "example@tasklist.com")
+
+		for _, token := range []string{"%%%", "bm90IGpzb24", "eyJ2IjotMX0"} {
+			if _, err := store.GetTaskChanges(userID, token, 0); !errors.Is(err, ErrInvalidSyncToken) {
+				t.Errorf("token %q: error %v, want ErrInvalidSyncToken", token, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,402 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Returned when a tag is created or renamed with the name of another tag of the user
+var ErrDuplicateTag = errors.New("tag name already in use")
+
+// Implemented by both *sql.DB and *sql.Tx
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *sqlStore) GetTags(userID uuid.UUID) ([]utils.Tag, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT tag_id, user_id, name, created_at FROM tags WHERE user_id = ? ORDER BY name", userIDBin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []utils.Tag{}
+	for rows.Next() {
+		var tag utils.Tag
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Stores a new tag, the generated ID and creation time are set on tag
+func (s *sqlStore) CreateTag(tag *utils.Tag) error {
+	userIDBin, err := tag.UserID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	created, err := insertTag(s.db, userIDBin, tag.Name)
+	if isUniqueViolation(err) {
+		return ErrDuplicateTag
+	} else if err != nil {
+		return err
+	}
+
+	created.UserID = tag.UserID
+	*tag = created
+	return nil
+}
+
+func insertTag(q dbtx, userIDBin []byte, name string) (utils.Tag, error) {
+	tag := utils.Tag{ID: uuid.New(), Name: name, CreatedAt: now()}
+	tagIDBin, err := tag.ID.MarshalBinary()
+	if err != nil {
+		return tag, err
+	}
+
+	_, err = q.Exec("INSERT INTO tags (tag_id, user_id, name, created_at) VALUES (?, ?, ?, ?)",
+		tagIDBin, userIDBin, tag.Name, tag.CreatedAt)
+
+	return tag, err
+}
+
+// Renames the tag, the tasks it is attached to keep it
+func (s *sqlStore) UpdateTag(userID, id uuid.UUID, tag utils.Tag) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE tags SET name = ? WHERE tag_id = ? AND user_id = ?", tag.Name, idBin, userIDBin)
+	if isUniqueViolation(err) {
+		return ErrDuplicateTag
+	} else if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	// The tasks list their tags by name
+	if err := touchTasks(tx, version, userIDBin, "task_id IN (SELECT task_id FROM task_tags WHERE tag_id = ?)", idBin); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Deletes the tag and detaches it from every task
+func (s *sqlStore) DeleteTag(userID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := nextSyncVersion(tx, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := touchTasks(tx, version, userIDBin, "task_id IN (SELECT task_id FROM task_tags WHERE tag_id = ?)", idBin); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM tags WHERE tag_id = ? AND user_id = ?", idBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Replaces the tags of a task with the named tags of the user, creating the missing ones
+func setTaskTags(q dbtx, userIDBin, taskIDBin []byte, names []string) error {
+	if _, err := q.Exec("DELETE FROM task_tags WHERE task_id = ?", taskIDBin); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var tagIDBin []byte
+		err := q.QueryRow("SELECT tag_id FROM tags WHERE user_id = ? AND name = ?", userIDBin, name).Scan(&tagIDBin)
+		if errors.Is(err, sql.ErrNoRows) {
+			tag, err := insertTag(q, userIDBin, name)
+			if err != nil {
+				return err
+			}
+			if tagIDBin, err = tag.ID.MarshalBinary(); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := q.Exec("INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)", taskIDBin, tagIDBin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fills in the tags of the tasks with a single query
+func loadTaskTags(q dbtx, tasks []utils.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	index := make(map[uuid.UUID]int, len(tasks))
+	args := make([]any, 0, len(tasks))
+	for i := range tasks {
+		tasks[i].Tags = []string{}
+		index[tasks[i].ID] = i
+
+		idBin, err := tasks[i].ID.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		args = append(args, idBin)
+	}
+
+	rows, err := q.Query("SELECT tt.task_id, t.name FROM task_tags tt JOIN tags t ON t.tag_id = tt.tag_id WHERE tt.task_id IN (?"+
+		strings.Repeat(", ?", len(args)-1)+") ORDER BY t.name", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID uuid.UUID
+		var name string
+		if err := rows.Scan(&taskID, &name); err != nil {
+			return err
+		}
+
+		i := index[taskID]
+		tasks[i].Tags = append(tasks[i].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// Condition matching the tasks tagged with the names, with all of them when matchAll is set
+func taskTagCondition(names []string, matchAll bool) (string, []any) {
+	args := make([]any, 0, len(names))
+	for _, name := range names {
+		args = append(args, name)
+	}
+
+	cond := "task_id IN (SELECT tt.task_id FROM task_tags tt JOIN tags t ON t.tag_id = tt.tag_id WHERE t.name IN (?" +
+		strings.Repeat(", ?", len(names)-1) + ")"
+	if matchAll {
+		cond += " GROUP BY tt.task_id HAVING COUNT(*) = ?"
+		args = append(args, len(names))
+	}
+
+	return cond + ")", args
+}
+
+// Reports whether the name belongs to a tag of the user other than exceptID
+func (m *MemoryStore) tagNameTaken(userID uuid.UUID, name string, exceptID uuid.UUID) bool {
+	for _, tag := range m.tags {
+		if tag.UserID == userID && tag.Name == name && tag.ID != exceptID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the task with its tags and checklist filled in
+func (m *MemoryStore) withDetails(task utils.Task) utils.Task {
+	task.Tags = []string{}
+	for _, tagID := range m.taskTags[task.ID] {
+		task.Tags = append(task.Tags, m.tags[tagID].Name)
+	}
+
+	sort.Strings(task.Tags)
+	task.SetChecklist(m.checklistOf(task.ID))
+	return task
+}
+
+// Replaces the tags of a task like setTaskTags does
+func (m *MemoryStore) setTaskTags(task utils.Task) {
+	var tagIDs []uuid.UUID
+	for _, name := range task.Tags {
+		tagID := uuid.Nil
+		for _, tag := range m.tags {
+			if tag.UserID == task.UserID && tag.Name == name {
+				tagID = tag.ID
+				break
+			}
+		}
+
+		if tagID == uuid.Nil {
+			tagID = uuid.New()
+			m.tags[tagID] = utils.Tag{ID: tagID, Name: name, UserID: task.UserID, CreatedAt: now()}
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	if len(tagIDs) == 0 {
+		delete(m.taskTags, task.ID)
+	} else {
+		m.taskTags[task.ID] = tagIDs
+	}
+}
+
+// Reports whether the task has any of the tags, or all of them when matchAll is set
+func (m *MemoryStore) hasTags(task utils.Task, names []string, matchAll bool) bool {
+	var tags []string
+	for _, tagID := range m.taskTags[task.ID] {
+		tags = append(tags, m.tags[tagID].Name)
+	}
+
+	for _, name := range names {
+		found := slices.Contains(tags, name)
+		if found && !matchAll {
+			return true
+		}
+		if !found && matchAll {
+			return false
+		}
+	}
+
+	return matchAll
+}
+
+func (m *MemoryStore) GetTags(userID uuid.UUID) ([]utils.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := []utils.Tag{}
+	for _, tag := range m.tags {
+		if tag.UserID == userID {
+			tags = append(tags, tag)
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (m *MemoryStore) CreateTag(tag *utils.Tag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[tag.UserID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+
+	if m.tagNameTaken(tag.UserID, tag.Name, uuid.Nil) {
+		return ErrDuplicateTag
+	}
+
+	created := *tag
+	created.ID = uuid.New()
+	created.CreatedAt = now()
+
+	m.tags[created.ID] = created
+
+	*tag = created
+	return nil
+}
+
+func (m *MemoryStore) UpdateTag(userID, id uuid.UUID, tag utils.Tag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tags[id]
+	if !ok || existing.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	if m.tagNameTaken(userID, tag.Name, id) {
+		return ErrDuplicateTag
+	}
+
+	existing.Name = tag.Name
+	m.tags[id] = existing
+	m.touchTagged(userID, id)
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteTag(userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, ok := m.tags[id]
+	if !ok || tag.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	m.touchTagged(userID, id)
+	delete(m.tags, id)
+	for taskID, tagIDs := range m.taskTags {
+		m.taskTags[taskID] = slices.DeleteFunc(tagIDs, func(tagID uuid.UUID) bool { return tagID == id })
+	}
+
+	return nil
+}
+
+// Marks the tasks with the tag as changed, they list their tags by name
+func (m *MemoryStore) touchTagged(userID, tagID uuid.UUID) {
+	version := m.nextSyncVersion(userID)
+	for taskID, tagIDs := range m.taskTags {
+		if slices.Contains(tagIDs, tagID) {
+			m.touchTask(taskID, version)
+		}
+	}
+}
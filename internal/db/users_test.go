@@ -0,0 +1,41 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateUserErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		userID := createTestUser(t, store, "example@tasklist.com")
+		createTestUser(t, store, "other@tasklist.com")
+
+		user, err := store.GetUserById(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.UpdateUser(uuid.New(), user); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("missing user: error %v, want sql.ErrNoRows", err)
+		}
+
+		taken := user
+		taken.Email = "other@tasklist.com"
+		if err := store.UpdateUser(userID, taken); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("taken email: error %v, want ErrDuplicateEmail", err)
+		}
+
+		user.Name = "renamed"
+		if err := store.UpdateUser(userID, user); err != nil {
+			t.Fatal(err)
+		}
+
+		// The version read before the update is stale now
+		if err := store.UpdateUser(userID, user); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("stale version: error %v, want ErrVersionConflict", err)
+		}
+	})
+}
@@ -0,0 +1,623 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Column order expected by scanWebhook
+const webhookColumns = "webhook_id, user_id, url, events, secret, active, created_at, updated_at"
+
+func scanWebhook(row rowScanner) (utils.Webhook, error) {
+	var webhook utils.Webhook
+	var events string
+
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		&events,
+		&webhook.Secret,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+
+	webhook.Events = strings.Split(events, ",")
+	return webhook, err
+}
+
+func queryWebhooks(q dbtx, query string, args ...any) ([]utils.Webhook, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []utils.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Column order expected by scanDelivery
+const deliveryColumns = "delivery_id, webhook_id, user_id, event, payload, status, attempts, response_status, last_error, next_attempt_at, delivered_at, created_at"
+
+func scanDelivery(row rowScanner) (utils.WebhookDelivery, error) {
+	var delivery utils.WebhookDelivery
+	var payload []byte
+
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.UserID,
+		&delivery.Event,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.NextAttemptAt,
+		&delivery.DeliveredAt,
+		&delivery.CreatedAt,
+	)
+
+	delivery.Payload = payload
+	return delivery, err
+}
+
+func queryDeliveries(q dbtx, query string, args ...any) ([]utils.WebhookDelivery, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []utils.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s *sqlStore) GetWebhooks(userID uuid.UUID) ([]utils.Webhook, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return queryWebhooks(s.db, "SELECT "+webhookColumns+" FROM webhooks WHERE user_id = ? ORDER BY created_at, webhook_id", userIDBin)
+}
+
+func (s *sqlStore) GetWebhook(userID, id uuid.UUID) (utils.Webhook, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Webhook{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.Webhook{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE webhook_id = ? AND user_id = ?", idBin, userIDBin)
+
+	return scanWebhook(row)
+}
+
+// Stores a new webhook, the generated ID and timestamps are set on webhook
+func (s *sqlStore) CreateWebhook(webhook *utils.Webhook) error {
+	webhookID := uuid.New()
+	webhookIDBin, err := webhookID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := webhook.UserID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = s.db.Exec("INSERT INTO webhooks (webhook_id, user_id, url, events, secret, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		webhookIDBin, userIDBin, webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret, webhook.Active, createdAt, createdAt)
+	if err != nil {
+		return err
+	}
+
+	webhook.ID = webhookID
+	webhook.CreatedAt = createdAt
+	webhook.UpdatedAt = createdAt
+
+	return nil
+}
+
+func (s *sqlStore) UpdateWebhook(userID, id uuid.UUID, webhook utils.Webhook) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE webhooks SET url = ?, events = ?, secret = ?, active = ?, updated_at = ? WHERE webhook_id = ? AND user_id = ?",
+		webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret, webhook.Active, now(), idBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Deletes the webhook along with its delivery log
+func (s *sqlStore) DeleteWebhook(userID, id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("DELETE FROM webhooks WHERE webhook_id = ? AND user_id = ?", idBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Queues a delivery of the event payload to every active webhook of the user subscribed to it
+func (s *sqlStore) EnqueueWebhookEvent(userID uuid.UUID, event string, payload []byte) error {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	webhooks, err := queryWebhooks(tx, "SELECT "+webhookColumns+" FROM webhooks WHERE user_id = ? AND active = ?", userIDBin, true)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event) {
+			continue
+		}
+
+		if _, err := insertDelivery(tx, webhook.ID, userIDBin, event, payload); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertDelivery(q dbtx, webhookID uuid.UUID, userIDBin []byte, event string, payload []byte) (uuid.UUID, error) {
+	deliveryID := uuid.New()
+	deliveryIDBin, err := deliveryID.MarshalBinary()
+	if err != nil {
+		return deliveryID, err
+	}
+
+	webhookIDBin, err := webhookID.MarshalBinary()
+	if err != nil {
+		return deliveryID, err
+	}
+
+	createdAt := now()
+	_, err = q.Exec("INSERT INTO webhook_deliveries (delivery_id, webhook_id, user_id, event, payload, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		deliveryIDBin, webhookIDBin, userIDBin, event, string(payload), utils.DeliveryPending, createdAt, createdAt)
+
+	return deliveryID, err
+}
+
+// Lists the latest deliveries of the webhook, newest first
+func (s *sqlStore) GetWebhookDeliveries(userID, webhookID uuid.UUID, limit int) ([]utils.WebhookDelivery, error) {
+	webhookIDBin, err := webhookID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var found int
+	err = s.db.QueryRow("SELECT 1 FROM webhooks WHERE webhook_id = ? AND user_id = ?", webhookIDBin, userIDBin).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryDeliveries(s.db, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC, delivery_id DESC LIMIT ?",
+		webhookIDBin, limit)
+}
+
+func (s *sqlStore) GetWebhookDelivery(userID, webhookID, id uuid.UUID) (utils.WebhookDelivery, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.WebhookDelivery{}, err
+	}
+
+	webhookIDBin, err := webhookID.MarshalBinary()
+	if err != nil {
+		return utils.WebhookDelivery{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.WebhookDelivery{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE delivery_id = ? AND webhook_id = ? AND user_id = ?",
+		idBin, webhookIDBin, userIDBin)
+
+	return scanDelivery(row)
+}
+
+// Queues the payload of an earlier delivery again as a new delivery, which is returned
+func (s *sqlStore) RedeliverWebhookDelivery(userID, webhookID, id uuid.UUID) (utils.WebhookDelivery, error) {
+	delivery, err := s.GetWebhookDelivery(userID, webhookID, id)
+	if err != nil {
+		return delivery, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return delivery, err
+	}
+
+	redeliveryID, err := insertDelivery(s.db, webhookID, userIDBin, delivery.Event, delivery.Payload)
+	if err != nil {
+		return delivery, err
+	}
+
+	return s.GetWebhookDelivery(userID, webhookID, redeliveryID)
+}
+
+// Claims up to limit pending deliveries that are due like ClaimDueReminders does,
+// next_attempt_at is moved to the end of the lease
+func (s *sqlStore) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]utils.WebhookDelivery, error) {
+	claimedAt := now()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var lock string
+	if s.dialect == "mysql" {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+
+	deliveries, err := queryDeliveries(tx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?"+lock,
+		utils.DeliveryPending, claimedAt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedUntil := claimedAt.Add(lease)
+	for _, delivery := range deliveries {
+		idBin, err := delivery.ID.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE delivery_id = ?", lockedUntil, idBin); err != nil {
+			return nil, err
+		}
+	}
+
+	return deliveries, tx.Commit()
+}
+
+// Records a delivery the webhook accepted
+func (s *sqlStore) MarkWebhookDelivered(id uuid.UUID, responseStatus int) error {
+	return s.finishAttempt(id, utils.DeliverySucceeded, &responseStatus, "", nil)
+}
+
+// Records a failed attempt, the delivery is tried again at retryAt
+func (s *sqlStore) RetryWebhookDelivery(id uuid.UUID, responseStatus *int, errMsg string, retryAt time.Time) error {
+	return s.finishAttempt(id, utils.DeliveryPending, responseStatus, errMsg, &retryAt)
+}
+
+// Records the last failed attempt of a delivery that is not retried anymore
+func (s *sqlStore) FailWebhookDelivery(id uuid.UUID, responseStatus *int, errMsg string) error {
+	return s.finishAttempt(id, utils.DeliveryFailed, responseStatus, errMsg, nil)
+}
+
+func (s *sqlStore) finishAttempt(id uuid.UUID, status utils.DeliveryStatus, responseStatus *int, errMsg string, retryAt *time.Time) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var deliveredAt any
+	if status == utils.DeliverySucceeded {
+		deliveredAt = now()
+	}
+
+	result, err := s.db.Exec("UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_status = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE delivery_id = ?",
+		status, responseStatus, lastError(errMsg), utcTime(retryAt), deliveredAt, idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+func (m *MemoryStore) GetWebhooks(userID uuid.UUID) ([]utils.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhooks := []utils.Webhook{}
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID.String() < webhooks[j].ID.String()
+	})
+
+	return webhooks, nil
+}
+
+func (m *MemoryStore) GetWebhook(userID, id uuid.UUID) (utils.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok || webhook.UserID != userID {
+		return utils.Webhook{}, sql.ErrNoRows
+	}
+
+	return webhook, nil
+}
+
+func (m *MemoryStore) CreateWebhook(webhook *utils.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[webhook.UserID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+
+	created := *webhook
+	created.ID = uuid.New()
+	created.Events = slices.Clone(webhook.Events)
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+
+	m.webhooks[created.ID] = created
+
+	*webhook = created
+	return nil
+}
+
+func (m *MemoryStore) UpdateWebhook(userID, id uuid.UUID, webhook utils.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.webhooks[id]
+	if !ok || existing.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	existing.URL = webhook.URL
+	existing.Events = slices.Clone(webhook.Events)
+	existing.Secret = webhook.Secret
+	existing.Active = webhook.Active
+	existing.UpdatedAt = now()
+
+	m.webhooks[id] = existing
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteWebhook(userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok || webhook.UserID != userID {
+		return sql.ErrNoRows
+	}
+
+	m.deleteWebhook(id)
+
+	return nil
+}
+
+func (m *MemoryStore) deleteWebhook(id uuid.UUID) {
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+}
+
+func (m *MemoryStore) EnqueueWebhookEvent(userID uuid.UUID, event string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userID && webhook.Subscribed(event) {
+			m.insertDelivery(webhook.ID, userID, event, payload)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) insertDelivery(webhookID, userID uuid.UUID, event string, payload []byte) utils.WebhookDelivery {
+	createdAt := now()
+	delivery := utils.WebhookDelivery{
+		ID:            uuid.New(),
+		WebhookID:     webhookID,
+		UserID:        userID,
+		Event:         event,
+		Payload:       slices.Clone(payload),
+		Status:        utils.DeliveryPending,
+		NextAttemptAt: &createdAt,
+		CreatedAt:     createdAt,
+	}
+
+	m.deliveries[delivery.ID] = delivery
+	return delivery
+}
+
+func (m *MemoryStore) GetWebhookDeliveries(userID, webhookID uuid.UUID, limit int) ([]utils.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[webhookID]
+	if !ok || webhook.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+
+	deliveries := []utils.WebhookDelivery{}
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID.String() > deliveries[j].ID.String()
+	})
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func (m *MemoryStore) GetWebhookDelivery(userID, webhookID, id uuid.UUID) (utils.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	delivery, ok := m.deliveries[id]
+	if !ok || delivery.WebhookID != webhookID || delivery.UserID != userID {
+		return utils.WebhookDelivery{}, sql.ErrNoRows
+	}
+
+	return delivery, nil
+}
+
+func (m *MemoryStore) RedeliverWebhookDelivery(userID, webhookID, id uuid.UUID) (utils.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[id]
+	if !ok || delivery.WebhookID != webhookID || delivery.UserID != userID {
+		return utils.WebhookDelivery{}, sql.ErrNoRows
+	}
+
+	return m.insertDelivery(webhookID, userID, delivery.Event, delivery.Payload), nil
+}
+
+func (m *MemoryStore) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]utils.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claimedAt := now()
+
+	deliveries := []utils.WebhookDelivery{}
+	for _, delivery := range m.deliveries {
+		if delivery.Status == utils.DeliveryPending && !delivery.NextAttemptAt.After(claimedAt) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	lockedUntil := claimedAt.Add(lease)
+	for _, delivery := range deliveries {
+		delivery.NextAttemptAt = &lockedUntil
+		m.deliveries[delivery.ID] = delivery
+	}
+
+	return deliveries, nil
+}
+
+func (m *MemoryStore) MarkWebhookDelivered(id uuid.UUID, responseStatus int) error {
+	return m.finishAttempt(id, utils.DeliverySucceeded, &responseStatus, "", nil)
+}
+
+func (m *MemoryStore) RetryWebhookDelivery(id uuid.UUID, responseStatus *int, errMsg string, retryAt time.Time) error {
+	retryAt = retryAt.UTC()
+	return m.finishAttempt(id, utils.DeliveryPending, responseStatus, errMsg, &retryAt)
+}
+
+func (m *MemoryStore) FailWebhookDelivery(id uuid.UUID, responseStatus *int, errMsg string) error {
+	return m.finishAttempt(id, utils.DeliveryFailed, responseStatus, errMsg, nil)
+}
+
+func (m *MemoryStore) finishAttempt(id uuid.UUID, status utils.DeliveryStatus, responseStatus *int, errMsg string, retryAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	delivery.Status = status
+	delivery.Attempts++
+	delivery.ResponseStatus = responseStatus
+	delivery.LastError = lastError(errMsg)
+	delivery.NextAttemptAt = retryAt
+	if status == utils.DeliverySucceeded {
+		deliveredAt := now()
+		delivery.DeliveredAt = &deliveredAt
+	}
+
+	m.deliveries[id] = delivery
+
+	return nil
+}
@@ -0,0 +1,1086 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+var (
+	// Returned when a user joins a workspace they are already a member of
+	ErrAlreadyMember = errors.New("already a member of the workspace")
+	// Returned when the email has a pending invitation to the workspace already
+	ErrDuplicateInvitation = errors.New("already invited to the workspace")
+)
+
+// Column order expected by scanWorkspace, the role is the one of the member the workspaces are listed for
+const workspaceColumns = "w.workspace_id, w.name, m.role, w.created_at, w.updated_at"
+
+func scanWorkspace(row rowScanner) (utils.Workspace, error) {
+	var workspace utils.Workspace
+
+	err := row.Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.Role,
+		&workspace.CreatedAt,
+		&workspace.UpdatedAt,
+	)
+
+	return workspace, err
+}
+
+// Column order expected by scanMember
+const memberColumns = "m.workspace_id, m.user_id, u.username, u.email, m.role, m.created_at"
+
+func scanMember(row rowScanner) (utils.WorkspaceMembership, error) {
+	var member utils.WorkspaceMembership
+
+	err := row.Scan(
+		&member.WorkspaceID,
+		&member.UserID,
+		&member.Username,
+		&member.Email,
+		&member.Role,
+		&member.JoinedAt,
+	)
+
+	return member, err
+}
+
+// Column order expected by scanInvitation
+const invitationColumns = "i.invitation_id, i.workspace_id, w.name, i.email, i.role, i.created_at"
+
+func scanInvitation(row rowScanner) (utils.WorkspaceInvitation, error) {
+	var invitation utils.WorkspaceInvitation
+
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.WorkspaceID,
+		&invitation.WorkspaceName,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.CreatedAt,
+	)
+
+	return invitation, err
+}
+
+// Stores a new workspace with the user as its owner, the generated ID and
+// timestamps are set on workspace
+func (s *sqlStore) CreateWorkspace(workspace *utils.Workspace, ownerID uuid.UUID) error {
+	workspaceID := uuid.New()
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	ownerIDBin, err := ownerID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	createdAt := now()
+	_, err = tx.Exec("INSERT INTO workspaces (workspace_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		workspaceIDBin, workspace.Name, createdAt, createdAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)",
+		workspaceIDBin, ownerIDBin, utils.WorkspaceOwner, createdAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	workspace.ID = workspaceID
+	workspace.Role = utils.WorkspaceOwner
+	workspace.CreatedAt = createdAt
+	workspace.UpdatedAt = createdAt
+
+	return nil
+}
+
+// Lists the workspaces the user is a member of
+func (s *sqlStore) GetWorkspaces(userID uuid.UUID) ([]utils.Workspace, error) {
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT "+workspaceColumns+" FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.workspace_id "+
+		"WHERE m.user_id = ? ORDER BY w.name, w.workspace_id", userIDBin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []utils.Workspace{}
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+// Workspaces are only found for their members, with the role of the user.
+// Everything in a workspace is looked up through it.
+func (s *sqlStore) GetWorkspace(userID, id uuid.UUID) (utils.Workspace, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Workspace{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.Workspace{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.workspace_id "+
+		"WHERE w.workspace_id = ? AND m.user_id = ?", idBin, userIDBin)
+
+	return scanWorkspace(row)
+}
+
+func (s *sqlStore) UpdateWorkspace(id uuid.UUID, workspace utils.Workspace) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE workspaces SET name = ?, updated_at = ? WHERE workspace_id = ?", workspace.Name, now(), idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Deletes the workspace, the foreign keys delete its members, invitations,
+// tasks and projects with it
+func (s *sqlStore) DeleteWorkspace(id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("DELETE FROM workspaces WHERE workspace_id = ?", idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Lists the members of the workspace in the order they joined
+func (s *sqlStore) GetWorkspaceMembers(workspaceID uuid.UUID) ([]utils.WorkspaceMembership, error) {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT "+memberColumns+" FROM workspace_members m JOIN users u ON u.user_id = m.user_id "+
+		"WHERE m.workspace_id = ? ORDER BY m.created_at, m.user_id", workspaceIDBin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []utils.WorkspaceMembership{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// Returns sql.ErrNoRows when the user is not a member of the workspace
+func (s *sqlStore) GetWorkspaceMember(workspaceID, userID uuid.UUID) (utils.WorkspaceMembership, error) {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return utils.WorkspaceMembership{}, err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return utils.WorkspaceMembership{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+memberColumns+" FROM workspace_members m JOIN users u ON u.user_id = m.user_id "+
+		"WHERE m.workspace_id = ? AND m.user_id = ?", workspaceIDBin, userIDBin)
+
+	return scanMember(row)
+}
+
+func (s *sqlStore) UpdateWorkspaceMember(workspaceID, userID uuid.UUID, role utils.WorkspaceRole) error {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("UPDATE workspace_members SET role = ? WHERE workspace_id = ? AND user_id = ?", role, workspaceIDBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Removes the user from the workspace. The tasks and projects they created
+// stay in the workspace, their reminders on those tasks are deleted so that
+// nothing of the workspace reaches them anymore.
+func (s *sqlStore) RemoveWorkspaceMember(workspaceID, userID uuid.UUID) error {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceIDBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM reminders WHERE user_id = ? AND task_id IN (SELECT task_id FROM tasks WHERE workspace_id = ?)", userIDBin, workspaceIDBin)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) queryInvitations(query string, args ...any) ([]utils.WorkspaceInvitation, error) {
+	rows, err := s.db.Query("SELECT "+invitationColumns+" FROM workspace_invitations i JOIN workspaces w ON w.workspace_id = i.workspace_id "+
+		"WHERE "+query+" ORDER BY i.created_at, i.invitation_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []utils.WorkspaceInvitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// Stores a new invitation, the generated ID and creation time are set on invitation
+func (s *sqlStore) CreateWorkspaceInvitation(invitation *utils.WorkspaceInvitation) error {
+	invitationID := uuid.New()
+	invitationIDBin, err := invitationID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	workspaceIDBin, err := invitation.WorkspaceID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	createdAt := now()
+	_, err = s.db.Exec("INSERT INTO workspace_invitations (invitation_id, workspace_id, email, role, created_at) VALUES (?, ?, ?, ?, ?)",
+		invitationIDBin, workspaceIDBin, invitation.Email, invitation.Role, createdAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateInvitation
+	} else if err != nil {
+		return err
+	}
+
+	created, err := s.GetWorkspaceInvitation(invitationID)
+	if err != nil {
+		return err
+	}
+
+	*invitation = created
+	return nil
+}
+
+// Invitations are looked up by ID alone, the handlers check whether the
+// caller manages the workspace or is the invited user
+func (s *sqlStore) GetWorkspaceInvitation(id uuid.UUID) (utils.WorkspaceInvitation, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.WorkspaceInvitation{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+invitationColumns+" FROM workspace_invitations i JOIN workspaces w ON w.workspace_id = i.workspace_id "+
+		"WHERE i.invitation_id = ?", idBin)
+
+	return scanInvitation(row)
+}
+
+func (s *sqlStore) GetWorkspaceInvitations(workspaceID uuid.UUID) ([]utils.WorkspaceInvitation, error) {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryInvitations("i.workspace_id = ?", workspaceIDBin)
+}
+
+// Lists the invitations sent to the email
+func (s *sqlStore) GetWorkspaceInvitationsByEmail(email string) ([]utils.WorkspaceInvitation, error) {
+	return s.queryInvitations("i.email = ?", utils.NormalizeEmail(email))
+}
+
+// Adds the user to the workspace of the invitation with its role and removes the invitation
+func (s *sqlStore) AcceptWorkspaceInvitation(id, userID uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	userIDBin, err := userID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var workspaceIDBin []byte
+	var role utils.WorkspaceRole
+	err = tx.QueryRow("SELECT workspace_id, role FROM workspace_invitations WHERE invitation_id = ?", idBin).Scan(&workspaceIDBin, &role)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM workspace_invitations WHERE invitation_id = ?", idBin); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)",
+		workspaceIDBin, userIDBin, role, now())
+	if isUniqueViolation(err) {
+		return ErrAlreadyMember
+	} else if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Withdraws or declines the invitation
+func (s *sqlStore) DeleteWorkspaceInvitation(id uuid.UUID) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec("DELETE FROM workspace_invitations WHERE invitation_id = ?", idBin)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Lists one page of the tasks of the workspace, filtered like GetTasksByUserID
+func (s *sqlStore) GetWorkspaceTasks(workspaceID uuid.UUID, filter utils.TaskFilter) (utils.TaskPage, error) {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return utils.TaskPage{}, err
+	}
+
+	return s.queryTaskPage("workspace_id = ?", []any{workspaceIDBin}, filter)
+}
+
+func (s *sqlStore) GetWorkspaceProjects(workspaceID uuid.UUID) ([]utils.Project, error) {
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryProjects("workspace_id = ?", workspaceIDBin)
+}
+
+// Projects of other workspaces are not found
+func (s *sqlStore) GetWorkspaceProject(workspaceID, id uuid.UUID) (utils.Project, error) {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return utils.Project{}, err
+	}
+
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return utils.Project{}, err
+	}
+
+	row := s.db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE project_id = ? AND workspace_id = ?", idBin, workspaceIDBin)
+
+	return scanProject(row)
+}
+
+// Deletes the project of the workspace like DeleteProject. Its tasks may have
+// been created by different members, the ones that are kept take the next
+// sync version of their creator.
+func (s *sqlStore) DeleteWorkspaceProject(workspaceID, id uuid.UUID, deleteTasks bool) error {
+	idBin, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	workspaceIDBin, err := workspaceID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if deleteTasks {
+		// Tasks in workspaces are not synced, they leave no tombstone
+		if _, err := tx.Exec("DELETE FROM tasks WHERE project_id = ? AND workspace_id = ?", idBin, workspaceIDBin); err != nil {
+			return err
+		}
+	} else {
+		creators, err := projectTaskCreators(tx, idBin)
+		if err != nil {
+			return err
+		}
+
+		// Users are locked in a fixed order so that concurrent deletes cannot deadlock
+		for _, userIDBin := range creators {
+			version, err := nextSyncVersion(tx, userIDBin)
+			if err != nil {
+				return err
+			}
+			if err := touchTasks(tx, version, userIDBin, "project_id = ?", idBin); err != nil {
+				return err
+			}
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM projects WHERE project_id = ? AND workspace_id = ?", idBin, workspaceIDBin)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// The users who created tasks in the project, ordered by ID
+func projectTaskCreators(q dbtx, projectIDBin []byte) ([][]byte, error) {
+	rows, err := q.Query("SELECT DISTINCT user_id FROM tasks WHERE project_id = ? ORDER BY user_id", projectIDBin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users [][]byte
+	for rows.Next() {
+		var userIDBin []byte
+		if err := rows.Scan(&userIDBin); err != nil {
+			return nil, err
+		}
+		users = append(users, userIDBin)
+	}
+
+	return users, rows.Err()
+}
+
+// Gives the workspace tasks and projects of a user who is deleting their
+// account to another member, so that the foreign keys do not delete them with
+// the user. The highest ranked member takes them over, the one who joined
+// first among equals. Workspaces without other members are deleted.
+func handOverWorkspaces(tx dbtx, userIDBin []byte) error {
+	rows, err := tx.Query("SELECT workspace_id FROM workspace_members WHERE user_id = ?", userIDBin)
+	if err != nil {
+		return err
+	}
+
+	var workspaces [][]byte
+	for rows.Next() {
+		var workspaceIDBin []byte
+		if err := rows.Scan(&workspaceIDBin); err != nil {
+			rows.Close()
+			return err
+		}
+		workspaces = append(workspaces, workspaceIDBin)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, workspaceIDBin := range workspaces {
+		var heirBin []byte
+		err := tx.QueryRow("SELECT user_id FROM workspace_members WHERE workspace_id = ? AND user_id <> ? "+
+			"ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, created_at, user_id LIMIT 1",
+			workspaceIDBin, userIDBin).Scan(&heirBin)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := tx.Exec("DELETE FROM workspaces WHERE workspace_id = ?", workspaceIDBin); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE tasks SET user_id = ? WHERE workspace_id = ? AND user_id = ?", heirBin, workspaceIDBin, userIDBin); err != nil {
+			return err
+		}
+		if err := handOverProjects(tx, workspaceIDBin, userIDBin, heirBin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Project names are unique per user, a project whose name the heir already
+// uses is renamed with the first free number, e.g. "School (2)"
+func handOverProjects(tx dbtx, workspaceIDBin, userIDBin, heirBin []byte) error {
+	rows, err := tx.Query("SELECT project_id, name FROM projects WHERE workspace_id = ? AND user_id = ?", workspaceIDBin, userIDBin)
+	if err != nil {
+		return err
+	}
+
+	type handedOver struct {
+		idBin []byte
+		name  string
+	}
+	var projects []handedOver
+	for rows.Next() {
+		var project handedOver
+		if err := rows.Scan(&project.idBin, &project.name); err != nil {
+			rows.Close()
+			return err
+		}
+		projects = append(projects, project)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, project := range projects {
+		name := project.name
+		for n := 2; ; n++ {
+			var found int
+			err := tx.QueryRow("SELECT 1 FROM projects WHERE user_id = ? AND name = ?", heirBin, name).Scan(&found)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			} else if err != nil {
+				return err
+			}
+			name = numberedProjectName(project.name, n)
+		}
+
+		if _, err := tx.Exec("UPDATE projects SET user_id = ?, name = ? WHERE project_id = ?", heirBin, name, project.idBin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Key of a membership in the memory store
+type memoryMember struct {
+	workspaceID uuid.UUID
+	userID      uuid.UUID
+}
+
+// Fills in the name and email of the member from their user
+func (m *MemoryStore) withUser(member utils.WorkspaceMembership) utils.WorkspaceMembership {
+	user := m.users[member.UserID]
+	member.Username = user.Name
+	member.Email = user.Email
+	return member
+}
+
+func (m *MemoryStore) CreateWorkspace(workspace *utils.Workspace, ownerID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[ownerID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+
+	created := *workspace
+	created.ID = uuid.New()
+	created.Role = ""
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+
+	m.workspaces[created.ID] = created
+	m.members[memoryMember{created.ID, ownerID}] = utils.WorkspaceMembership{
+		WorkspaceID: created.ID,
+		UserID:      ownerID,
+		Role:        utils.WorkspaceOwner,
+		JoinedAt:    created.CreatedAt,
+	}
+
+	created.Role = utils.WorkspaceOwner
+	*workspace = created
+	return nil
+}
+
+func (m *MemoryStore) GetWorkspaces(userID uuid.UUID) ([]utils.Workspace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	workspaces := []utils.Workspace{}
+	for key, member := range m.members {
+		if key.userID == userID {
+			workspace := m.workspaces[key.workspaceID]
+			workspace.Role = member.Role
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	sort.Slice(workspaces, func(i, j int) bool {
+		if workspaces[i].Name != workspaces[j].Name {
+			return workspaces[i].Name < workspaces[j].Name
+		}
+		return workspaces[i].ID.String() < workspaces[j].ID.String()
+	})
+
+	return workspaces, nil
+}
+
+func (m *MemoryStore) GetWorkspace(userID, id uuid.UUID) (utils.Workspace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	member, ok := m.members[memoryMember{id, userID}]
+	if !ok {
+		return utils.Workspace{}, sql.ErrNoRows
+	}
+
+	workspace := m.workspaces[id]
+	workspace.Role = member.Role
+	return workspace, nil
+}
+
+func (m *MemoryStore) UpdateWorkspace(id uuid.UUID, workspace utils.Workspace) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.workspaces[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	existing.Name = workspace.Name
+	existing.UpdatedAt = now()
+	m.workspaces[id] = existing
+
+	return nil
+}
+
+// Deletes the workspace with everything in it, like ON DELETE CASCADE in the SQL schema
+func (m *MemoryStore) DeleteWorkspace(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workspaces[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	m.deleteWorkspace(id)
+
+	return nil
+}
+
+func (m *MemoryStore) deleteWorkspace(id uuid.UUID) {
+	for taskID, task := range m.tasks {
+		if task.WorkspaceID != nil && *task.WorkspaceID == id {
+			m.deleteTask(taskID)
+		}
+	}
+	for projectID, project := range m.projects {
+		if project.WorkspaceID != nil && *project.WorkspaceID == id {
+			delete(m.projects, projectID)
+		}
+	}
+	for key := range m.members {
+		if key.workspaceID == id {
+			delete(m.members, key)
+		}
+	}
+	for invitationID, invitation := range m.invitations {
+		if invitation.WorkspaceID == id {
+			delete(m.invitations, invitationID)
+		}
+	}
+	delete(m.workspaces, id)
+}
+
+func (m *MemoryStore) GetWorkspaceMembers(workspaceID uuid.UUID) ([]utils.WorkspaceMembership, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := []utils.WorkspaceMembership{}
+	for key, member := range m.members {
+		if key.workspaceID == workspaceID {
+			members = append(members, m.withUser(member))
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].JoinedAt.Equal(members[j].JoinedAt) {
+			return members[i].JoinedAt.Before(members[j].JoinedAt)
+		}
+		return members[i].UserID.String() < members[j].UserID.String()
+	})
+
+	return members, nil
+}
+
+func (m *MemoryStore) GetWorkspaceMember(workspaceID, userID uuid.UUID) (utils.WorkspaceMembership, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	member, ok := m.members[memoryMember{workspaceID, userID}]
+	if !ok {
+		return utils.WorkspaceMembership{}, sql.ErrNoRows
+	}
+
+	return m.withUser(member), nil
+}
+
+func (m *MemoryStore) UpdateWorkspaceMember(workspaceID, userID uuid.UUID, role utils.WorkspaceRole) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryMember{workspaceID, userID}
+	member, ok := m.members[key]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	member.Role = role
+	m.members[key] = member
+
+	return nil
+}
+
+func (m *MemoryStore) RemoveWorkspaceMember(workspaceID, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryMember{workspaceID, userID}
+	if _, ok := m.members[key]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(m.members, key)
+	for reminderID, reminder := range m.reminders {
+		task := m.tasks[reminder.TaskID]
+		if reminder.UserID == userID && task.WorkspaceID != nil && *task.WorkspaceID == workspaceID {
+			delete(m.reminders, reminderID)
+			delete(m.reminderLocks, reminderID)
+		}
+	}
+
+	return nil
+}
+
+// Fills in the name of the workspace the invitation is to
+func (m *MemoryStore) withWorkspaceName(invitation utils.WorkspaceInvitation) utils.WorkspaceInvitation {
+	invitation.WorkspaceName = m.workspaces[invitation.WorkspaceID].Name
+	return invitation
+}
+
+// Returns the matching invitations ordered by creation time
+func (m *MemoryStore) filterInvitations(match func(utils.WorkspaceInvitation) bool) []utils.WorkspaceInvitation {
+	invitations := []utils.WorkspaceInvitation{}
+	for _, invitation := range m.invitations {
+		if match(invitation) {
+			invitations = append(invitations, m.withWorkspaceName(invitation))
+		}
+	}
+
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.Before(invitations[j].CreatedAt)
+		}
+		return invitations[i].ID.String() < invitations[j].ID.String()
+	})
+
+	return invitations
+}
+
+func (m *MemoryStore) CreateWorkspaceInvitation(invitation *utils.WorkspaceInvitation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workspaces[invitation.WorkspaceID]; !ok {
+		return errors.New("foreign key constraint failed: unknown workspace")
+	}
+
+	for _, existing := range m.invitations {
+		if existing.WorkspaceID == invitation.WorkspaceID && existing.Email == invitation.Email {
+			return ErrDuplicateInvitation
+		}
+	}
+
+	created := *invitation
+	created.ID = uuid.New()
+	created.CreatedAt = now()
+
+	m.invitations[created.ID] = created
+
+	*invitation = m.withWorkspaceName(created)
+	return nil
+}
+
+func (m *MemoryStore) GetWorkspaceInvitation(id uuid.UUID) (utils.WorkspaceInvitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	invitation, ok := m.invitations[id]
+	if !ok {
+		return utils.WorkspaceInvitation{}, sql.ErrNoRows
+	}
+
+	return m.withWorkspaceName(invitation), nil
+}
+
+func (m *MemoryStore) GetWorkspaceInvitations(workspaceID uuid.UUID) ([]utils.WorkspaceInvitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterInvitations(func(invitation utils.WorkspaceInvitation) bool {
+		return invitation.WorkspaceID == workspaceID
+	}), nil
+}
+
+func (m *MemoryStore) GetWorkspaceInvitationsByEmail(email string) ([]utils.WorkspaceInvitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	email = utils.NormalizeEmail(email)
+	return m.filterInvitations(func(invitation utils.WorkspaceInvitation) bool {
+		return invitation.Email == email
+	}), nil
+}
+
+func (m *MemoryStore) AcceptWorkspaceInvitation(id, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invitation, ok := m.invitations[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := m.users[userID]; !ok {
+		return errors.New("foreign key constraint failed: unknown user")
+	}
+
+	key := memoryMember{invitation.WorkspaceID, userID}
+	if _, ok := m.members[key]; ok {
+		return ErrAlreadyMember
+	}
+
+	delete(m.invitations, id)
+	m.members[key] = utils.WorkspaceMembership{
+		WorkspaceID: invitation.WorkspaceID,
+		UserID:      userID,
+		Role:        invitation.Role,
+		JoinedAt:    now(),
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteWorkspaceInvitation(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.invitations[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(m.invitations, id)
+
+	return nil
+}
+
+func (m *MemoryStore) GetWorkspaceTasks(workspaceID uuid.UUID, filter utils.TaskFilter) (utils.TaskPage, error) {
+	return m.taskPage(func(task utils.Task) bool {
+		return task.WorkspaceID != nil && *task.WorkspaceID == workspaceID
+	}, filter)
+}
+
+func (m *MemoryStore) GetWorkspaceProjects(workspaceID uuid.UUID) ([]utils.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterProjects(func(project utils.Project) bool {
+		return project.WorkspaceID != nil && *project.WorkspaceID == workspaceID
+	}), nil
+}
+
+func (m *MemoryStore) GetWorkspaceProject(workspaceID, id uuid.UUID) (utils.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	project, ok := m.projects[id]
+	if !ok || project.WorkspaceID == nil || *project.WorkspaceID != workspaceID {
+		return utils.Project{}, sql.ErrNoRows
+	}
+
+	return project, nil
+}
+
+func (m *MemoryStore) DeleteWorkspaceProject(workspaceID, id uuid.UUID, deleteTasks bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	project, ok := m.projects[id]
+	if !ok || project.WorkspaceID == nil || *project.WorkspaceID != workspaceID {
+		return sql.ErrNoRows
+	}
+
+	var creators []uuid.UUID
+	for taskID, task := range m.tasks {
+		if task.ProjectID == nil || *task.ProjectID != id {
+			continue
+		}
+
+		if deleteTasks {
+			m.deleteTask(taskID)
+		} else if !slices.Contains(creators, task.UserID) {
+			creators = append(creators, task.UserID)
+		}
+	}
+
+	for _, userID := range creators {
+		version := m.nextSyncVersion(userID)
+		for taskID, task := range m.tasks {
+			if task.UserID == userID && task.ProjectID != nil && *task.ProjectID == id {
+				task.ProjectID = nil
+				task.Version = version
+				m.tasks[taskID] = task
+			}
+		}
+	}
+
+	delete(m.projects, id)
+
+	return nil
+}
+
+// Removes the memberships of the user and hands over their workspace tasks
+// and projects like handOverWorkspaces does in the SQL stores
+func (m *MemoryStore) deleteUserMemberships(userID uuid.UUID) {
+	for key := range m.members {
+		if key.userID != userID {
+			continue
+		}
+		delete(m.members, key)
+
+		heir, ok := m.workspaceHeir(key.workspaceID)
+		if !ok {
+			m.deleteWorkspace(key.workspaceID)
+			continue
+		}
+
+		for taskID, task := range m.tasks {
+			if task.UserID == userID && task.WorkspaceID != nil && *task.WorkspaceID == key.workspaceID {
+				task.UserID = heir
+				m.tasks[taskID] = task
+			}
+		}
+		for projectID, project := range m.projects {
+			if project.UserID == userID && project.WorkspaceID != nil && *project.WorkspaceID == key.workspaceID {
+				name := project.Name
+				for n := 2; m.projectNameTaken(heir, name, projectID); n++ {
+					name = numberedProjectName(project.Name, n)
+				}
+
+				project.UserID = heir
+				project.Name = name
+				m.projects[projectID] = project
+			}
+		}
+	}
+}
+
+// The member who takes over the tasks and projects of a leaving user
+func (m *MemoryStore) workspaceHeir(workspaceID uuid.UUID) (uuid.UUID, bool) {
+	var heir *utils.WorkspaceMembership
+	for key, member := range m.members {
+		if key.workspaceID != workspaceID {
+			continue
+		}
+		if heir == nil || heirBefore(member, *heir) {
+			member := member
+			heir = &member
+		}
+	}
+
+	if heir == nil {
+		return uuid.Nil, false
+	}
+
+	return heir.UserID, true
+}
+
+func heirBefore(a, b utils.WorkspaceMembership) bool {
+	if a.Role != b.Role {
+		return a.Role.Outranks(b.Role)
+	}
+	if !a.JoinedAt.Equal(b.JoinedAt) {
+		return a.JoinedAt.Before(b.JoinedAt)
+	}
+	return a.UserID.String() < b.UserID.String()
+}
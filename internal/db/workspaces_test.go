@@ -0,0 +1,94 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func createTestProject(t *testing.T, store Storage, userID uuid.UUID, workspaceID *uuid.UUID, name string) utils.Project {
+	t.Helper()
+
+	project := utils.Project{Name: name, UserID: userID, WorkspaceID: workspaceID}
+	if err := store.CreateProject(&project); err != nil {
+		t.Fatal(err)
+	}
+
+	return project
+}
+
+func joinTestWorkspace(t *testing.T, store Storage, workspaceID, userID uuid.UUID, email string) {
+	t.Helper()
+
+	invitation := utils.WorkspaceInvitation{WorkspaceID: workspaceID, Email: email, Role: utils.WorkspaceMember}
+	if err := store.CreateWorkspaceInvitation(&invitation); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AcceptWorkspaceInvitation(invitation.ID, userID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The projects of a deleted member go to the owner, renamed where the owner uses the name already
+func TestDeleteUserHandsOverClashingProjects(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Storage) {
+		ownerID := createTestUser(t, store, "owner@tasklist.com")
+		memberID := createTestUser(t, store, "member@tasklist.com")
+
+		workspace := utils.Workspace{Name: "Study group"}
+		if err := store.CreateWorkspace(&workspace, ownerID); err != nil {
+			t.Fatal(err)
+		}
+		joinTestWorkspace(t, store, workspace.ID, memberID, "member@tasklist.com")
+
+		long := strings.Repeat("x", 50)
+		createTestProject(t, store, ownerID, nil, "School")
+		createTestProject(t, store, ownerID, &workspace.ID, "School (2)")
+		createTestProject(t, store, ownerID, nil, long)
+
+		clashing := createTestProject(t, store, memberID, &workspace.ID, "School")
+		clashingLong := createTestProject(t, store, memberID, &workspace.ID, long)
+		free := createTestProject(t, store, memberID, &workspace.ID, "Physics")
+		personal := createTestProject(t, store, memberID, nil, "Hobbies")
+
+		task, err := store.CreateTask(&utils.Task{Title: "Workspace task", Deadline: time.Date(2030, 12, 1, 0, 0, 0, 0, time.UTC),
+			UserID: memberID, WorkspaceID: &workspace.ID, ProjectID: &clashing.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.DeleteUser(memberID); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[uuid.UUID]string{
+			clashing.ID:     "School (3)",
+			clashingLong.ID: strings.Repeat("x", 46) + " (2)",
+			free.ID:         "Physics",
+		}
+		for id, name := range want {
+			project, err := store.GetWorkspaceProject(workspace.ID, id)
+			if err != nil {
+				t.Fatalf("project %s: %v", id, err)
+			}
+			if project.Name != name || project.UserID != ownerID {
+				t.Errorf("handed over project %+v, want %q of the owner", project, name)
+			}
+		}
+
+		if _, err := store.GetProject(ownerID, personal.ID); err == nil {
+			t.Error("personal project of the deleted member was handed over")
+		}
+
+		got, role, err := store.GetAccessibleTask(ownerID, task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UserID != ownerID || role != utils.RoleOwner || got.ProjectID == nil || *got.ProjectID != clashing.ID {
+			t.Errorf("handed over task %+v, want it in project %s", got, clashing.ID)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+// Package events passes change events to the clients connected to the
+// /me/events streams. Recent events of every user are kept in memory so that
+// a reconnecting client can resume after the last event it received.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Events kept per user for resuming
+	DefaultHistory = 100
+	// Events buffered per connection, a client that falls further behind is disconnected
+	subscriberBuffer = 64
+)
+
+type Event struct {
+	ID        uint64          `json:"id,omitempty"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Bus keeps the recent events of every user and passes new ones to their subscribers
+type Bus struct {
+	mu sync.Mutex
+	// IDs of this process are after startID
+	startID uint64
+	lastID  uint64
+	history int
+	users   map[uuid.UUID]*userEvents
+}
+
+type userEvents struct {
+	recent []Event
+	// ID of the newest event no longer in recent
+	dropped     uint64
+	subscribers map[*Subscription]struct{}
+}
+
+// A connected client of one user. C is closed when the subscription ends,
+// either by Close or because the client fell behind.
+type Subscription struct {
+	C <-chan Event
+
+	bus    *Bus
+	userID uuid.UUID
+	ch     chan Event
+	closed bool
+}
+
+func NewBus() *Bus {
+	// IDs continue from the start time so that they keep growing over restarts.
+	// Events before a restart are lost, resuming from them is never complete.
+	startID := uint64(time.Now().UnixMilli())
+
+	return &Bus{
+		startID: startID,
+		lastID:  startID,
+		history: DefaultHistory,
+		users:   make(map[uuid.UUID]*userEvents),
+	}
+}
+
+// Sends the event to the connected clients of the user
+func (b *Bus) Publish(userID uuid.UUID, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{
+		ID:        b.lastID,
+		Event:     event,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Data:      body,
+	}
+
+	u := b.user(userID)
+	u.recent = append(u.recent, e)
+	if len(u.recent) > b.history {
+		trim := len(u.recent) - b.history
+		u.dropped = u.recent[trim-1].ID
+		u.recent = append([]Event(nil), u.recent[trim:]...)
+	}
+
+	for sub := range u.subscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			// Dropping events would leave the client silently out of date
+			b.unsubscribe(sub)
+		}
+	}
+
+	return nil
+}
+
+// Subscribes to the events of the user. With the ID of the last event a
+// previous connection received, the kept events after it are returned for
+// replay. complete is false when events after lastID are no longer kept, the
+// client has to reload its data instead.
+func (b *Bus) Subscribe(userID uuid.UUID, lastID *uint64) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u := b.user(userID)
+	complete = true
+
+	if lastID != nil {
+		complete = *lastID >= b.startID && *lastID >= u.dropped && *lastID <= b.lastID
+		for i, e := range u.recent {
+			if e.ID > *lastID {
+				replay = append(replay, u.recent[i:]...)
+				break
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: ch, bus: b, userID: userID, ch: ch}
+	u.subscribers[sub] = struct{}{}
+
+	return sub, replay, complete
+}
+
+// Ends the subscription, safe to call more than once
+func (sub *Subscription) Close() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	sub.bus.unsubscribe(sub)
+}
+
+func (b *Bus) user(userID uuid.UUID) *userEvents {
+	u, ok := b.users[userID]
+	if !ok {
+		u = &userEvents{subscribers: make(map[*Subscription]struct{})}
+		b.users[userID] = u
+	}
+	return u
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+
+	if u, ok := b.users[sub.userID]; ok {
+		delete(u.subscribers, sub)
+	}
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	default:
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func expectNone(t *testing.T, sub *Subscription) {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.C:
+		t.Fatalf("got event %+v (open %v), want none", e, ok)
+	default:
+	}
+}
+
+func TestPublishReachesOnlyTheUser(t *testing.T) {
+	bus := NewBus()
+	userID, otherID := uuid.New(), uuid.New()
+
+	sub, replay, complete := bus.Subscribe(userID, nil)
+	defer sub.Close()
+	other, _, _ := bus.Subscribe(otherID, nil)
+	defer other.Close()
+
+	if len(replay) != 0 || !complete {
+		t.Fatalf("new subscription replays %v, complete %v", replay, complete)
+	}
+
+	if err := bus.Publish(userID, "task.created", map[string]string{"title": "Math homework"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := receive(t, sub)
+	if e.Event != "task.created" || string(e.Data) != `{"title":"Math homework"}` || e.ID <= bus.startID {
+		t.Errorf("event %+v", e)
+	}
+	expectNone(t, other)
+}
+
+func TestSubscribeReplaysAfterLastID(t *testing.T) {
+	bus := NewBus()
+	bus.history = 3
+	userID := uuid.New()
+
+	var ids []uint64
+	for i := 0; i < 5; i++ {
+		bus.Publish(userID, "task.updated", i)
+		ids = append(ids, bus.lastID)
+	}
+	// Another users events in between do not break the replay
+	bus.Publish(uuid.New(), "task.updated", "other")
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		replay   []uint64
+		complete bool
+	}{
+		{"latest", ids[4], nil, true},
+		{"kept", ids[2], ids[3:], true},
+		{"oldest kept", ids[1], ids[2:], true},
+		{"dropped", ids[0], ids[2:], false},
+		{"before startup", bus.startID - 1, ids[2:], false},
+		{"from the future", bus.lastID + 1, nil, false},
+	}
+
+	for _, tt := range tests {
+		sub, replay, complete := bus.Subscribe(userID, &tt.lastID)
+		sub.Close()
+
+		var got []uint64
+		for _, e := range replay {
+			got = append(got, e.ID)
+		}
+		if len(got) != len(tt.replay) || complete != tt.complete {
+			t.Errorf("%s: replayed %v complete %v, want %v complete %v", tt.name, got, complete, tt.replay, tt.complete)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.replay[i] {
+				t.Errorf("%s: replayed %v, want %v", tt.name, got, tt.replay)
+				break
+			}
+		}
+	}
+}
+
+func TestCloseUnsubscribes(t *testing.T) {
+	bus := NewBus()
+	userID := uuid.New()
+
+	sub, _, _ := bus.Subscribe(userID, nil)
+	kept, _, _ := bus.Subscribe(userID, nil)
+	defer kept.Close()
+
+	sub.Close()
+	sub.Close()
+
+	if _, ok := <-sub.C; ok {
+		t.Error("closed subscription still receives events")
+	}
+	if _, ok := bus.users[userID].subscribers[sub]; ok {
+		t.Error("closed subscription is still subscribed")
+	}
+
+	if err := bus.Publish(userID, "task.deleted", nil); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, kept)
+}
+
+// A client that stops reading is disconnected instead of blocking the publisher
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewBus()
+	userID := uuid.New()
+
+	sub, _, _ := bus.Subscribe(userID, nil)
+	for i := 0; i <= subscriberBuffer; i++ {
+		bus.Publish(userID, "task.updated", i)
+	}
+
+	if len(bus.users[userID].subscribers) != 0 {
+		t.Fatal("slow subscriber is still subscribed")
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d buffered events, want %d", received, subscriberBuffer)
+	}
+	sub.Close()
+}
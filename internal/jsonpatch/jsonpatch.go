@@ -0,0 +1,408 @@
+// Package jsonpatch applies the two patch formats accepted by PATCH requests:
+// JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902).
+//
+//	patched, err := jsonpatch.MergePatch(doc, []byte(`{"description": null}`))
+//	patched, err := jsonpatch.Apply(doc, []byte(`[{"op": "remove", "path": "/description"}]`))
+//
+// Documents are decoded with json.Number so that numbers pass through unchanged.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the patch formats
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// The patch itself is malformed, e.g. not JSON or an operation without a path
+	ErrInvalidPatch = errors.New("invalid patch")
+	// A test operation did not match the document
+	ErrTestFailed = errors.New("test failed")
+	// The patch is well formed but cannot be applied to the document,
+	// e.g. it removes a member that does not exist
+	ErrNotApplicable = errors.New("patch cannot be applied")
+)
+
+// Applies a JSON Merge Patch: objects are merged member by member, a null
+// removes the member and any other value replaces it.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergeValue(t[name], value)
+		}
+	}
+
+	return t
+}
+
+// An operation of a JSON Patch
+type Operation struct {
+	Op   string  `json:"op"`
+	Path *string `json:"path"`
+	From *string `json:"from"`
+	// Kept raw so that a null value can be told apart from a missing one
+	Value json.RawMessage `json:"value"`
+}
+
+// Applies the operations of a JSON Patch in order. The patch is applied as a
+// whole, on any error the document is left as it was.
+func Apply(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: must be an array of operations", ErrInvalidPatch)
+	}
+
+	for i, op := range ops {
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func (op Operation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: %s without a path", ErrInvalidPatch, op.Op)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if _, err := get(doc, path); err != nil {
+				return nil, err
+			}
+			doc, _, err = remove(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(normalize(current), normalize(value)) {
+				return nil, fmt.Errorf("%w: %s", ErrTestFailed, *op.Path)
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: %s without from", ErrInvalidPatch, op.Op)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			value, err := get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, deepCopy(value))
+		}
+
+		if isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", ErrNotApplicable, *op.From)
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+func (op Operation) value() (any, error) {
+	if len(op.Value) == 0 {
+		return nil, fmt.Errorf("%w: %s without a value", ErrInvalidPatch, op.Op)
+	}
+	return decode(op.Value)
+}
+
+// Parses a JSON Pointer (RFC 6901) into its reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path must start with /: %q", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(path)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, notFound(path)
+		}
+	}
+
+	return doc, nil
+}
+
+// Adds the value at path and returns the changed document. Adding to an
+// array inserts before the index, "-" appends.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return doc, nil
+	case []any:
+		i := len(node)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		node = append(node[:i], append([]any{value}, node[i:]...)...)
+		return set(doc, path[:len(path)-1], node)
+	}
+
+	return nil, notFound(path)
+}
+
+// Removes the value at path, returns the changed document and the removed value
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, notFound(path)
+		}
+		delete(node, last)
+		return doc, value, nil
+	case []any:
+		i, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[i]
+		node = append(node[:i:i], node[i+1:]...)
+		doc, err = set(doc, path[:len(path)-1], node)
+		return doc, value, err
+	}
+
+	return nil, nil, notFound(path)
+}
+
+// Replaces the value at path, used for arrays whose slice header changes
+func set(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	}
+
+	return doc, nil
+}
+
+// Parses an array index between 0 and max, leading zeros are not allowed
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || strings.HasPrefix(token, "+") {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrNotApplicable, token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrNotApplicable, i)
+	}
+	return i, nil
+}
+
+func notFound(path []string) error {
+	return fmt.Errorf("%w: %s does not exist", ErrNotApplicable, formatPointer(path))
+}
+
+func formatPointer(path []string) string {
+	var b strings.Builder
+	for _, token := range path {
+		b.WriteString("/")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+
+	return v, nil
+}
+
+// Numbers compare by value in test operations, 1 and 1.0 are equal
+func normalize(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for name, value := range v {
+			out[name] = normalize(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = normalize(value)
+		}
+		return out
+	}
+	return v
+}
+
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for name, value := range v {
+			out[name] = deepCopy(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = deepCopy(value)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,173 @@
+package jsonpatch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Compares JSON documents by value, ignoring the order of members
+func sameJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+
+	g, err := decode(got)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", got, err)
+	}
+	w, err := decode([]byte(want))
+	if err != nil {
+		t.Fatalf("decoding %s: %v", want, err)
+	}
+
+	return reflect.DeepEqual(normalize(g), normalize(w))
+}
+
+const testDoc = `{"title":"Math homework","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   error
+	}{
+		// add
+		{"add member", `[{"op":"add","path":"/description","value":"Pages 4-5"}]`,
+			`{"title":"Math homework","description":"Pages 4-5","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"add replaces existing member", `[{"op":"add","path":"/title","value":"Physics"}]`,
+			`{"title":"Physics","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"add null value", `[{"op":"add","path":"/description","value":null}]`,
+			`{"title":"Math homework","description":null,"tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"add inserts into array", `[{"op":"add","path":"/tags/1","value":"urgent"}]`,
+			`{"title":"Math homework","tags":["school","urgent","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"add at array length", `[{"op":"add","path":"/tags/2","value":"urgent"}]`,
+			`{"title":"Math homework","tags":["school","math","urgent"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"add appends with -", `[{"op":"add","path":"/tags/-","value":"urgent"}]`,
+			`{"title":"Math homework","tags":["school","math","urgent"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"add past array end", `[{"op":"add","path":"/tags/3","value":"urgent"}]`, "", ErrNotApplicable},
+		{"add negative index", `[{"op":"add","path":"/tags/-1","value":"urgent"}]`, "", ErrNotApplicable},
+		{"add leading zero index", `[{"op":"add","path":"/tags/01","value":"urgent"}]`, "", ErrNotApplicable},
+		{"add to missing parent", `[{"op":"add","path":"/missing/child","value":1}]`, "", ErrNotApplicable},
+		{"add without value", `[{"op":"add","path":"/description"}]`, "", ErrInvalidPatch},
+
+		// remove
+		{"remove member", `[{"op":"remove","path":"/checklist"}]`,
+			`{"title":"Math homework","tags":["school","math"],"nested":{"items":[{"done":false}]}}`, nil},
+		{"remove array element", `[{"op":"remove","path":"/tags/0"}]`,
+			`{"title":"Math homework","tags":["math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"remove missing member", `[{"op":"remove","path":"/description"}]`, "", ErrNotApplicable},
+		{"remove out of range", `[{"op":"remove","path":"/tags/2"}]`, "", ErrNotApplicable},
+		{"remove -", `[{"op":"remove","path":"/tags/-"}]`, "", ErrNotApplicable},
+
+		// replace
+		{"replace member", `[{"op":"replace","path":"/title","value":"Physics"}]`,
+			`{"title":"Physics","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"replace array element", `[{"op":"replace","path":"/tags/1","value":"physics"}]`,
+			`{"title":"Math homework","tags":["school","physics"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"replace nested", `[{"op":"replace","path":"/nested/items/0/done","value":true}]`,
+			`{"title":"Math homework","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":true}]}}`, nil},
+		{"replace missing member", `[{"op":"replace","path":"/description","value":"x"}]`, "", ErrNotApplicable},
+		{"replace out of range", `[{"op":"replace","path":"/tags/2","value":"x"}]`, "", ErrNotApplicable},
+		{"replace whole document", `[{"op":"replace","path":"","value":{"title":"New"}}]`, `{"title":"New"}`, nil},
+
+		// move
+		{"move member", `[{"op":"move","from":"/title","path":"/name"}]`,
+			`{"name":"Math homework","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"move array element", `[{"op":"move","from":"/tags/0","path":"/tags/-"}]`,
+			`{"title":"Math homework","tags":["math","school"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"move to itself", `[{"op":"move","from":"/title","path":"/title"}]`, testDoc, nil},
+		{"move into own child", `[{"op":"move","from":"/nested","path":"/nested/items/0/parent"}]`, "", ErrNotApplicable},
+		{"move missing member", `[{"op":"move","from":"/description","path":"/notes"}]`, "", ErrNotApplicable},
+		{"move without from", `[{"op":"move","path":"/notes"}]`, "", ErrInvalidPatch},
+
+		// copy
+		{"copy member", `[{"op":"copy","from":"/tags","path":"/labels"}]`,
+			`{"title":"Math homework","tags":["school","math"],"labels":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"copy is independent", `[{"op":"copy","from":"/nested","path":"/copy"},{"op":"replace","path":"/copy/items/0/done","value":true}]`,
+			`{"title":"Math homework","tags":["school","math"],"checklist":{"a/b":1,"m~n":2},"nested":{"items":[{"done":false}]},"copy":{"items":[{"done":true}]}}`, nil},
+		{"copy missing member", `[{"op":"copy","from":"/description","path":"/notes"}]`, "", ErrNotApplicable},
+
+		// test
+		{"test passes", `[{"op":"test","path":"/title","value":"Math homework"}]`, testDoc, nil},
+		{"test compares numbers by value", `[{"op":"test","path":"/checklist/a~1b","value":1.0}]`, testDoc, nil},
+		{"test compares objects", `[{"op":"test","path":"/nested","value":{"items":[{"done":false}]}}]`, testDoc, nil},
+		{"test fails", `[{"op":"test","path":"/title","value":"Physics"}]`, "", ErrTestFailed},
+		{"test fails on type", `[{"op":"test","path":"/checklist/a~1b","value":"1"}]`, "", ErrTestFailed},
+		{"failed test stops the patch", `[{"op":"replace","path":"/title","value":"Physics"},{"op":"test","path":"/title","value":"Math homework"}]`, "", ErrTestFailed},
+		{"test of missing member", `[{"op":"test","path":"/description","value":"x"}]`, "", ErrNotApplicable},
+
+		// pointers
+		{"~1 unescapes to /", `[{"op":"replace","path":"/checklist/a~1b","value":3}]`,
+			`{"title":"Math homework","tags":["school","math"],"checklist":{"a/b":3,"m~n":2},"nested":{"items":[{"done":false}]}}`, nil},
+		{"~0 unescapes to ~", `[{"op":"remove","path":"/checklist/m~0n"}]`,
+			`{"title":"Math homework","tags":["school","math"],"checklist":{"a/b":1},"nested":{"items":[{"done":false}]}}`, nil},
+		{"~01 is ~1, not /", `[{"op":"add","path":"/checklist/~01","value":4}]`,
+			`{"title":"Math homework","tags":["school","math"],"checklist":{"a/b":1,"m~n":2,"~1":4},"nested":{"items":[{"done":false}]}}`, nil},
+		{"path without leading /", `[{"op":"remove","path":"title"}]`, "", ErrInvalidPatch},
+
+		// malformed patches
+		{"not an array", `{"op":"remove","path":"/title"}`, "", ErrInvalidPatch},
+		{"not JSON", `[{"op":`, "", ErrInvalidPatch},
+		{"unknown op", `[{"op":"rename","path":"/title"}]`, "", ErrInvalidPatch},
+		{"missing path", `[{"op":"remove"}]`, "", ErrInvalidPatch},
+		{"empty patch", `[]`, testDoc, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(testDoc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("patched %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"title":"Math","done":false}`, `{"title":"Physics"}`, `{"title":"Physics","done":false}`},
+		{"null deletes member", `{"title":"Math","description":"Pages 4-5"}`, `{"description":null}`, `{"title":"Math"}`},
+		{"null of missing member", `{"title":"Math"}`, `{"description":null}`, `{"title":"Math"}`},
+		{"add member", `{"title":"Math"}`, `{"description":"Pages 4-5"}`, `{"title":"Math","description":"Pages 4-5"}`},
+		{"merge nested", `{"a":{"b":1,"c":2}}`, `{"a":{"b":null,"d":3}}`, `{"a":{"c":2,"d":3}}`},
+		{"object replaces scalar", `{"a":1}`, `{"a":{"b":null,"c":2}}`, `{"a":{"c":2}}`},
+		{"arrays are replaced", `{"tags":["a","b"]}`, `{"tags":["c"]}`, `{"tags":["c"]}`},
+		{"non-object patch replaces document", `{"title":"Math"}`, `["a"]`, `["a"]`},
+		{"empty patch", `{"title":"Math"}`, `{}`, `{"title":"Math"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("patched %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Numbers are not converted to float64 on the way through
+	if got, err := MergePatch([]byte(`{"n":1.50}`), []byte(`{"big":12345678901234567890}`)); err != nil || string(got) != `{"big":12345678901234567890,"n":1.50}` {
+		t.Errorf("numbers patched to %s, %v", got, err)
+	}
+
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"title":`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("malformed merge patch: error %v, want ErrInvalidPatch", err)
+	}
+}
@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Writes reminders to a log, for development
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// Logs to the standard logger when logger is nil
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	n.logger.Printf("Reminder for %s (%s): %s", msg.User.Name, msg.User.Email, msg.Text())
+	return nil
+}
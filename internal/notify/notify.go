@@ -0,0 +1,71 @@
+// Package notify delivers task reminders to users. The channel is chosen with
+// the NOTIFIER environment variable, see FromEnv.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// A due reminder with the task and user it belongs to. Times are in the time zone of the user.
+type Message struct {
+	Reminder utils.Reminder
+	Task     utils.Task
+	User     utils.User
+}
+
+type Notifier interface {
+	// Delivers the message, an error means the delivery should be retried
+	Notify(ctx context.Context, msg Message) error
+}
+
+func (m Message) Subject() string {
+	return "Reminder: " + m.Task.Title
+}
+
+func (m Message) Text() string {
+	deadline := m.Task.Deadline.Format("Mon Jan 2 15:04 MST")
+	if m.Task.AllDay {
+		deadline = m.Task.Deadline.Format("Mon Jan 2")
+	}
+
+	text := fmt.Sprintf("%s is due %s.", m.Task.Title, deadline)
+	if m.Task.Description != "" {
+		text += "\n\n" + m.Task.Description
+	}
+
+	return text
+}
+
+// Builds the notifier selected with NOTIFIER:
+//
+//	log (default)  writes reminders to the server log
+//	webhook        posts them as JSON to REMINDER_WEBHOOK_URL
+//	smtp           emails them through SMTP_ADDR (host:port) from SMTP_FROM,
+//	               SMTP_USERNAME and SMTP_PASSWORD are optional
+func FromEnv() (Notifier, error) {
+	switch kind := os.Getenv("NOTIFIER"); kind {
+	case "", "log":
+		return NewLogNotifier(nil), nil
+
+	case "webhook":
+		url := os.Getenv("REMINDER_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("REMINDER_WEBHOOK_URL is required for the webhook notifier")
+		}
+		return NewWebhookNotifier(url), nil
+
+	case "smtp":
+		addr, from := os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_FROM")
+		if addr == "" || from == "" {
+			return nil, fmt.Errorf("SMTP_ADDR and SMTP_FROM are required for the smtp notifier")
+		}
+		return NewSMTPNotifier(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")), nil
+
+	default:
+		return nil, fmt.Errorf("unknown notifier: %s", kind)
+	}
+}
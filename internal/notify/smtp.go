@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Emails reminders through an SMTP server, e.g. a local test server like MailHog
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// Authenticates with PLAIN auth when username is set. net/smtp only sends
+// the password over TLS, or to a server on localhost.
+func NewSMTPNotifier(addr, from, username, password string) *SMTPNotifier {
+	n := &SMTPNotifier{addr: addr, from: from}
+
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return n
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return smtp.SendMail(n.addr, n.auth, n.from, []string{msg.User.Email}, n.message(msg))
+}
+
+// Keeps task titles from adding headers of their own
+var headerReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+// Builds a plain text email with CRLF line endings
+func (n *SMTPNotifier) message(msg Message) []byte {
+	headers := []string{
+		"From: " + n.from,
+		"To: " + msg.User.Email,
+		"Subject: " + mime.QEncoding.Encode("utf-8", headerReplacer.Replace(msg.Subject())),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+
+	body := strings.ReplaceAll(msg.Text(), "\n", "\r\n")
+
+	return []byte(fmt.Sprintf("%s\r\n\r\n%s\r\n", strings.Join(headers, "\r\n"), body))
+}
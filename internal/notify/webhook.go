@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Posts reminders as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// Body of a reminder webhook request
+type webhookPayload struct {
+	Event    string         `json:"event"`
+	UserID   uuid.UUID      `json:"user_id"`
+	Email    string         `json:"email"`
+	Subject  string         `json:"subject"`
+	Text     string         `json:"text"`
+	Reminder utils.Reminder `json:"reminder"`
+	Task     utils.Task     `json:"task"`
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Any response status other than 2xx counts as a failed delivery
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(webhookPayload{
+		Event:    "reminder",
+		UserID:   msg.User.ID,
+		Email:    msg.User.Email,
+		Subject:  msg.Subject(),
+		Text:     msg.Text(),
+		Reminder: msg.Reminder,
+		Task:     msg.Task,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+// Package policy decides which permissions the users of the API have. A
+// policy gives permissions to roles, a subject (the user making a request)
+// has the role stored on their account and the roles the policy binds to
+// them. Everything that is not granted is denied.
+//
+// Every authenticated route declares the permission it needs with a
+// Requirement, so the decision never depends on the handler itself.
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// A permission is "<resource>:<action>", e.g. task:read
+type Permission string
+
+// Something the permissions are about, each has a read and a write permission
+type Resource string
+
+const (
+	Tasks      Resource = "task"
+	Projects   Resource = "project"
+	Tags       Resource = "tag"
+	Shares     Resource = "share"
+	Workspaces Resource = "workspace"
+	Webhooks   Resource = "webhook"
+	// The account of the user, their profile and sessions
+	Users Resource = "user"
+)
+
+var resources = []Resource{Tasks, Projects, Tags, Shares, Workspaces, Webhooks, Users}
+
+// Managing every user and task, not only the subject's own
+const UserAdmin Permission = "user:admin"
+
+func (r Resource) Read() Permission {
+	return Permission(string(r) + ":read")
+}
+
+func (r Resource) Write() Permission {
+	return Permission(string(r) + ":write")
+}
+
+// Reports whether the permission is one that routes can require
+func (p Permission) Known() bool {
+	if p == UserAdmin {
+		return true
+	}
+
+	for _, resource := range resources {
+		if p == resource.Read() || p == resource.Write() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether the granted permission covers p. A grant can end in a
+// wildcard, "task:*" covers every task permission and "*" every permission.
+func covers(grant string, p Permission) bool {
+	if grant == "*" {
+		return true
+	}
+
+	if prefix, ok := strings.CutSuffix(grant, "*"); ok {
+		return strings.HasPrefix(string(p), prefix)
+	}
+
+	return grant == string(p)
+}
+
+// The permission a route needs, Read for GET and HEAD requests and Write for the other methods
+type Requirement struct {
+	Read  Permission
+	Write Permission
+}
+
+// The read and write permissions of the resource
+func ReadWrite(resource Resource) Requirement {
+	return Requirement{Read: resource.Read(), Write: resource.Write()}
+}
+
+// The same permission for every method
+func Require(p Permission) Requirement {
+	return Requirement{Read: p, Write: p}
+}
+
+func (req Requirement) For(method string) Permission {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return req.Read
+	default:
+		return req.Write
+	}
+}
+
+// Who a decision is made for
+type Subject struct {
+	ID    string
+	Roles []string
+}
+
+// The subject of an authenticated user, with the role of their account
+func UserSubject(user utils.User) Subject {
+	return Subject{ID: user.ID.String(), Roles: []string{string(user.Role)}}
+}
+
+// The policy file, e.g.
+//
+//	{
+//	    "roles": {
+//	        "user": ["task:*", "user:read", "user:write"],
+//	        "admin": ["*"]
+//	    },
+//	    "subjects": {
+//	        "1e2918cd-d27f-47e7-8318-cfd4d7056617": ["admin"]
+//	    }
+//	}
+//
+// roles maps a role to the permissions it grants, subjects gives roles to
+// users by their ID in addition to the role of their account.
+type Policy struct {
+	Roles    map[string][]string `json:"roles"`
+	Subjects map[string][]string `json:"subjects"`
+}
+
+//go:embed default.json
+var defaultPolicy []byte
+
+// The policy used without POLICY_FILE. Users have every permission on their
+// own data, admins also have user:admin.
+func Default() *Policy {
+	policy, err := Parse(defaultPolicy)
+	if err != nil {
+		panic("invalid default policy: " + err.Error())
+	}
+
+	return policy
+}
+
+// Reads the policy from the file in POLICY_FILE, or the default policy when it is not set
+func FromEnv() (*Policy, error) {
+	path := os.Getenv("POLICY_FILE")
+	if path == "" {
+		return Default(), nil
+	}
+
+	return Load(path)
+}
+
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+// Parses and validates a policy. Unknown fields, permissions and roles are
+// errors, a typo would otherwise silently deny.
+func Parse(data []byte) (*Policy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+func (p *Policy) validate() error {
+	for role, grants := range p.Roles {
+		for _, grant := range grants {
+			if !validGrant(grant) {
+				return fmt.Errorf("invalid policy: unknown permission %q for role %s", grant, role)
+			}
+		}
+	}
+
+	for subject, roles := range p.Subjects {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("invalid policy: unknown role %q for subject %s", role, subject)
+			}
+		}
+	}
+
+	return nil
+}
+
+// A grant must cover at least one known permission
+func validGrant(grant string) bool {
+	if covers(grant, UserAdmin) {
+		return true
+	}
+
+	for _, resource := range resources {
+		if covers(grant, resource.Read()) || covers(grant, resource.Write()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether any role of the subject grants the permission. Unknown
+// roles grant nothing and unknown permissions are never granted.
+func (p *Policy) Allows(subject Subject, permission Permission) bool {
+	if !permission.Known() {
+		return false
+	}
+
+	roles := append(append([]string(nil), subject.Roles...), p.Subjects[subject.ID]...)
+	for _, role := range roles {
+		for _, grant := range p.Roles[role] {
+			if covers(grant, permission) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
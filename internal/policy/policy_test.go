@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+const testPolicy = `{
+    "roles": {
+        "user": ["task:*", "project:read", "user:read", "user:write"],
+        "admin": ["*"],
+        "hooks": ["webhook:*"],
+        "empty": []
+    },
+    "subjects": {
+        "bound-user": ["hooks"]
+    }
+}`
+
+func mustParse(t *testing.T, data string) *Policy {
+	t.Helper()
+
+	policy, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return policy
+}
+
+func TestParseRejectsInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		err    string
+	}{
+		{"unknown permission", `{"roles": {"user": ["task:reed"]}}`, `unknown permission "task:reed"`},
+		{"unknown resource", `{"roles": {"user": ["calendar:read"]}}`, `unknown permission "calendar:read"`},
+		{"wildcard matching nothing", `{"roles": {"user": ["calendar:*"]}}`, `unknown permission "calendar:*"`},
+		{"unknown role", `{"roles": {"user": []}, "subjects": {"someone": ["ghost"]}}`, `unknown role "ghost"`},
+		{"unknown field", `{"rolez": {}}`, `unknown field "rolez"`},
+		{"unknown nested field", `{"roles": {}, "users": {}}`, `unknown field "users"`},
+		{"malformed JSON", `{"roles": `, "invalid policy"},
+		{"wrong type", `{"roles": {"user": "task:read"}}`, "invalid policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.policy))
+			if err == nil {
+				t.Fatal("policy was accepted")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %q, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	policy := Default()
+
+	user := Subject{ID: uuid.NewString(), Roles: []string{string(utils.UserRegular)}}
+	admin := Subject{ID: uuid.NewString(), Roles: []string{string(utils.UserAdmin)}}
+
+	for _, resource := range resources {
+		for _, permission := range []Permission{resource.Read(), resource.Write()} {
+			if !policy.Allows(user, permission) {
+				t.Errorf("user is denied %s", permission)
+			}
+			if !policy.Allows(admin, permission) {
+				t.Errorf("admin is denied %s", permission)
+			}
+		}
+	}
+
+	if policy.Allows(user, UserAdmin) {
+		t.Error("user is allowed user:admin")
+	}
+	if !policy.Allows(admin, UserAdmin) {
+		t.Error("admin is denied user:admin")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	policy := mustParse(t, testPolicy)
+
+	tests := []struct {
+		name       string
+		subject    Subject
+		permission Permission
+		want       bool
+	}{
+		{"granted", Subject{Roles: []string{"user"}}, Projects.Read(), true},
+		{"not granted", Subject{Roles: []string{"user"}}, Projects.Write(), false},
+		{"resource wildcard read", Subject{Roles: []string{"user"}}, Tasks.Read(), true},
+		{"resource wildcard write", Subject{Roles: []string{"user"}}, Tasks.Write(), true},
+		{"resource wildcard other resource", Subject{Roles: []string{"user"}}, Tags.Read(), false},
+		{"resource wildcard user:admin", Subject{Roles: []string{"user"}}, UserAdmin, false},
+		{"full wildcard", Subject{Roles: []string{"admin"}}, Webhooks.Write(), true},
+		{"full wildcard user:admin", Subject{Roles: []string{"admin"}}, UserAdmin, true},
+		{"role without permissions", Subject{Roles: []string{"empty"}}, Tasks.Read(), false},
+		{"unknown role", Subject{Roles: []string{"superuser"}}, Tasks.Read(), false},
+		{"no roles", Subject{}, Tasks.Read(), false},
+		{"unknown permission", Subject{Roles: []string{"admin"}}, "calendar:read", false},
+		{"empty permission", Subject{Roles: []string{"admin"}}, "", false},
+		{"any role grants", Subject{Roles: []string{"superuser", "user"}}, Tasks.Read(), true},
+		{"bound role", Subject{ID: "bound-user", Roles: []string{"user"}}, Webhooks.Write(), true},
+		{"bound role without account role", Subject{ID: "bound-user"}, Webhooks.Read(), true},
+		{"bound role is not shared", Subject{ID: "other-user", Roles: []string{"user"}}, Webhooks.Write(), false},
+		{"bound role adds only its permissions", Subject{ID: "bound-user"}, Tasks.Read(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allows(tt.subject, tt.permission); got != tt.want {
+				t.Errorf("Allows(%+v, %q) = %v, want %v", tt.subject, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserSubject(t *testing.T) {
+	policy := mustParse(t, testPolicy)
+
+	user := utils.User{ID: uuid.New(), Role: utils.UserRegular}
+	if !policy.Allows(UserSubject(user), Tasks.Write()) {
+		t.Error("user is denied task:write")
+	}
+
+	user.Role = "suspended"
+	if policy.Allows(UserSubject(user), Tasks.Read()) {
+		t.Error("user with an unknown role is allowed task:read")
+	}
+}
+
+func TestRequirementFor(t *testing.T) {
+	tests := []struct {
+		requirement Requirement
+		method      string
+		want        Permission
+	}{
+		{ReadWrite(Tasks), http.MethodGet, Tasks.Read()},
+		{ReadWrite(Tasks), http.MethodHead, Tasks.Read()},
+		{ReadWrite(Tasks), http.MethodPost, Tasks.Write()},
+		{ReadWrite(Tasks), http.MethodPut, Tasks.Write()},
+		{ReadWrite(Tasks), http.MethodPatch, Tasks.Write()},
+		{ReadWrite(Tasks), http.MethodDelete, Tasks.Write()},
+		{ReadWrite(Users), http.MethodPost, Users.Write()},
+		{Require(UserAdmin), http.MethodGet, UserAdmin},
+		{Require(UserAdmin), http.MethodDelete, UserAdmin},
+	}
+
+	for _, tt := range tests {
+		if got := tt.requirement.For(tt.method); got != tt.want {
+			t.Errorf("%+v.For(%s) = %q, want %q", tt.requirement, tt.method, got, tt.want)
+		}
+	}
+}
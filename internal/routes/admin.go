@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// handler for /admin/users && /admin/users/{account_id} endpoints
+func (s *APIServer) handleAdminUsers(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["account_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetUsers(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+
+	switch r.Method {
+	case "GET":
+		return s.handleAdminGetUser(w, r)
+	case "DELETE":
+		return s.handleAdminDeleteUser(w, r)
+	default:
+		return utils.MethodNotAllowed(r.Method)
+	}
+}
+
+// handler for the /admin/tasks endpoint
+func (s *APIServer) handleAdminTasks(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetTasks(w, r)
+	default:
+		return utils.MethodNotAllowed(r.Method)
+	}
+}
+
+// Loads the user the admin route is for
+func (s *APIServer) getAccount(r *http.Request) (utils.User, error) {
+	id, err := utils.GetAccountID(r)
+	if err != nil {
+		return utils.User{}, err
+	}
+
+	return s.store.GetUserById(id)
+}
+
+func (s *APIServer) handleAdminGetUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.getAccount(r)
+	if err != nil {
+		return err
+	}
+
+	if notModified(w, r, user.Version) {
+		return nil
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, user)
+}
+
+// The last admin cannot be deleted, another user has to be made an admin first
+func (s *APIServer) checkLastAdmin(user utils.User) error {
+	if user.Role != utils.UserAdmin {
+		return nil
+	}
+
+	users, err := s.store.GetUsers()
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(users, func(other utils.User) bool {
+		return other.Role == utils.UserAdmin && other.ID != user.ID
+	}) {
+		return utils.Conflict("the last admin cannot be deleted, make another user an admin first")
+	}
+
+	return nil
+}
+
+// Deletes any user along with everything they own, like DELETE /me does for the users themselves
+func (s *APIServer) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.getAccount(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkIfMatch(r, user.Version); err != nil {
+		return err
+	}
+
+	if err := s.checkOwnedWorkspaces(user.ID); err != nil {
+		return err
+	}
+
+	if err := s.checkLastAdmin(user); err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteUser(user.ID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": user.ID})
+}
+
+// Grants or revokes the admin role. Admins cannot change their own role so
+// that the last admin cannot lock everyone out.
+func (s *APIServer) handleAdminUserRole(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "PUT" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	admin, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getAccount(r)
+	if err != nil {
+		return err
+	}
+
+	if user.ID == admin.ID {
+		return utils.Conflict("admins cannot change their own role")
+	}
+
+	req := new(utils.UserRoleRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	if err := s.checkIfMatch(r, user.Version); err != nil {
+		return err
+	}
+
+	if err := s.store.SetUserRole(user.ID, utils.UserRole(req.Role)); err != nil {
+		return err
+	}
+
+	updated, err := s.store.GetUserById(user.ID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", etag(updated.Version))
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,100 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func setUserRole(t *testing.T, h *apitest.Harness, adminToken string, id uuid.UUID, role utils.UserRole) {
+	t.Helper()
+
+	var updated utils.User
+	if status := h.Do("PUT", "/admin/users/"+id.String()+"/role", adminToken, utils.UserRoleRequest{Role: string(role)}, &updated); status != http.StatusOK || updated.Role != role {
+		t.Fatalf("change role to %s: status %d, user %+v", role, status, updated)
+	}
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		admin, adminToken := h.RegisterAndLogin("admin", "admin@tasklist.com", "Example1")
+		user, userToken := h.RegisterAndLogin("user", "user@tasklist.com", "Example1")
+		if err := h.Store.SetUserRole(admin.ID, utils.UserAdmin); err != nil {
+			t.Fatal(err)
+		}
+		createTask(t, h, userToken, map[string]any{"title": "Math homework", "deadline": "2030-12-01"})
+
+		paths := []accessCase{
+			{"GET", "/users", nil, 0},
+			{"GET", "/admin/users", nil, 0},
+			{"GET", "/admin/users/" + admin.ID.String(), nil, 0},
+			{"DELETE", "/admin/users/" + admin.ID.String(), nil, 0},
+			{"PUT", "/admin/users/" + user.ID.String() + "/role", utils.UserRoleRequest{Role: "admin"}, 0},
+			{"GET", "/admin/tasks", nil, 0},
+		}
+		withStatus := func(status int) []accessCase {
+			tests := append([]accessCase(nil), paths...)
+			for i := range tests {
+				tests[i].want = status
+			}
+			return tests
+		}
+
+		checkAccess(t, h, "anonymous", "", withStatus(http.StatusUnauthorized))
+		checkAccess(t, h, "user", userToken, withStatus(http.StatusForbidden))
+
+		var users []utils.User
+		if status := h.Do("GET", "/admin/users", adminToken, nil, &users); status != http.StatusOK || len(users) != 2 {
+			t.Errorf("admin listing users: status %d, %d users", status, len(users))
+		}
+		var tasks []utils.Task
+		if status := h.Do("GET", "/admin/tasks", adminToken, nil, &tasks); status != http.StatusOK || len(tasks) != 1 || tasks[0].UserID != user.ID {
+			t.Errorf("admin listing tasks: status %d, tasks %+v", status, tasks)
+		}
+
+		if status := h.Do("DELETE", "/admin/users/"+user.ID.String(), adminToken, nil, nil); status != http.StatusOK {
+			t.Fatalf("admin deleting a user: status %d", status)
+		}
+		if _, err := h.Store.GetUserById(user.ID); err == nil {
+			t.Error("deleted user still exists")
+		}
+	})
+}
+
+// The role is read on every request, granting or revoking it applies right away
+func TestAdminRoleChanges(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		first, firstToken := h.RegisterAndLogin("first", "first@tasklist.com", "Example1")
+		second, secondToken := h.RegisterAndLogin("second", "second@tasklist.com", "Example1")
+		if err := h.Store.SetUserRole(first.ID, utils.UserAdmin); err != nil {
+			t.Fatal(err)
+		}
+
+		checkAccess(t, h, "user", secondToken, []accessCase{{"GET", "/admin/users", nil, http.StatusForbidden}})
+		setUserRole(t, h, firstToken, second.ID, utils.UserAdmin)
+		checkAccess(t, h, "promoted user", secondToken, []accessCase{{"GET", "/admin/users", nil, http.StatusOK}})
+
+		setUserRole(t, h, secondToken, first.ID, utils.UserRegular)
+		checkAccess(t, h, "demoted admin", firstToken, []accessCase{
+			{"GET", "/admin/users", nil, http.StatusForbidden},
+			{"PUT", "/admin/users/" + second.ID.String() + "/role", utils.UserRoleRequest{Role: "user"}, http.StatusForbidden},
+		})
+
+		// The last admin can neither step down nor delete their account
+		checkAccess(t, h, "last admin", secondToken, []accessCase{
+			{"PUT", "/admin/users/" + second.ID.String() + "/role", utils.UserRoleRequest{Role: "user"}, http.StatusConflict},
+			{"DELETE", "/admin/users/" + second.ID.String(), nil, http.StatusConflict},
+			{"DELETE", "/me", nil, http.StatusConflict},
+		})
+		checkAccess(t, h, "last admin", secondToken, []accessCase{{"GET", "/admin/users", nil, http.StatusOK}})
+
+		setUserRole(t, h, secondToken, first.ID, utils.UserAdmin)
+		if status := h.Do("DELETE", "/me", secondToken, nil, nil); status != http.StatusOK {
+			t.Errorf("admin deleting their account with another admin left: status %d", status)
+		}
+		checkAccess(t, h, "remaining admin", firstToken, []accessCase{{"GET", "/admin/users", nil, http.StatusOK}})
+	})
+}
@@ -0,0 +1,113 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func login(t *testing.T, h *apitest.Harness, email, password string) utils.LoginResponse {
+	t.Helper()
+
+	var res utils.LoginResponse
+	if status := h.Do("POST", "/login", "", utils.LoginRequest{Email: email, Password: password}, &res); status != http.StatusOK {
+		t.Fatalf("login %s: status %d", email, status)
+	}
+
+	return res
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		register := utils.RegisterUserRequest{Username: "example", Email: "example@tasklist.com", Password: "Example1"}
+		if status := h.Do("POST", "/register", "", register, nil); status != http.StatusOK {
+			t.Fatalf("register: status %d", status)
+		}
+
+		var apiErr utils.APIError
+		if status := h.Do("POST", "/register", "", register, &apiErr); status != http.StatusConflict || apiErr.Code != utils.CodeConflict {
+			t.Errorf("duplicate email: status %d, code %q", status, apiErr.Code)
+		}
+
+		wrong := utils.LoginRequest{Email: register.Email, Password: "Wrong-password"}
+		if status := h.Do("POST", "/login", "", wrong, nil); status != http.StatusUnauthorized {
+			t.Errorf("wrong password: status %d", status)
+		}
+
+		unknown := utils.LoginRequest{Email: "nobody@tasklist.com", Password: register.Password}
+		if status := h.Do("POST", "/login", "", unknown, nil); status != http.StatusUnauthorized {
+			t.Errorf("unknown email: status %d", status)
+		}
+
+		res := login(t, h, register.Email, register.Password)
+		if res.Token == "" || res.RefreshToken == "" || res.ExpiresIn <= 0 {
+			t.Fatalf("login response %+v", res)
+		}
+
+		var me utils.User
+		if status := h.Do("GET", "/me", res.Token, nil, &me); status != http.StatusOK || me.Email != register.Email {
+			t.Errorf("GET /me: status %d, user %+v", status, me)
+		}
+
+		if status := h.Do("GET", "/me", "", nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("GET /me without a token: status %d", status)
+		}
+		if status := h.Do("GET", "/me", "not-a-token", nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("GET /me with an invalid token: status %d", status)
+		}
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+		first := login(t, h, "example@tasklist.com", "Example1")
+
+		var refreshed utils.TokenResponse
+		if status := h.Do("POST", "/token/refresh", "", utils.RefreshRequest{RefreshToken: first.RefreshToken}, &refreshed); status != http.StatusOK {
+			t.Fatalf("refresh: status %d", status)
+		}
+		if refreshed.RefreshToken == first.RefreshToken {
+			t.Fatal("refresh token was not rotated")
+		}
+
+		if status := h.Do("GET", "/me", refreshed.Token, nil, nil); status != http.StatusOK {
+			t.Fatalf("GET /me with the refreshed token: status %d", status)
+		}
+
+		// Reusing a rotated refresh token revokes the session
+		if status := h.Do("POST", "/token/refresh", "", utils.RefreshRequest{RefreshToken: first.RefreshToken}, nil); status != http.StatusUnauthorized {
+			t.Errorf("reused refresh token: status %d", status)
+		}
+		if status := h.Do("GET", "/me", refreshed.Token, nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("GET /me after reuse: status %d", status)
+		}
+		if status := h.Do("POST", "/token/refresh", "", utils.RefreshRequest{RefreshToken: refreshed.RefreshToken}, nil); status != http.StatusUnauthorized {
+			t.Errorf("refresh after reuse: status %d", status)
+		}
+
+		if status := h.Do("POST", "/token/refresh", "", utils.RefreshRequest{RefreshToken: "unknown"}, nil); status != http.StatusUnauthorized {
+			t.Errorf("unknown refresh token: status %d", status)
+		}
+	})
+}
+
+func TestLogout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+		other := login(t, h, "example@tasklist.com", "Example1")
+
+		if status := h.Do("POST", "/logout", token, nil, nil); status != http.StatusOK {
+			t.Fatalf("logout: status %d", status)
+		}
+
+		if status := h.Do("GET", "/me", token, nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("GET /me after logout: status %d", status)
+		}
+		if status := h.Do("GET", "/me", other.Token, nil, nil); status != http.StatusOK {
+			t.Errorf("GET /me in another session: status %d", status)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// handler for /me/tasks/{task_id}/checklist && /me/tasks/{task_id}/checklist/{item_id} endpoints
+func (s *APIServer) handleChecklist(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["item_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetChecklist(w, r)
+		case "POST":
+			return s.handleAddChecklistItem(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "PUT":
+			return s.handleUpdateChecklistItem(w, r)
+		case "DELETE":
+			return s.handleDeleteChecklistItem(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetChecklist(w http.ResponseWriter, r *http.Request) error {
+	ownerID, taskID, err := s.taskOwner(r, utils.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	items, err := s.store.GetChecklist(ownerID, taskID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, items)
+}
+
+// Appends an item to the end of the checklist
+func (s *APIServer) handleAddChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	ownerID, taskID, err := s.taskOwner(r, utils.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ChecklistItemBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	item := &utils.ChecklistItem{TaskID: taskID}
+	item.ModifyChecklistItem(req)
+	if err := utils.Validate(item); err != nil {
+		return err
+	}
+
+	if err := s.store.AddChecklistItem(ownerID, item); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, item)
+}
+
+// Renames or ticks an item, responds with the updated item
+func (s *APIServer) handleUpdateChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	ownerID, taskID, err := s.taskOwner(r, utils.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetChecklistItemID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ChecklistItemBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	item, err := s.store.GetChecklistItem(ownerID, taskID, id)
+	if err != nil {
+		return err
+	}
+
+	item.ModifyChecklistItem(req)
+	if err := s.store.UpdateChecklistItem(ownerID, taskID, id, item); err != nil {
+		return err
+	}
+
+	updated, err := s.store.GetChecklistItem(ownerID, taskID, id)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+func (s *APIServer) handleDeleteChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	ownerID, taskID, err := s.taskOwner(r, utils.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetChecklistItemID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteChecklistItem(ownerID, taskID, id); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
+}
+
+// handler for /me/tasks/{task_id}/checklist/order, responds with the reordered checklist
+func (s *APIServer) handleReorderChecklist(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "PUT" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	ownerID, taskID, err := s.taskOwner(r, utils.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ReorderChecklistRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := s.store.ReorderChecklist(ownerID, taskID, req.ItemIDs); err != nil {
+		return err
+	}
+
+	items, err := s.store.GetChecklist(ownerID, taskID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, items)
+}
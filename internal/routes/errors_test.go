@@ -0,0 +1,81 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Every error is sent as {"error": ..., "code": ..., "details": ...} with a JSON content type
+func TestErrorEnvelope(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   string
+			status int
+			code   utils.ErrorCode
+			field  string
+		}{
+			{"unauthenticated", "GET", "/me/tasks", "", "", http.StatusUnauthorized, utils.CodeUnauthorized, ""},
+			{"unknown task", "GET", "/me/tasks/" + uuid.NewString(), token, "", http.StatusNotFound, utils.CodeNotFound, ""},
+			{"invalid task ID", "GET", "/me/tasks/not-a-uuid", token, "", http.StatusBadRequest, utils.CodeBadRequest, ""},
+			{"malformed JSON", "POST", "/me/tasks", token, `{"title":`, http.StatusBadRequest, utils.CodeBadRequest, ""},
+			{"validation", "POST", "/me/tasks", token, `{"title":"abc","deadline":"2030-12-01"}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed, "title"},
+			{"method", "PATCH", "/me/tasks", token, "", http.StatusMethodNotAllowed, utils.CodeMethodNotAllowed, ""},
+			{"forbidden", "GET", "/admin/tasks", token, "", http.StatusForbidden, utils.CodeForbidden, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := http.NewRequest(tt.method, h.Server.URL+tt.path, strings.NewReader(tt.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Content-Type", "application/json")
+				if tt.token != "" {
+					req.Header.Set("Authorization", "JWT "+tt.token)
+				}
+
+				res, err := h.Server.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer res.Body.Close()
+
+				if res.StatusCode != tt.status {
+					t.Errorf("status %d, want %d", res.StatusCode, tt.status)
+				}
+				if contentType := res.Header.Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("content type %q", contentType)
+				}
+
+				var body struct {
+					Error   string             `json:"error"`
+					Code    utils.ErrorCode    `json:"code"`
+					Details []utils.FieldError `json:"details"`
+				}
+				if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+					t.Fatalf("decoding error body: %v", err)
+				}
+
+				if body.Code != tt.code || body.Error == "" {
+					t.Errorf("error %q with code %q, want code %q", body.Error, body.Code, tt.code)
+				}
+
+				if tt.field != "" && (len(body.Details) == 0 || body.Details[0].Field != tt.field) {
+					t.Errorf("details %+v, want an error for %s", body.Details, tt.field)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Tasks and users are versioned, the version is their ETag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Reports whether the If-Match or If-None-Match header value lists the ETag.
+// Weak tags match their strong form unless strong comparison is asked for.
+func etagListed(header, tag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak, ok := strings.CutPrefix(candidate, "W/"); ok {
+			if strong {
+				continue
+			}
+			candidate = weak
+		}
+
+		if candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks the If-Match precondition of a PUT or DELETE against the current
+// version of the resource. Without the header the change goes through,
+// unless the server requires it.
+func (s *APIServer) checkIfMatch(r *http.Request, version int64) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if s.RequireIfMatch {
+			return utils.PreconditionRequired("If-Match header required, send the ETag of the last GET")
+		}
+		return nil
+	}
+
+	if !etagListed(header, etag(version), true) {
+		return utils.PreconditionFailed("changed since it was read, reload and try again")
+	}
+
+	return nil
+}
+
+// Sets the ETag of the response. Returns true after responding with
+// 304 Not Modified when the client has the current version already.
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	tag := etag(version)
+	w.Header().Set("ETag", tag)
+
+	if header := r.Header.Get("If-None-Match"); header != "" && etagListed(header, tag, false) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// A concurrent change between the If-Match check and saving fails the
+// precondition too, without If-Match it is reported as a conflict
+func versionConflict(r *http.Request, err error) error {
+	if errors.Is(err, db.ErrVersionConflict) && r.Header.Get("If-Match") != "" {
+		return utils.PreconditionFailed("changed since it was read, reload and try again")
+	}
+
+	return err
+}
@@ -0,0 +1,277 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sunikka/tasklist-backendGo/internal/auth"
+	"github.com/sunikka/tasklist-backendGo/internal/events"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+const (
+	// Keeps idle connections open through proxies, the session is also checked this often
+	heartbeatInterval = 25 * time.Second
+	wsWriteTimeout    = 10 * time.Second
+)
+
+// Sent instead of the missed events when a client cannot be resumed, it has to reload its data
+const eventReset = "reset"
+
+var upgrader = websocket.Upgrader{
+	// The connection is authenticated with the access token rather than
+	// cookies, so other sites cannot open it on the users behalf
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Passes the event to the event streams of the user and queues it for their webhooks.
+// The change has been saved already, so a failure is logged instead of failing the request.
+func (s *APIServer) emit(userID uuid.UUID, event string, data any) {
+	if err := s.events.Publish(userID, event, data); err != nil {
+		log.Printf("Publishing %s event failed: %v", event, err)
+	}
+
+	s.enqueueWebhooks(userID, event, data)
+}
+
+// Passes an event about the task to the users who can see it
+func (s *APIServer) emitTask(task utils.Task, event string, data any) {
+	recipients, err := s.taskRecipients(task)
+	if err != nil {
+		log.Printf("Publishing %s event failed: %v", event, err)
+		return
+	}
+
+	s.emitAll(recipients, event, data)
+}
+
+func (s *APIServer) emitAll(userIDs []uuid.UUID, event string, data any) {
+	for _, userID := range userIDs {
+		s.emit(userID, event, data)
+	}
+}
+
+// The users who can see the task: every member of its workspace, otherwise
+// its owner and the users who have accepted a share of it or its project.
+// Deleting a task deletes its shares, so the recipients of a deletion are
+// looked up before it.
+func (s *APIServer) taskRecipients(task utils.Task) ([]uuid.UUID, error) {
+	if task.WorkspaceID != nil {
+		members, err := s.store.GetWorkspaceMembers(*task.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients := make([]uuid.UUID, 0, len(members))
+		for _, member := range members {
+			recipients = append(recipients, member.UserID)
+		}
+		return recipients, nil
+	}
+
+	shares, err := s.store.GetTaskShares(task.UserID, task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.ProjectID != nil {
+		projectShares, err := s.store.GetProjectShares(task.UserID, *task.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, projectShares...)
+	}
+
+	recipients := []uuid.UUID{task.UserID}
+	for _, share := range shares {
+		if share.Status == utils.ShareAccepted && share.UserID != nil && !slices.Contains(recipients, *share.UserID) {
+			recipients = append(recipients, *share.UserID)
+		}
+	}
+
+	return recipients, nil
+}
+
+// handler for the /me/events Server-Sent Events stream
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	lastIDStr := r.Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = r.URL.Query().Get("last_event_id")
+	}
+	lastID, err := parseLastEventID(lastIDStr)
+	if err != nil {
+		return err
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming not supported by %T", w)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(e events.Event) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		if e.Event == eventReset {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Event, body)
+		} else {
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Event, body)
+		}
+		flusher.Flush()
+		return err
+	}
+
+	ping := func() error {
+		_, err := fmt.Fprint(w, ": ping\n\n")
+		flusher.Flush()
+		return err
+	}
+
+	s.streamEvents(r, lastID, send, ping)
+	return nil
+}
+
+// handler for /me/events/ws, the same events as /me/events over a WebSocket.
+// Every event is sent as a JSON text message, a client resumes with ?last_event_id=.
+func (s *APIServer) handleEventsWebSocket(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	lastID, err := parseLastEventID(r.URL.Query().Get("last_event_id"))
+	if err != nil {
+		return err
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded with the error
+		return nil
+	}
+	defer conn.Close()
+
+	// Clients only send control frames, reading handles them and notices a closed connection
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	send := func(e events.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(e)
+	}
+
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+	}
+
+	s.streamEvents(r.WithContext(ctx), lastID, send, ping)
+
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteTimeout))
+	return nil
+}
+
+// Sends the events of the authenticated user until the client disconnects,
+// falls behind or their session ends
+func (s *APIServer) streamEvents(r *http.Request, lastID *uint64, send func(events.Event) error, ping func() error) {
+	user, err := currentUser(r)
+	if err != nil {
+		return
+	}
+	sessionID, _ := auth.SessionIDFromContext(r.Context())
+
+	sub, replay, complete := s.events.Subscribe(user.ID, lastID)
+	defer sub.Close()
+
+	if !complete {
+		if err := send(events.Event{Event: eventReset, CreatedAt: time.Now().UTC().Truncate(time.Second), Data: json.RawMessage("{}")}); err != nil {
+			return
+		}
+	}
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case e, ok := <-sub.C:
+			if !ok {
+				// Fell behind, the client resumes from its last event on reconnect
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			session, err := s.store.GetSession(sessionID)
+			if err != nil || !session.Active() {
+				return
+			}
+			if err := ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Parses the ID of the last event a client received, nil if s is empty
+func parseLastEventID(s string) (*uint64, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, utils.BadRequest("invalid last event ID: " + s)
+	}
+
+	return &id, nil
+}
@@ -0,0 +1,171 @@
+package routes_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+type streamEvent struct {
+	ID    string
+	Event string
+	Task  utils.Task
+}
+
+// Event stream of a user, read until the test ends
+type eventStream struct {
+	t      *testing.T
+	events chan streamEvent
+}
+
+func openEvents(t *testing.T, h *apitest.Harness, token, lastID string) *eventStream {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", h.Server.URL+"/me/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "JWT "+token)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+
+	res, err := h.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("events: status %d, content type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	stream := &eventStream{t: t, events: make(chan streamEvent, 16)}
+	go func() {
+		defer close(stream.events)
+
+		var e streamEvent
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				e.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var body struct {
+					Data utils.Task `json:"data"`
+				}
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &body)
+				e.Task = body.Data
+			case line == "" && e.Event != "":
+				stream.events <- e
+				e = streamEvent{}
+			}
+		}
+	}()
+
+	return stream
+}
+
+func (s *eventStream) next() streamEvent {
+	s.t.Helper()
+
+	select {
+	case e, ok := <-s.events:
+		if !ok {
+			s.t.Fatal("event stream ended")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no event within 5 seconds")
+		return streamEvent{}
+	}
+}
+
+// Events are delivered in order, so when the users own task is the next
+// event they have not been sent anything about the tasks changed before it
+func (s *eventStream) expectOwnTaskNext(h *apitest.Harness, token string) {
+	s.t.Helper()
+
+	marker := createTask(s.t, h, token, map[string]any{"title": "Marker task", "deadline": "2030-12-01"})
+	if e := s.next(); e.Event != utils.EventTaskCreated || e.Task.ID != marker.ID {
+		s.t.Fatalf("got %s of task %s %q before the users own task", e.Event, e.Task.ID, e.Task.Title)
+	}
+}
+
+func TestEventsOfOtherUsersAreNotDelivered(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, otherToken := h.RegisterAndLogin("other", "other@tasklist.com", "Example1")
+
+		stream := openEvents(t, h, otherToken, "")
+
+		task := createTask(t, h, ownerToken, map[string]any{"title": "Private task", "deadline": "2030-12-01"})
+		path := "/me/tasks/" + task.ID.String()
+		stream.expectOwnTaskNext(h, otherToken)
+
+		// A pending invitation does not give access to the events yet
+		var share utils.Share
+		if status := h.Do("POST", path+"/shares", ownerToken, utils.ShareRequest{Email: "other@tasklist.com", Role: "viewer"}, &share); status != http.StatusOK {
+			t.Fatalf("share: status %d", status)
+		}
+		if status := h.Do("PUT", path, ownerToken, map[string]any{"title": "Renamed while pending"}, nil); status != http.StatusOK {
+			t.Fatalf("update: status %d", status)
+		}
+		stream.expectOwnTaskNext(h, otherToken)
+
+		if status := h.Do("POST", "/me/invitations/"+share.ID.String()+"/accept", otherToken, nil, nil); status != http.StatusOK {
+			t.Fatalf("accept: status %d", status)
+		}
+		if status := h.Do("PUT", path, ownerToken, map[string]any{"title": "Renamed when shared"}, nil); status != http.StatusOK {
+			t.Fatalf("update: status %d", status)
+		}
+		if e := stream.next(); e.Event != utils.EventTaskUpdated || e.Task.ID != task.ID {
+			t.Fatalf("recipient of the share got %s of task %s", e.Event, e.Task.ID)
+		}
+
+		if status := h.Do("DELETE", path+"/shares/"+share.ID.String(), ownerToken, nil, nil); status != http.StatusOK {
+			t.Fatalf("revoke: status %d", status)
+		}
+		if status := h.Do("PUT", path, ownerToken, map[string]any{"title": "Renamed when revoked"}, nil); status != http.StatusOK {
+			t.Fatalf("update: status %d", status)
+		}
+		stream.expectOwnTaskNext(h, otherToken)
+	})
+}
+
+func TestEventsResumeAfterLastEventID(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+		_, otherToken := h.RegisterAndLogin("other", "other@tasklist.com", "Example1")
+
+		stream := openEvents(t, h, token, "")
+		first := createTask(t, h, token, map[string]any{"title": "First task", "deadline": "2030-12-01"})
+		received := stream.next()
+		if received.Task.ID != first.ID || received.ID == "" {
+			t.Fatalf("first event %+v", received)
+		}
+
+		// Changes while the client is away are replayed on reconnect, those of other users are not
+		second := createTask(t, h, token, map[string]any{"title": "Second task", "deadline": "2030-12-01"})
+		createTask(t, h, otherToken, map[string]any{"title": "Other users task", "deadline": "2030-12-01"})
+
+		resumed := openEvents(t, h, token, received.ID)
+		if e := resumed.next(); e.Event != utils.EventTaskCreated || e.Task.ID != second.ID {
+			t.Fatalf("replayed %s of task %s, want the second task", e.Event, e.Task.ID)
+		}
+		resumed.expectOwnTaskNext(h, token)
+
+		var apiErr utils.APIError
+		if status := h.Do("GET", "/me/events?last_event_id=latest", token, nil, &apiErr); status != http.StatusBadRequest {
+			t.Errorf("invalid last event ID: status %d", status)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package routes_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Creates tasks that share titles and deadlines, timestamps are stored in whole
+// seconds so most of them also share created_at and updated_at
+func createPagedTasks(t *testing.T, h *apitest.Harness, token string) []uuid.UUID {
+	t.Helper()
+
+	titles := []string{"Alpha task", "Bravo task", "Charlie task"}
+	deadlines := []string{"2030-01-01", "2030-06-01"}
+
+	var ids []uuid.UUID
+	for i := 0; i < 11; i++ {
+		task := createTask(t, h, token, map[string]any{"title": titles[i%len(titles)], "deadline": deadlines[i%len(deadlines)]})
+		ids = append(ids, task.ID)
+	}
+
+	return ids
+}
+
+// Lists the tasks one page at a time by following the cursors
+func listPaged(t *testing.T, h *apitest.Harness, token string, query url.Values) []utils.Task {
+	t.Helper()
+
+	var all []utils.Task
+	for page := 0; ; page++ {
+		if page > 20 {
+			t.Fatal("paging does not end")
+		}
+
+		res := h.Request("GET", "/me/tasks?"+query.Encode(), token, nil)
+		var tasks []utils.Task
+		err := json.NewDecoder(res.Body).Decode(&tasks)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || err != nil {
+			t.Fatalf("page %d: status %d, %v", page, res.StatusCode, err)
+		}
+		all = append(all, tasks...)
+
+		cursor := res.Header.Get("X-Next-Cursor")
+		if cursor == "" {
+			return all
+		}
+		query.Set("cursor", cursor)
+	}
+}
+
+// Compares tasks in the order of the listing, ties are broken by task ID
+func compareListed(a, b utils.Task, sort utils.TaskSort) int {
+	var c int
+	switch sort {
+	case utils.SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	case utils.SortDeadline:
+		c = a.Deadline.Compare(b.Deadline)
+	case utils.SortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID.String(), b.ID.String())
+}
+
+func taskIDs(tasks []utils.Task) []uuid.UUID {
+	ids := make([]uuid.UUID, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestPaginationHasNoGapsOrRepeats(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+		created := createPagedTasks(t, h, token)
+
+		for _, sort := range []utils.TaskSort{utils.SortCreatedAt, utils.SortUpdatedAt, utils.SortDeadline, utils.SortTitle} {
+			for _, order := range []string{"asc", "desc"} {
+				t.Run(string(sort)+" "+order, func(t *testing.T) {
+					query := url.Values{"sort": {string(sort)}, "order": {order}}
+
+					// The whole listing on one page is the order the pages must follow
+					var want []utils.Task
+					if status := h.Do("GET", "/me/tasks?"+query.Encode()+"&limit=100", token, nil, &want); status != http.StatusOK {
+						t.Fatalf("list: status %d", status)
+					}
+
+					for i := 1; i < len(want); i++ {
+						c := compareListed(want[i-1], want[i], sort)
+						if order == "desc" {
+							c = -c
+						}
+						if c >= 0 {
+							t.Fatalf("tasks %d and %d are out of order: %+v, %+v", i-1, i, want[i-1], want[i])
+						}
+					}
+
+					for _, limit := range []string{"1", "2", "4"} {
+						query.Set("limit", limit)
+						query.Del("cursor")
+
+						got := taskIDs(listPaged(t, h, token, query))
+						if !slices.Equal(got, taskIDs(want)) {
+							t.Errorf("limit %s: pages list %v, want %v", limit, got, taskIDs(want))
+						}
+					}
+
+					got := taskIDs(want)
+					for _, id := range created {
+						if !slices.Contains(got, id) {
+							t.Errorf("listing of %d tasks is missing %s", len(got), id)
+						}
+					}
+					if len(got) != len(created) {
+						t.Errorf("listing has %d tasks, want the %d created", len(got), len(created))
+					}
+				})
+			}
+		}
+	})
+}
+
+func TestPaginationRejectsInvalidCursors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+		createPagedTasks(t, h, token)
+
+		res := h.Request("GET", "/me/tasks?sort=deadline&limit=2", token, nil)
+		res.Body.Close()
+		cursor := res.Header.Get("X-Next-Cursor")
+		if cursor == "" {
+			t.Fatal("no cursor for the next page")
+		}
+
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tamper := func(old, new string) string {
+			if !strings.Contains(string(raw), old) {
+				t.Fatalf("cursor %s does not contain %s", raw, old)
+			}
+			return base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), old, new, 1)))
+		}
+
+		tests := []struct {
+			name  string
+			query string
+		}{
+			{"not base64", "sort=deadline&cursor=%25%25%25"},
+			{"not JSON", "sort=deadline&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("not json"))},
+			{"truncated", "sort=deadline&cursor=" + cursor[:len(cursor)/2]},
+			{"other sort", "sort=title&cursor=" + cursor},
+			{"other order", "sort=deadline&order=desc&cursor=" + cursor},
+			{"sort changed in the cursor", "sort=deadline&cursor=" + tamper(`"s":"deadline"`, `"s":"title"`)},
+			{"value is not a time", "sort=deadline&cursor=" + tamper(`"v":"`, `"v":"x`)},
+			{"ID is not a UUID", "sort=deadline&cursor=" + tamper(`"id":"`, `"id":"x`)},
+		}
+
+		for _, tt := range tests {
+			var apiErr utils.APIError
+			if status := h.Do("GET", "/me/tasks?"+tt.query, token, nil, &apiErr); status != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want 400", tt.name, status)
+			}
+		}
+	})
+}
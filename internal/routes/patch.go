@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sunikka/tasklist-backendGo/internal/jsonpatch"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Larger patches are rejected before they are parsed
+const maxPatchSize = 1 << 20
+
+// Applies the patch of a request to a JSON document
+type patchFunc func(doc []byte) ([]byte, error)
+
+// Reads the body of a PATCH request. The Content-Type picks the format, a
+// JSON Merge Patch or a JSON Patch.
+func readPatch(w http.ResponseWriter, r *http.Request) (patchFunc, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch mediaType {
+	case jsonpatch.MergePatchType:
+		apply = jsonpatch.MergePatch
+	case jsonpatch.JSONPatchType:
+		apply = jsonpatch.Apply
+	default:
+		w.Header().Set("Accept-Patch", jsonpatch.MergePatchType+", "+jsonpatch.JSONPatchType)
+		return nil, utils.UnsupportedMediaType("Content-Type must be " + jsonpatch.MergePatchType + " or " + jsonpatch.JSONPatchType)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		return nil, utils.BadRequest("invalid patch: " + err.Error())
+	}
+
+	return func(doc []byte) ([]byte, error) {
+		return apply(doc, body)
+	}, nil
+}
+
+// Applies the patch to doc and decodes the result into out, which is
+// validated. Members that are not in doc cannot be added.
+func applyPatch(apply patchFunc, doc, out any) error {
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	patched, err := apply(docJSON)
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return utils.Conflict(err.Error())
+	case errors.Is(err, jsonpatch.ErrNotApplicable):
+		return utils.InvalidField("patch", err.Error())
+	case err != nil:
+		return utils.BadRequest(err.Error())
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return patchDecodeError(err)
+	}
+
+	return utils.Validate(out)
+}
+
+// Reports a patched document that does not fit the resource as a validation error
+func patchDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field == "" {
+			return utils.InvalidField("patch", "the patched document must be an object")
+		}
+		return utils.InvalidField(typeErr.Field, "has the wrong type")
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return utils.InvalidField(strings.Trim(field, `"`), "cannot be changed")
+	}
+
+	return utils.BadRequest("invalid patch: " + err.Error())
+}
@@ -0,0 +1,146 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/jsonpatch"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Sends a PATCH with the given content type and decodes the response into out
+func patch(t *testing.T, h *apitest.Harness, path, token, contentType, body string, out any) int {
+	t.Helper()
+
+	res := requestWithHeaders(t, h, "PATCH", path, token, body, http.Header{"Content-Type": {contentType}})
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("PATCH %s: decoding response: %v", path, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+func TestPatchTask(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Math homework", "description": "Pages 4-5", "deadline": "2030-12-01", "tags": []string{"school"}})
+		path := "/me/tasks/" + task.ID.String()
+
+		var merged utils.Task
+		if status := patch(t, h, path, token, jsonpatch.MergePatchType, `{"title":"Physics homework","description":null}`, &merged); status != http.StatusOK {
+			t.Fatalf("merge patch: status %d", status)
+		}
+		if merged.Title != "Physics homework" || merged.Description != "" || merged.Version != task.Version+1 {
+			t.Errorf("merge patched task %+v", merged)
+		}
+
+		var patched utils.Task
+		ops := `[
+			{"op":"test","path":"/title","value":"Physics homework"},
+			{"op":"replace","path":"/status","value":"in_progress"},
+			{"op":"add","path":"/tags/-","value":"physics"},
+			{"op":"copy","from":"/title","path":"/description"}
+		]`
+		if status := patch(t, h, path, token, jsonpatch.JSONPatchType+"; charset=utf-8", ops, &patched); status != http.StatusOK {
+			t.Fatalf("JSON patch: status %d", status)
+		}
+		if patched.Status != utils.StatusInProgress || patched.Description != "Physics homework" ||
+			len(patched.Tags) != 2 || patched.Version != merged.Version+1 {
+			t.Errorf("JSON patched task %+v", patched)
+		}
+	})
+}
+
+func TestPatchTaskErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Math homework", "deadline": "2030-12-01"})
+		path := "/me/tasks/" + task.ID.String()
+
+		tests := []struct {
+			name        string
+			contentType string
+			body        string
+			status      int
+			code        utils.ErrorCode
+		}{
+			{"plain JSON", "application/json", `{"title":"Physics homework"}`, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMedia},
+			{"malformed merge patch", jsonpatch.MergePatchType, `{"title":`, http.StatusBadRequest, utils.CodeBadRequest},
+			{"malformed JSON patch", jsonpatch.JSONPatchType, `{"op":"remove","path":"/title"}`, http.StatusBadRequest, utils.CodeBadRequest},
+			{"unknown op", jsonpatch.JSONPatchType, `[{"op":"rename","path":"/title"}]`, http.StatusBadRequest, utils.CodeBadRequest},
+			{"failed test", jsonpatch.JSONPatchType, `[{"op":"test","path":"/title","value":"Other"},{"op":"replace","path":"/title","value":"Physics homework"}]`, http.StatusConflict, utils.CodeConflict},
+			{"missing member", jsonpatch.JSONPatchType, `[{"op":"remove","path":"/notes"}]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"index out of range", jsonpatch.JSONPatchType, `[{"op":"add","path":"/tags/1","value":"school"}]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"invalid result", jsonpatch.MergePatchType, `{"title":"abc"}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"wrong type", jsonpatch.MergePatchType, `{"title":5}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"required field removed", jsonpatch.JSONPatchType, `[{"op":"remove","path":"/title"}]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"document replaced", jsonpatch.MergePatchType, `["Physics homework"]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+
+			// Fields outside the patch document cannot be changed
+			{"merge task_id", jsonpatch.MergePatchType, `{"task_id":"00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"merge id", jsonpatch.MergePatchType, `{"id":"00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"merge user_id", jsonpatch.MergePatchType, `{"user_id":"00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"merge version", jsonpatch.MergePatchType, `{"version":100}`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"add user_id", jsonpatch.JSONPatchType, `[{"op":"add","path":"/user_id","value":"00000000-0000-0000-0000-000000000001"}]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"replace version", jsonpatch.JSONPatchType, `[{"op":"replace","path":"/version","value":100}]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+			{"replace task_id", jsonpatch.JSONPatchType, `[{"op":"replace","path":"/task_id","value":"00000000-0000-0000-0000-000000000001"}]`, http.StatusUnprocessableEntity, utils.CodeValidationFailed},
+		}
+
+		for _, tt := range tests {
+			var apiErr utils.APIError
+			if status := patch(t, h, path, token, tt.contentType, tt.body, &apiErr); status != tt.status || apiErr.Code != tt.code {
+				t.Errorf("%s: status %d, code %s, want %d %s", tt.name, status, apiErr.Code, tt.status, tt.code)
+			}
+		}
+
+		var got utils.Task
+		if status := h.Do("GET", path, token, nil, &got); status != http.StatusOK {
+			t.Fatalf("GET: status %d", status)
+		}
+		if got.ID != task.ID || got.UserID != task.UserID || got.Title != task.Title || got.Version != task.Version {
+			t.Errorf("task after the rejected patches %+v, want it unchanged", got)
+		}
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		user, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		var patched utils.User
+		if status := patch(t, h, "/me", token, jsonpatch.MergePatchType, `{"username":"renamed","timezone":"Europe/Helsinki"}`, &patched); status != http.StatusOK {
+			t.Fatalf("merge patch: status %d", status)
+		}
+		if patched.Name != "renamed" || patched.TimeZone != "Europe/Helsinki" || patched.Email != user.Email {
+			t.Errorf("merge patched user %+v", patched)
+		}
+
+		if status := patch(t, h, "/me", token, jsonpatch.JSONPatchType, `[{"op":"replace","path":"/email","value":"renamed@tasklist.com"}]`, &patched); status != http.StatusOK {
+			t.Fatalf("JSON patch: status %d", status)
+		}
+		if patched.Email != "renamed@tasklist.com" {
+			t.Errorf("JSON patched user %+v", patched)
+		}
+
+		for _, body := range []string{`{"id":"00000000-0000-0000-0000-000000000001"}`, `{"role":"admin"}`, `{"version":100}`} {
+			var apiErr utils.APIError
+			if status := patch(t, h, "/me", token, jsonpatch.MergePatchType, body, &apiErr); status != http.StatusUnprocessableEntity {
+				t.Errorf("merge patch %s: status %d, want 422", body, status)
+			}
+		}
+
+		got, err := h.Store.GetUserById(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != user.Role || got.Version != patched.Version {
+			t.Errorf("user after the rejected patches %+v", got)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// handler for /me/projects && /me/projects/{project_id} endpoints
+func (s *APIServer) handleProjects(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["project_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetProjects(w, r)
+		case "POST":
+			return s.handleCreateProject(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "GET":
+			return s.handleGetProjectByID(w, r)
+		case "PUT":
+			return s.handleUpdateProject(w, r)
+		case "DELETE":
+			return s.handleDeleteProject(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+// Checks that the project exists before a task is put in it. The project of
+// a workspace task has to be in the same workspace, the project of a
+// personal task has to belong to the user.
+func (s *APIServer) checkProject(userID uuid.UUID, workspaceID *uuid.UUID, projectID uuid.UUID) error {
+	var err error
+	if workspaceID != nil {
+		_, err = s.store.GetWorkspaceProject(*workspaceID, projectID)
+	} else {
+		_, err = s.store.GetProject(userID, projectID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.InvalidField("project_id", "unknown project")
+	}
+
+	return err
+}
+
+// Checks that the user can move the task to the project, nil removes it from
+// its project. Any member can move the tasks of a workspace, personal tasks
+// are moved by their owner.
+func (s *APIServer) checkMove(user utils.User, task utils.Task, projectID *uuid.UUID) error {
+	if task.WorkspaceID == nil && task.UserID != user.ID {
+		return utils.Forbidden("owner access to the task required")
+	}
+
+	if projectID == nil {
+		return nil
+	}
+
+	return s.checkProject(user.ID, task.WorkspaceID, *projectID)
+}
+
+func (s *APIServer) handleGetProjects(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	projects, err := s.store.GetProjects(userID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, projects)
+}
+
+func (s *APIServer) handleGetProjectByID(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetProjectID(r)
+	if err != nil {
+		return err
+	}
+
+	project, err := s.store.GetProject(userID, id)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, project)
+}
+
+func (s *APIServer) handleCreateProject(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ProjectBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	project := utils.NewProject(req.Name, req.Description, userID)
+	if err := utils.Validate(project); err != nil {
+		return err
+	}
+
+	if err := s.store.CreateProject(project); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, project)
+}
+
+func (s *APIServer) handleUpdateProject(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetProjectID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ProjectBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	project, err := s.store.GetProject(userID, id)
+	if err != nil {
+		return err
+	}
+
+	project.ModifyProject(req)
+	if err := utils.Validate(project); err != nil {
+		return err
+	}
+
+	if err := s.store.UpdateProject(userID, id, project); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"updated": id})
+}
+
+// Deletes the project, ?tasks=delete deletes its tasks too. By default
+// (?tasks=orphan) the tasks are kept without a project.
+func (s *APIServer) handleDeleteProject(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetProjectID(r)
+	if err != nil {
+		return err
+	}
+
+	deleteTasks, err := parseTasksMode(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteProject(userID, id, deleteTasks); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
+}
+
+// Reads the ?tasks= mode of a project deletion, whether its tasks are deleted too
+func parseTasksMode(r *http.Request) (deleteTasks bool, err error) {
+	switch mode := r.URL.Query().Get("tasks"); mode {
+	case "", "orphan":
+		return false, nil
+	case "delete":
+		return true, nil
+	default:
+		return false, utils.BadRequest("invalid tasks mode: " + mode)
+	}
+}
+
+// handler for /me/projects/{project_id}/tasks, lists the tasks of the project
+// with the same query parameters as the users task listing
+func (s *APIServer) handleProjectTasks(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetProjectID(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.store.GetProject(user.ID, id); err != nil {
+		return err
+	}
+
+	filter, err := parseTaskFilter(r, user.Location())
+	if err != nil {
+		return err
+	}
+	filter.ProjectID = &id
+
+	page, err := s.store.GetTasksByUserID(user.ID, filter)
+	if err != nil {
+		return err
+	}
+
+	return writeTaskPage(w, r, user, page)
+}
+
+// handler for /me/tasks/{task_id}/project, moves the task to another project
+// and responds with the moved task
+func (s *APIServer) handleMoveTask(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "PUT" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetTaskID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.MoveTaskRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	task, err := s.accessTask(user, id, utils.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkMove(user, task, req.ProjectID); err != nil {
+		return err
+	}
+
+	task.ProjectID = req.ProjectID
+	if err := s.store.UpdateTask(task.UserID, id, task); err != nil {
+		return err
+	}
+
+	moved, err := s.store.GetTaskById(task.UserID, id)
+	if err != nil {
+		return err
+	}
+
+	s.emitTask(moved, utils.EventTaskUpdated, moved)
+
+	moved.InLocation(user.Location())
+	return utils.WriteJSON(w, http.StatusOK, moved)
+}
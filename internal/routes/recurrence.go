@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+const (
+	defaultOccurrenceLimit = 5
+	maxOccurrenceLimit     = 50
+)
+
+// Creates the occurrence that follows a completed recurring task, with its
+// checklist unticked. Returns nil when the recurrence has ended or the next
+// occurrence already exists, e.g. when a task is reopened and completed again.
+func (s *APIServer) createNextOccurrence(task utils.Task) (*utils.Task, error) {
+	next, ok := task.NextOccurrence()
+	if !ok {
+		return nil, nil
+	}
+
+	created, err := s.store.CreateTask(next)
+	if errors.Is(err, db.ErrDuplicateOccurrence) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, item := range task.Checklist {
+		copied := &utils.ChecklistItem{TaskID: created.ID, Title: item.Title}
+		if err := s.store.AddChecklistItem(task.UserID, copied); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(task.Checklist) > 0 {
+		if *created, err = s.store.GetTaskById(task.UserID, created.ID); err != nil {
+			return nil, err
+		}
+	}
+	s.emitTask(*created, utils.EventTaskCreated, created)
+
+	return created, nil
+}
+
+// handler for /me/tasks/{task_id}/occurrences, previews the deadlines of the
+// upcoming occurrences of a recurring task, ?limit= sets how many (default 5)
+func (s *APIServer) handleOccurrences(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetTaskID(r)
+	if err != nil {
+		return err
+	}
+
+	limit := defaultOccurrenceLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxOccurrenceLimit {
+			return utils.BadRequest("limit must be between 1 and " + strconv.Itoa(maxOccurrenceLimit))
+		}
+	}
+
+	task, err := s.accessTask(user, id, utils.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	// Occurrences keep the local time of day in the users time zone, also across DST changes
+	task.InLocation(user.Location())
+	return utils.WriteJSON(w, http.StatusOK, task.UpcomingOccurrences(limit))
+}
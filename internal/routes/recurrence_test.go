@@ -0,0 +1,75 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Counts the tasks of the series by their occurrence number
+func seriesOccurrences(t *testing.T, h *apitest.Harness, token string, task utils.Task) map[int]int {
+	t.Helper()
+
+	var tasks []utils.Task
+	if status := h.Do("GET", "/me/tasks", token, nil, &tasks); status != http.StatusOK {
+		t.Fatalf("list: status %d", status)
+	}
+
+	occurrences := map[int]int{}
+	for _, other := range tasks {
+		if other.SeriesID != nil && *other.SeriesID == *task.SeriesID {
+			occurrences[other.Occurrence]++
+		}
+	}
+
+	return occurrences
+}
+
+func TestCompletingCreatesNextOccurrence(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Water the plants", "deadline": "2030-12-01", "recurrence": "FREQ=DAILY;COUNT=2"})
+		if task.SeriesID == nil || task.Occurrence != 1 {
+			t.Fatalf("created task %+v", task)
+		}
+
+		path := "/me/tasks/" + task.ID.String()
+		if status := h.Do("PUT", path, token, map[string]any{"status": "done"}, nil); status != http.StatusOK {
+			t.Fatalf("complete: status %d", status)
+		}
+
+		if got := seriesOccurrences(t, h, token, task); got[2] != 1 || len(got) != 2 {
+			t.Fatalf("occurrences after completing %v", got)
+		}
+	})
+}
+
+// Reopening a completed task and completing it again must not create the next occurrence twice
+func TestReopeningDoesNotDuplicateOccurrence(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Water the plants", "deadline": "2030-12-01", "recurrence": "FREQ=DAILY"})
+		path := "/me/tasks/" + task.ID.String()
+
+		for _, status := range []string{"done", "todo", "done"} {
+			if code := h.Do("PUT", path, token, map[string]any{"status": status}, nil); code != http.StatusOK {
+				t.Fatalf("set status %s: status %d", status, code)
+			}
+
+			var updated utils.Task
+			h.Do("GET", path, token, nil, &updated)
+			if updated.Recurrence != task.Recurrence {
+				t.Fatalf("set status %s: recurrence %q, want %q", status, updated.Recurrence, task.Recurrence)
+			}
+		}
+
+		got := seriesOccurrences(t, h, token, task)
+		if len(got) != 2 || got[1] != 1 || got[2] != 1 {
+			t.Errorf("occurrences of the series %v, want one each of 1 and 2", got)
+		}
+	})
+}
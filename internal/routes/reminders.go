@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// handler for /me/tasks/{task_id}/reminders && /me/tasks/{task_id}/reminders/{reminder_id} endpoints
+func (s *APIServer) handleReminders(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["reminder_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetReminders(w, r)
+		case "POST":
+			return s.handleCreateReminder(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "GET":
+			return s.handleGetReminder(w, r)
+		case "DELETE":
+			return s.handleDeleteReminder(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetReminders(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	taskID, err := utils.GetTaskID(r)
+	if err != nil {
+		return err
+	}
+
+	reminders, err := s.store.GetReminders(user.ID, taskID)
+	if err != nil {
+		return err
+	}
+
+	loc := user.Location()
+	for i := range reminders {
+		reminders[i].InLocation(loc)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, reminders)
+}
+
+func (s *APIServer) handleGetReminder(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	taskID, err := utils.GetTaskID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetReminderID(r)
+	if err != nil {
+		return err
+	}
+
+	reminder, err := s.store.GetReminder(user.ID, taskID, id)
+	if err != nil {
+		return err
+	}
+
+	reminder.InLocation(user.Location())
+	return utils.WriteJSON(w, http.StatusOK, reminder)
+}
+
+// Adds a reminder at a fixed time or a number of minutes before the deadline
+func (s *APIServer) handleCreateReminder(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	taskID, err := utils.GetTaskID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ReminderBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	task, err := s.accessTask(user, taskID, utils.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	reminder, err := utils.NewReminder(req, task, user.Location())
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateReminder(reminder); err != nil {
+		return err
+	}
+
+	reminder.InLocation(user.Location())
+	return utils.WriteJSON(w, http.StatusOK, reminder)
+}
+
+func (s *APIServer) handleDeleteReminder(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	taskID, err := utils.GetTaskID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetReminderID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteReminder(userID, taskID, id); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
+}
@@ -1,86 +1,189 @@
 package routes
 
 import (
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"github.com/sunikka/tasklist-backendGo/internal/auth"
 	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/events"
+	"github.com/sunikka/tasklist-backendGo/internal/policy"
 	"github.com/sunikka/tasklist-backendGo/internal/utils"
 )
 
 type APIServer struct {
 	listenAddr string
 	store      db.Storage
+	events     *events.Bus
+
+	// Rejects updates and deletes of tasks and users without an If-Match header
+	RequireIfMatch bool
+	// Decides the permissions of the users, every authenticated route requires one
+	Policy *policy.Policy
 }
 
 func NewAPIServer(listenAddr string, store db.Storage) *APIServer {
 	return &APIServer{
 		listenAddr: listenAddr,
 		store:      store,
+		events:     events.NewBus(),
+		Policy:     policy.Default(),
 	}
 }
 
 func (s APIServer) Run() {
+	log.Println("Tasklist-API listening on port", s.listenAddr)
+	if err := http.ListenAndServe(s.listenAddr, s.Handler()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Builds the router with every API endpoint registered,
+// also used for serving the API from tests with httptest
+func (s APIServer) Handler() http.Handler {
 	mux := mux.NewRouter()
 
 	// Request handlers
-	mux.HandleFunc("/tasks/{user_id}", auth.MiddlewareJWT(createHandler(s.handleTasks), s.store))
-	mux.HandleFunc("/tasks/{user_id}/{task_id}", auth.MiddlewareJWT(createHandler(s.handleTasks), s.store))
-
-	mux.HandleFunc("/users", createHandler(s.handleUsers))
-	mux.HandleFunc("/users/{user_id}", auth.MiddlewareJWT(createHandler(s.handleUsers), s.store))
+	mux.HandleFunc("/tasks/{user_id}", s.protect(policy.ReadWrite(policy.Tasks), s.handleTasks))
+	mux.HandleFunc("/tasks/{user_id}/{task_id}", s.protect(policy.ReadWrite(policy.Tasks), s.handleTasks))
+
+	mux.HandleFunc("/users", s.protect(policy.Require(policy.UserAdmin), s.handleUsers))
+	mux.HandleFunc("/users/{user_id}", s.protect(policy.ReadWrite(policy.Users), s.handleUsers))
+
+	mux.HandleFunc("/users/{user_id}/sessions", s.protect(policy.ReadWrite(policy.Users), s.handleSessions))
+	mux.HandleFunc("/users/{user_id}/sessions/{session_id}", s.protect(policy.ReadWrite(policy.Users), s.handleSessions))
+
+	// Same endpoints for the authenticated user without the user ID in the path
+	mux.HandleFunc("/me", s.protect(policy.ReadWrite(policy.Users), s.handleMe))
+	mux.HandleFunc("/me/tasks", s.protect(policy.ReadWrite(policy.Tasks), s.handleTasks))
+	mux.HandleFunc("/me/tasks/{task_id}", s.protect(policy.ReadWrite(policy.Tasks), s.handleTasks))
+	mux.HandleFunc("/me/tasks/{task_id}/project", s.protect(policy.ReadWrite(policy.Tasks), s.handleMoveTask))
+	// The order route is registered first so that "order" is not taken for an item ID
+	mux.HandleFunc("/me/tasks/{task_id}/checklist", s.protect(policy.ReadWrite(policy.Tasks), s.handleChecklist))
+	mux.HandleFunc("/me/tasks/{task_id}/checklist/order", s.protect(policy.ReadWrite(policy.Tasks), s.handleReorderChecklist))
+	mux.HandleFunc("/me/tasks/{task_id}/checklist/{item_id}", s.protect(policy.ReadWrite(policy.Tasks), s.handleChecklist))
+	mux.HandleFunc("/me/tasks/{task_id}/reminders", s.protect(policy.ReadWrite(policy.Tasks), s.handleReminders))
+	mux.HandleFunc("/me/tasks/{task_id}/reminders/{reminder_id}", s.protect(policy.ReadWrite(policy.Tasks), s.handleReminders))
+	mux.HandleFunc("/me/tasks/{task_id}/occurrences", s.protect(policy.ReadWrite(policy.Tasks), s.handleOccurrences))
+	mux.HandleFunc("/me/tasks/{task_id}/shares", s.protect(policy.ReadWrite(policy.Shares), s.handleShares))
+	mux.HandleFunc("/me/tasks/{task_id}/shares/{share_id}", s.protect(policy.ReadWrite(policy.Shares), s.handleShares))
+	mux.HandleFunc("/me/projects", s.protect(policy.ReadWrite(policy.Projects), s.handleProjects))
+	mux.HandleFunc("/me/projects/{project_id}", s.protect(policy.ReadWrite(policy.Projects), s.handleProjects))
+	mux.HandleFunc("/me/projects/{project_id}/tasks", s.protect(policy.ReadWrite(policy.Tasks), s.handleProjectTasks))
+	mux.HandleFunc("/me/projects/{project_id}/shares", s.protect(policy.ReadWrite(policy.Shares), s.handleShares))
+	mux.HandleFunc("/me/projects/{project_id}/shares/{share_id}", s.protect(policy.ReadWrite(policy.Shares), s.handleShares))
+	mux.HandleFunc("/me/invitations", s.protect(policy.ReadWrite(policy.Shares), s.handleInvitations))
+	mux.HandleFunc("/me/invitations/{share_id}/accept", s.protect(policy.ReadWrite(policy.Shares), s.handleAcceptInvitation))
+	mux.HandleFunc("/me/invitations/{share_id}/decline", s.protect(policy.ReadWrite(policy.Shares), s.handleDeclineInvitation))
+	mux.HandleFunc("/me/shared", s.protect(policy.ReadWrite(policy.Shares), s.handleSharedWithMe))
+	mux.HandleFunc("/me/shared/tasks", s.protect(policy.ReadWrite(policy.Shares), s.handleSharedTasks))
+	mux.HandleFunc("/me/workspaces", s.protect(policy.ReadWrite(policy.Workspaces), s.handleWorkspaces))
+	mux.HandleFunc("/me/workspaces/{workspace_id}", s.protect(policy.ReadWrite(policy.Workspaces), s.handleWorkspaces))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/members", s.protect(policy.ReadWrite(policy.Workspaces), s.handleWorkspaceMembers))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/members/{member_id}", s.protect(policy.ReadWrite(policy.Workspaces), s.handleWorkspaceMembers))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/invitations", s.protect(policy.ReadWrite(policy.Workspaces), s.handleWorkspaceInvitations))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/invitations/{invitation_id}", s.protect(policy.ReadWrite(policy.Workspaces), s.handleWorkspaceInvitations))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/tasks", s.protect(policy.ReadWrite(policy.Tasks), s.handleWorkspaceTasks))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/projects", s.protect(policy.ReadWrite(policy.Projects), s.handleWorkspaceProjects))
+	mux.HandleFunc("/me/workspaces/{workspace_id}/projects/{project_id}", s.protect(policy.ReadWrite(policy.Projects), s.handleWorkspaceProjects))
+	mux.HandleFunc("/me/workspace-invitations", s.protect(policy.ReadWrite(policy.Workspaces), s.handleMyWorkspaceInvitations))
+	mux.HandleFunc("/me/workspace-invitations/{invitation_id}/accept", s.protect(policy.ReadWrite(policy.Workspaces), s.handleAcceptWorkspaceInvitation))
+	mux.HandleFunc("/me/workspace-invitations/{invitation_id}/decline", s.protect(policy.ReadWrite(policy.Workspaces), s.handleDeclineWorkspaceInvitation))
+	mux.HandleFunc("/me/tags", s.protect(policy.ReadWrite(policy.Tags), s.handleTags))
+	mux.HandleFunc("/me/tags/{tag_id}", s.protect(policy.ReadWrite(policy.Tags), s.handleTags))
+	mux.HandleFunc("/me/webhooks", s.protect(policy.ReadWrite(policy.Webhooks), s.handleWebhooks))
+	mux.HandleFunc("/me/webhooks/{webhook_id}", s.protect(policy.ReadWrite(policy.Webhooks), s.handleWebhooks))
+	mux.HandleFunc("/me/webhooks/{webhook_id}/deliveries", s.protect(policy.ReadWrite(policy.Webhooks), s.handleWebhookDeliveries))
+	mux.HandleFunc("/me/webhooks/{webhook_id}/deliveries/{delivery_id}", s.protect(policy.ReadWrite(policy.Webhooks), s.handleWebhookDeliveries))
+	mux.HandleFunc("/me/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver", s.protect(policy.ReadWrite(policy.Webhooks), s.handleRedeliverWebhook))
+	mux.HandleFunc("/me/events", auth.QueryToken(s.protect(policy.Require(policy.Tasks.Read()), s.handleEvents)))
+	mux.HandleFunc("/me/events/ws", auth.QueryToken(s.protect(policy.Require(policy.Tasks.Read()), s.handleEventsWebSocket)))
+	mux.HandleFunc("/me/sessions", s.protect(policy.ReadWrite(policy.Users), s.handleSessions))
+	mux.HandleFunc("/me/sessions/{session_id}", s.protect(policy.ReadWrite(policy.Users), s.handleSessions))
+
+	mux.HandleFunc("/sync", s.protect(policy.ReadWrite(policy.Tasks), s.handleSync))
+
+	// Only for users with the user:admin permission
+	mux.HandleFunc("/admin/users", s.protect(policy.Require(policy.UserAdmin), s.handleAdminUsers))
+	mux.HandleFunc("/admin/users/{account_id}", s.protect(policy.Require(policy.UserAdmin), s.handleAdminUsers))
+	mux.HandleFunc("/admin/users/{account_id}/role", s.protect(policy.Require(policy.UserAdmin), s.handleAdminUserRole))
+	mux.HandleFunc("/admin/tasks", s.protect(policy.Require(policy.UserAdmin), s.handleAdminTasks))
 
 	mux.HandleFunc("/login", createHandler(s.handleLogin))
+	mux.HandleFunc("/logout", s.protect(policy.ReadWrite(policy.Users), s.handleLogout))
+	mux.HandleFunc("/token/refresh", createHandler(s.handleRefreshToken))
 	mux.HandleFunc("/register", createHandler(s.handleCreateUser))
 
 	// TODO: CORS config
-	handler := cors.Default().Handler(mux)
-
-	log.Println("Tasklist-API listening on port", s.listenAddr)
-	if err := http.ListenAndServe(s.listenAddr, handler); err != nil {
-		fmt.Println(err.Error())
-	}
+	return cors.Default().Handler(mux)
 }
 
 func (s *APIServer) handleLogin(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "POST" {
-		return fmt.Errorf("method not allowed %s", r.Method)
+		return utils.MethodNotAllowed(r.Method)
 	}
 
 	var req utils.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := utils.DecodeJSON(r, &req); err != nil {
 		return err
 	}
 
 	user, err := s.store.GetUserByEmail(req.Email)
 	if err != nil {
-		return err
+		// Unknown emails fail the same way as wrong passwords
+		return utils.Unauthorized("authentication failed")
 	}
 
 	if !user.ValidPassword(req.Password) {
-		return fmt.Errorf("authentication failed")
+		return utils.Unauthorized("authentication failed")
 	}
 
-	token, err := auth.GenerateToken(user.ID)
+	tokens, err := auth.StartSession(s.store, user, r.UserAgent())
 	if err != nil {
-		fmt.Println("Error generating token:", err)
 		return err
 	}
 
 	response := utils.LoginResponse{
-		Username: user.Name,
-		Token:    token,
+		Username:     user.Name,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 
 	return utils.WriteJSON(w, 200, response)
 }
 
-// handler for  /tasks/{userID} && /tasks/{userID}/{taskID} endpoints
+// Exchanges a refresh token for a new access token and refresh token
+func (s *APIServer) handleRefreshToken(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	var req utils.RefreshRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	tokens, err := auth.RefreshSession(s.store, req.RefreshToken)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, db.ErrRefreshTokenReused) || errors.Is(err, db.ErrSessionExpired) {
+		return utils.Unauthorized("invalid refresh token")
+	} else if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+// handler for  /tasks/{userID} && /tasks/{userID}/{taskID} endpoints (and /me/tasks)
 func (s *APIServer) handleTasks(w http.ResponseWriter, r *http.Request) error {
 
 	// For checking if the request has :id attached to it
@@ -93,8 +196,7 @@ func (s *APIServer) handleTasks(w http.ResponseWriter, r *http.Request) error {
 		case "POST":
 			return s.handleCreateTask(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return fmt.Errorf("method not allowed")
+			return utils.MethodNotAllowed(r.Method)
 		}
 
 	} else {
@@ -105,14 +207,15 @@ func (s *APIServer) handleTasks(w http.ResponseWriter, r *http.Request) error {
 			return s.handleDeleteTask(w, r)
 		case "PUT":
 			return s.handleUpdateTask(w, r)
+		case "PATCH":
+			return s.handlePatchTask(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return fmt.Errorf("method not allowed")
+			return utils.MethodNotAllowed(r.Method)
 		}
 	}
 }
 
-// handler for  /users && /users/:id endpoints
+// handler for  /users && /users/:id endpoints, listing the users is only for admins
 func (s *APIServer) handleUsers(w http.ResponseWriter, r *http.Request) error {
 
 	// For checking if the request has :id attached to it
@@ -123,8 +226,7 @@ func (s *APIServer) handleUsers(w http.ResponseWriter, r *http.Request) error {
 		case "GET":
 			return s.handleGetUsers(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return fmt.Errorf("method not allowed")
+			return utils.MethodNotAllowed(r.Method)
 		}
 
 	} else {
@@ -135,13 +237,30 @@ func (s *APIServer) handleUsers(w http.ResponseWriter, r *http.Request) error {
 			return s.handleDeleteUser(w, r)
 		case "PUT":
 			return s.handleUpdateUser(w, r)
+		case "PATCH":
+			return s.handlePatchUser(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return fmt.Errorf("method not allowed")
+			return utils.MethodNotAllowed(r.Method)
 		}
 	}
 }
 
+// handler for the /me endpoint
+func (s *APIServer) handleMe(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetUserByID(w, r)
+	case "DELETE":
+		return s.handleDeleteUser(w, r)
+	case "PUT":
+		return s.handleUpdateUser(w, r)
+	case "PATCH":
+		return s.handlePatchUser(w, r)
+	default:
+		return utils.MethodNotAllowed(r.Method)
+	}
+}
+
 func (s *APIServer) handleGetTasks(w http.ResponseWriter, r *http.Request) error {
 	tasks, err := s.store.GetTasks()
 	if err != nil {
@@ -152,76 +271,274 @@ func (s *APIServer) handleGetTasks(w http.ResponseWriter, r *http.Request) error
 }
 
 func (s *APIServer) handleGetTasksForUser(w http.ResponseWriter, r *http.Request) error {
-	id, err := utils.GetUserID(r)
+	user, err := currentUser(r)
 	if err != nil {
 		return err
 	}
 
-	tasks, err := s.store.GetTasksByUserID(id)
+	filter, err := parseTaskFilter(r, user.Location())
 	if err != nil {
 		return err
 	}
 
-	return utils.WriteJSON(w, http.StatusOK, tasks)
+	page, err := s.store.GetTasksByUserID(user.ID, filter)
+	if err != nil {
+		return err
+	}
+
+	return writeTaskPage(w, r, user, page)
+}
+
+// Writes a page of a task listing in the users time zone, the URL of the next page is sent in the Link header
+func writeTaskPage(w http.ResponseWriter, r *http.Request, user utils.User, page utils.TaskPage) error {
+	loc := user.Location()
+	for i := range page.Tasks {
+		page.Tasks[i].InLocation(loc)
+	}
+
+	if page.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, page.Tasks)
+}
+
+// Reads the task listing filters, ordering and page from the query string,
+// e.g. /tasks/{user_id}?status=todo,in_progress&sort=deadline&order=desc&limit=20.
+// Deadline bounds without an offset are read in loc.
+func parseTaskFilter(r *http.Request, loc *time.Location) (utils.TaskFilter, error) {
+	var filter utils.TaskFilter
+	query := r.URL.Query()
+
+	if statusStr := query.Get("status"); statusStr != "" {
+		for _, value := range strings.Split(statusStr, ",") {
+			status, err := utils.ParseTaskStatus(value)
+			if err != nil {
+				return filter, utils.BadRequest(err.Error())
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if projectStr := query.Get("project_id"); projectStr != "" {
+		projectID, err := uuid.Parse(projectStr)
+		if err != nil {
+			return filter, utils.BadRequest("invalid project ID: " + projectStr)
+		}
+		filter.ProjectID = &projectID
+	}
+
+	if tagStr := query.Get("tags"); tagStr != "" {
+		filter.Tags = utils.NormalizeTags(strings.Split(tagStr, ","))
+	}
+
+	switch mode := query.Get("tag_mode"); mode {
+	case "", "any":
+	case "all":
+		filter.TagMatchAll = true
+	default:
+		return filter, utils.BadRequest("invalid tag_mode: " + mode)
+	}
+
+	var err error
+	if filter.DeadlineBefore, err = parseDeadlineParam(query.Get("deadline_before"), loc); err != nil {
+		return filter, utils.BadRequest("invalid deadline_before: " + err.Error())
+	}
+	if filter.DeadlineAfter, err = parseDeadlineParam(query.Get("deadline_after"), loc); err != nil {
+		return filter, utils.BadRequest("invalid deadline_after: " + err.Error())
+	}
+
+	filter.Search = query.Get("q")
+
+	if sortStr := query.Get("sort"); sortStr != "" {
+		if filter.Sort, err = utils.ParseTaskSort(sortStr); err != nil {
+			return filter, utils.BadRequest(err.Error())
+		}
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return filter, utils.BadRequest("invalid order: " + order)
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > utils.MaxTaskLimit {
+			return filter, utils.BadRequest(fmt.Sprintf("limit must be between 1 and %d", utils.MaxTaskLimit))
+		}
+		filter.Limit = limit
+	}
+
+	filter.Cursor = query.Get("cursor")
+
+	return filter, nil
+}
+
+// Parses an RFC3339 timestamp or a YYYY-MM-DD date (midnight in loc), nil if s is empty
+func parseDeadlineParam(s string, loc *time.Location) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.ParseInLocation(time.DateOnly, s, loc); err != nil {
+			return nil, errors.New("expected RFC3339 or YYYY-MM-DD")
+		}
+	}
+
+	return &t, nil
 }
 
 func (s *APIServer) handleGetTaskByID(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
 	id, err := utils.GetTaskID(r)
 	if err != nil {
 		return err
 	}
 
-	task, err := s.store.GetTaskById(id)
+	task, err := s.accessTask(user, id, utils.RoleViewer)
 	if err != nil {
 		return err
 	}
 
+	if notModified(w, r, task.Version) {
+		return nil
+	}
+
+	task.InLocation(user.Location())
+
 	return utils.WriteJSON(w, http.StatusOK, task)
 }
 
 func (s *APIServer) handleCreateTask(w http.ResponseWriter, r *http.Request) error {
 	req := new(utils.TaskBodyRequest)
-	// user, err := auth.GetUserFromToken(r, s.store)
-	userID, err := utils.GetUserID(r)
+	user, err := currentUser(r)
 	if err != nil {
 		return err
 	}
 
-	err = json.NewDecoder(r.Body).Decode(req)
+	err = utils.DecodeJSON(r, req)
 	if err != nil {
 		return err
 	}
 
-	task, err := utils.NewTask(req.Title, req.Description, req.Deadline, userID)
+	created, err := s.createTask(user, nil, req)
 	if err != nil {
 		return err
 	}
 
+	created.InLocation(user.Location())
+	return utils.WriteJSON(w, http.StatusOK, created)
+}
+
+// Creates a task of the user from the request, in the workspace if one is
+// given. Shared with the sync and workspace endpoints.
+func (s *APIServer) createTask(user utils.User, workspaceID *uuid.UUID, req *utils.TaskBodyRequest) (*utils.Task, error) {
+	if err := utils.Validate(req); err != nil {
+		return nil, err
+	}
+
+	task, err := utils.NewTask(req.Title, req.Description, req.Deadline, user)
+	if err != nil {
+		return nil, err
+	}
+	task.WorkspaceID = workspaceID
+
+	if req.Status != "" {
+		status, err := utils.ParseTaskStatus(req.Status)
+		if err != nil {
+			return nil, utils.InvalidField("status", err.Error())
+		}
+		task.SetStatus(status)
+	}
+
+	if req.ProjectID != nil {
+		if err := s.checkProject(user.ID, workspaceID, *req.ProjectID); err != nil {
+			return nil, err
+		}
+		task.ProjectID = req.ProjectID
+	}
+
+	if req.Tags != nil {
+		task.Tags = utils.NormalizeTags(*req.Tags)
+	}
+
+	if req.Recurrence != nil {
+		if err := task.SetRecurrence(*req.Recurrence); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := utils.Validate(task); err != nil {
+		return nil, err
+	}
+
 	created, err := s.store.CreateTask(task)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return utils.WriteJSON(w, http.StatusOK, created)
+	s.emitTask(*created, utils.EventTaskCreated, created)
+
+	return created, nil
 }
 
 func (s *APIServer) handleDeleteTask(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
 
 	id, err := utils.GetTaskID(r)
 	if err != nil {
 		return err
 	}
 
-	if err := s.store.DeleteTask(id); err != nil {
+	task, err := s.accessTask(user, id, utils.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkIfMatch(r, task.Version); err != nil {
+		return err
+	}
+
+	recipients, err := s.taskRecipients(task)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteTask(task.UserID, id); err != nil {
 		return err
 	}
 
+	s.emitAll(recipients, utils.EventTaskDeleted, utils.JSONres{"task_id": id})
+
 	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
 }
 
 func (s *APIServer) handleUpdateTask(w http.ResponseWriter, r *http.Request) error {
 	req := new(utils.TaskBodyRequest)
-	err := json.NewDecoder(r.Body).Decode(req)
+	err := utils.DecodeJSON(r, req)
+	if err != nil {
+		return err
+	}
+
+	user, err := currentUser(r)
 	if err != nil {
 		return err
 	}
@@ -231,18 +548,132 @@ func (s *APIServer) handleUpdateTask(w http.ResponseWriter, r *http.Request) err
 		return err
 	}
 
-	task, err := s.store.GetTaskById(id)
+	checkIfMatch := func(task utils.Task) error {
+		return s.checkIfMatch(r, task.Version)
+	}
+
+	updated, next, err := s.updateTask(user, id, req, checkIfMatch)
+	if err != nil {
+		return versionConflict(r, err)
+	}
+
+	return writeUpdatedTask(w, user, updated, next)
+}
+
+// Applies a JSON Merge Patch or JSON Patch to the task. Unlike PUT a patch
+// can clear fields, e.g. the description or the project.
+func (s *APIServer) handlePatchTask(w http.ResponseWriter, r *http.Request) error {
+	apply, err := readPatch(w, r)
 	if err != nil {
 		return err
 	}
 
-	task.ModifyTask(req)
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
 
-	if err := s.store.UpdateTask(id, task); err != nil {
+	id, err := utils.GetTaskID(r)
+	if err != nil {
 		return err
 	}
 
-	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"updated": id})
+	checkIfMatch := func(task utils.Task) error {
+		return s.checkIfMatch(r, task.Version)
+	}
+
+	updated, next, err := s.modifyTask(user, id, checkIfMatch, func(task *utils.Task) error {
+		var patch utils.TaskPatch
+		if err := applyPatch(apply, task.PatchDocument(user.Location()), &patch); err != nil {
+			return err
+		}
+
+		moved := (patch.ProjectID == nil) != (task.ProjectID == nil) ||
+			(patch.ProjectID != nil && *patch.ProjectID != *task.ProjectID)
+		if moved {
+			if err := s.checkMove(user, *task, patch.ProjectID); err != nil {
+				return err
+			}
+		}
+
+		return task.ApplyPatch(&patch, user.Location())
+	})
+	if err != nil {
+		return versionConflict(r, err)
+	}
+
+	return writeUpdatedTask(w, user, updated, next)
+}
+
+// Responds with the saved task and its ETag. The ID of the next occurrence
+// created by completing a recurring task is sent in X-Next-Occurrence.
+func writeUpdatedTask(w http.ResponseWriter, user utils.User, updated utils.Task, next *utils.Task) error {
+	w.Header().Set("ETag", etag(updated.Version))
+	if next != nil {
+		w.Header().Set("X-Next-Occurrence", next.ID.String())
+	}
+
+	updated.InLocation(user.Location())
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// Applies the request to the task of the user, shared with the sync endpoint.
+// check can reject the change based on the stored task. Completing a
+// recurring task also returns the created next occurrence.
+func (s *APIServer) updateTask(user utils.User, id uuid.UUID, req *utils.TaskBodyRequest, check func(utils.Task) error) (updated utils.Task, next *utils.Task, err error) {
+	return s.modifyTask(user, id, check, func(task *utils.Task) error {
+		if err := utils.Validate(req); err != nil {
+			return err
+		}
+		return task.ModifyTask(req, user.Location())
+	})
+}
+
+// Loads the task, lets modify change it and saves it. Editors of a shared
+// task can change it too, it stays with its owner. The task is passed in the
+// location of the user.
+func (s *APIServer) modifyTask(user utils.User, id uuid.UUID, check func(utils.Task) error, modify func(*utils.Task) error) (updated utils.Task, next *utils.Task, err error) {
+	task, err := s.accessTask(user, id, utils.RoleEditor)
+	if err != nil {
+		return updated, nil, err
+	}
+	ownerID := task.UserID
+
+	if check != nil {
+		if err := check(task); err != nil {
+			return updated, nil, err
+		}
+	}
+
+	// The next occurrence of a recurring task keeps the local time of day of the deadline
+	task.InLocation(user.Location())
+
+	previousStatus := task.Status
+	if err := modify(&task); err != nil {
+		return updated, nil, err
+	}
+
+	if err := utils.Validate(task); err != nil {
+		return updated, nil, err
+	}
+
+	if err := s.store.UpdateTask(ownerID, id, task); err != nil {
+		return updated, nil, err
+	}
+
+	updated, err = s.store.GetTaskById(ownerID, id)
+	if err != nil {
+		return updated, nil, err
+	}
+	s.emitTask(updated, utils.EventTaskUpdated, updated)
+
+	if previousStatus != utils.StatusDone && task.Status == utils.StatusDone {
+		if next, err = s.createNextOccurrence(task); err != nil {
+			return updated, nil, err
+		}
+	}
+
+	return updated, next, nil
 }
 
 func (s *APIServer) handleGetUsers(w http.ResponseWriter, r *http.Request) error {
@@ -255,7 +686,7 @@ func (s *APIServer) handleGetUsers(w http.ResponseWriter, r *http.Request) error
 }
 
 func (s *APIServer) handleGetUserByID(w http.ResponseWriter, r *http.Request) error {
-	id, err := utils.GetUserID(r)
+	id, err := currentUserID(r)
 	if err != nil {
 		return err
 	}
@@ -265,6 +696,10 @@ func (s *APIServer) handleGetUserByID(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
+	if notModified(w, r, user.Version) {
+		return nil
+	}
+
 	return utils.WriteJSON(w, http.StatusOK, user)
 
 }
@@ -272,21 +707,29 @@ func (s *APIServer) handleGetUserByID(w http.ResponseWriter, r *http.Request) er
 func (s *APIServer) handleCreateUser(w http.ResponseWriter, r *http.Request) error {
 
 	if r.Method != "POST" {
-		return fmt.Errorf("method not allowed")
+		return utils.MethodNotAllowed(r.Method)
 	}
 
 	req := new(utils.RegisterUserRequest)
 
-	err := json.NewDecoder(r.Body).Decode(req)
+	err := utils.DecodeJSON(r, req)
 	if err != nil {
 		return err
 	}
 
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
 	user, err := utils.NewUser(req.Username, req.Email, req.Password)
 	if err != nil {
 		return err
 	}
 
+	if req.TimeZone != "" {
+		user.TimeZone = req.TimeZone
+	}
+
 	if err := s.store.CreateUser(user); err != nil {
 		return err
 	}
@@ -295,10 +738,23 @@ func (s *APIServer) handleCreateUser(w http.ResponseWriter, r *http.Request) err
 
 func (s *APIServer) handleDeleteUser(w http.ResponseWriter, r *http.Request) error {
 
-	id, err := utils.GetUserID(r)
+	user, err := currentUser(r)
 	if err != nil {
 		return err
 	}
+	id := user.ID
+
+	if err := s.checkIfMatch(r, user.Version); err != nil {
+		return err
+	}
+
+	if err := s.checkOwnedWorkspaces(id); err != nil {
+		return err
+	}
+
+	if err := s.checkLastAdmin(user); err != nil {
+		return err
+	}
 
 	if err := s.store.DeleteUser(id); err != nil {
 		return err
@@ -309,28 +765,103 @@ func (s *APIServer) handleDeleteUser(w http.ResponseWriter, r *http.Request) err
 
 func (s *APIServer) handleUpdateUser(w http.ResponseWriter, r *http.Request) error {
 	req := new(utils.UserBodyRequest)
-	err := json.NewDecoder(r.Body).Decode(req)
+	err := utils.DecodeJSON(r, req)
 	if err != nil {
 		return err
 	}
 
-	id, err := utils.GetUserID(r)
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	updated, err := s.modifyUser(r, func(user *utils.User) error {
+		return user.ModifyUser(req)
+	})
 	if err != nil {
 		return err
 	}
 
-	user, err := s.store.GetUserById(id)
+	w.Header().Set("ETag", etag(updated.Version))
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// Applies a JSON Merge Patch or JSON Patch to the user, removing the time zone resets it to UTC
+func (s *APIServer) handlePatchUser(w http.ResponseWriter, r *http.Request) error {
+	apply, err := readPatch(w, r)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.modifyUser(r, func(user *utils.User) error {
+		var patch utils.UserPatch
+		if err := applyPatch(apply, user.PatchDocument(), &patch); err != nil {
+			return err
+		}
+		return user.ApplyPatch(&patch)
+	})
 	if err != nil {
 		return err
 	}
 
-	user.ModifyUser(req)
+	w.Header().Set("ETag", etag(updated.Version))
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// Loads the authenticated user, lets modify change them and saves them
+// when the If-Match precondition holds. Returns the saved user.
+func (s *APIServer) modifyUser(r *http.Request, modify func(*utils.User) error) (utils.User, error) {
+	id, err := currentUserID(r)
+	if err != nil {
+		return utils.User{}, err
+	}
+
+	user, err := s.store.GetUserById(id)
+	if err != nil {
+		return user, err
+	}
+
+	if err := s.checkIfMatch(r, user.Version); err != nil {
+		return user, err
+	}
+
+	if err := modify(&user); err != nil {
+		return user, err
+	}
 
 	if err := s.store.UpdateUser(id, user); err != nil {
-		return err
+		return user, versionConflict(r, err)
 	}
 
-	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"updated": id})
+	updated, err := s.store.GetUserById(id)
+	if err != nil {
+		return updated, err
+	}
+	s.emit(id, utils.EventUserUpdated, updated)
+
+	return updated, nil
+}
+
+// Returns the user authenticated by auth.MiddlewareJWT. On routes with a
+// {user_id} path variable the middleware has already checked that they match.
+func currentUser(r *http.Request) (utils.User, error) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		return user, errors.New("authentication required")
+	}
+
+	return user, nil
+}
+
+func currentUserID(r *http.Request) (uuid.UUID, error) {
+	user, err := currentUser(r)
+	return user.ID, err
+}
+
+// Authenticates the request and checks that the policy grants the user the permission the route needs
+func (s APIServer) protect(need policy.Requirement, fc APIFunc) http.HandlerFunc {
+	return auth.MiddlewarePolicy(createHandler(fc), s.store, s.Policy, need)
 }
 
 type APIFunc func(w http.ResponseWriter, r *http.Request) error
@@ -339,7 +870,48 @@ func createHandler(fc APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := fc(w, r)
 		if err != nil {
-			utils.WriteJSON(w, http.StatusBadRequest, utils.APIError{Error: err.Error()})
+			httpErr := toHTTPError(err)
+			if httpErr.Status >= http.StatusInternalServerError {
+				log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+			}
+			utils.WriteError(w, httpErr)
 		}
 	}
 }
+
+// Maps errors returned by handlers to the response sent to the client.
+// Storage errors get their matching status, anything unexpected is a 500
+// whose details only end up in the log.
+func toHTTPError(err error) *utils.HTTPError {
+	var httpErr *utils.HTTPError
+
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr
+	case errors.Is(err, sql.ErrNoRows):
+		// Also covers resources that exist but are not visible to the caller
+		return utils.NotFound("not found")
+	case errors.Is(err, db.ErrDuplicateEmail):
+		return utils.Conflict("email already in use")
+	case errors.Is(err, db.ErrDuplicateProject):
+		return utils.Conflict("project name already in use")
+	case errors.Is(err, db.ErrDuplicateTag):
+		return utils.Conflict("tag name already in use")
+	case errors.Is(err, db.ErrDuplicateShare):
+		return utils.Conflict("already shared with this email")
+	case errors.Is(err, db.ErrAlreadyMember):
+		return utils.Conflict("already a member of the workspace")
+	case errors.Is(err, db.ErrDuplicateInvitation):
+		return utils.Conflict("already invited to the workspace")
+	case errors.Is(err, db.ErrChecklistMismatch):
+		return utils.InvalidField("item_ids", "must list every checklist item of the task once")
+	case errors.Is(err, db.ErrInvalidCursor):
+		return utils.BadRequest("invalid cursor")
+	case errors.Is(err, db.ErrInvalidSyncToken):
+		return utils.BadRequest("invalid sync token")
+	case errors.Is(err, db.ErrVersionConflict):
+		return utils.Conflict("changed by another request, reload and try again")
+	default:
+		return utils.Internal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/auth"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Revokes the session the request was authenticated with
+func (s *APIServer) handleLogout(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	sessionID, _ := auth.SessionIDFromContext(r.Context())
+
+	if err := s.store.RevokeSession(user.ID, sessionID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"revoked": sessionID})
+}
+
+// handler for /me/sessions && /me/sessions/{session_id} endpoints (also under /users/{user_id})
+func (s *APIServer) handleSessions(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["session_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetSessions(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "DELETE":
+			return s.handleRevokeSession(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetSessions(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	currentID, _ := auth.SessionIDFromContext(r.Context())
+
+	sessions, err := s.store.GetSessionsByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, sessions)
+}
+
+func (s *APIServer) handleRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetSessionID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RevokeSession(userID, id); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"revoked": id})
+}
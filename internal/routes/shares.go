@@ -0,0 +1,346 @@
+package routes
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Loads a task the user owns or has been given access to, their role on it
+// has to be at least need. Tasks they cannot see at all are not found.
+func (s *APIServer) accessTask(user utils.User, id uuid.UUID, need utils.ShareRole) (utils.Task, error) {
+	task, role, err := s.store.GetAccessibleTask(user.ID, id)
+	if err != nil {
+		return task, err
+	}
+
+	if !role.Allows(need) {
+		return task, utils.Forbidden(fmt.Sprintf("%s access to the task required", need))
+	}
+
+	return task, nil
+}
+
+// Checks the users access to the task of the request path. Returns the owner
+// of the task, the stored data of the task belongs to them.
+func (s *APIServer) taskOwner(r *http.Request, need utils.ShareRole) (ownerID, taskID uuid.UUID, err error) {
+	user, err := currentUser(r)
+	if err != nil {
+		return ownerID, taskID, err
+	}
+
+	taskID, err = utils.GetTaskID(r)
+	if err != nil {
+		return ownerID, taskID, err
+	}
+
+	task, err := s.accessTask(user, taskID, need)
+	if err != nil {
+		return ownerID, taskID, err
+	}
+
+	return task.UserID, taskID, nil
+}
+
+// The task or project whose shares a request manages, only its owner can
+// see and change them
+type shareTarget struct {
+	taskID    *uuid.UUID
+	projectID *uuid.UUID
+}
+
+func (t shareTarget) matches(share utils.Share) bool {
+	if t.taskID != nil {
+		return share.TaskID != nil && *share.TaskID == *t.taskID
+	}
+	return share.ProjectID != nil && *share.ProjectID == *t.projectID
+}
+
+func (s *APIServer) getShareTarget(r *http.Request, user utils.User) (shareTarget, error) {
+	if _, ok := mux.Vars(r)["task_id"]; ok {
+		id, err := utils.GetTaskID(r)
+		if err != nil {
+			return shareTarget{}, err
+		}
+
+		task, err := s.accessTask(user, id, utils.RoleOwner)
+		if err != nil {
+			return shareTarget{}, err
+		}
+		if task.WorkspaceID != nil {
+			return shareTarget{}, utils.Conflict("tasks in a workspace are shared with its members")
+		}
+		return shareTarget{taskID: &id}, nil
+	}
+
+	id, err := utils.GetProjectID(r)
+	if err != nil {
+		return shareTarget{}, err
+	}
+
+	if _, err := s.store.GetProject(user.ID, id); err != nil {
+		return shareTarget{}, err
+	}
+	return shareTarget{projectID: &id}, nil
+}
+
+// handler for /me/tasks/{task_id}/shares, /me/projects/{project_id}/shares
+// and a single share under either
+func (s *APIServer) handleShares(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.getShareTarget(r, user)
+	if err != nil {
+		return err
+	}
+
+	_, hasID := mux.Vars(r)["share_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetShares(w, user, target)
+		case "POST":
+			return s.handleCreateShare(w, r, user, target)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "PUT":
+			return s.handleUpdateShare(w, r, user, target)
+		case "DELETE":
+			return s.handleDeleteShare(w, r, user, target)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetShares(w http.ResponseWriter, user utils.User, target shareTarget) error {
+	var shares []utils.Share
+	var err error
+	if target.taskID != nil {
+		shares, err = s.store.GetTaskShares(user.ID, *target.taskID)
+	} else {
+		shares, err = s.store.GetProjectShares(user.ID, *target.projectID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, shares)
+}
+
+// Invites the email to the task or project, the invitation is pending until
+// the user with that email accepts it
+func (s *APIServer) handleCreateShare(w http.ResponseWriter, r *http.Request, user utils.User, target shareTarget) error {
+	req := new(utils.ShareRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	if utils.NormalizeEmail(req.Email) == utils.NormalizeEmail(user.Email) {
+		return utils.InvalidField("email", "cannot share with yourself")
+	}
+
+	share := utils.NewShare(req, user.ID, target.taskID, target.projectID)
+	if err := s.store.CreateShare(share); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, share)
+}
+
+// Looks up a share of the target, shares of other tasks and projects are not found
+func (s *APIServer) getTargetShare(r *http.Request, user utils.User, target shareTarget) (utils.Share, error) {
+	id, err := utils.GetShareID(r)
+	if err != nil {
+		return utils.Share{}, err
+	}
+
+	share, err := s.store.GetShare(id)
+	if err != nil {
+		return share, err
+	}
+
+	if share.OwnerID != user.ID || !target.matches(share) {
+		return utils.Share{}, sql.ErrNoRows
+	}
+
+	return share, nil
+}
+
+// Changes the role of a share, also before the invitation is accepted
+func (s *APIServer) handleUpdateShare(w http.ResponseWriter, r *http.Request, user utils.User, target shareTarget) error {
+	req := new(utils.UpdateShareRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	share, err := s.getTargetShare(r, user, target)
+	if err != nil {
+		return err
+	}
+
+	share.Role = utils.ShareRole(req.Role)
+	if err := s.store.UpdateShare(share.ID, share); err != nil {
+		return err
+	}
+
+	updated, err := s.store.GetShare(share.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// Revokes the share or withdraws the invitation
+func (s *APIServer) handleDeleteShare(w http.ResponseWriter, r *http.Request, user utils.User, target shareTarget) error {
+	share, err := s.getTargetShare(r, user, target)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteShare(user.ID, share.ID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": share.ID})
+}
+
+// handler for /me/invitations, lists the pending invitations sent to the users email
+func (s *APIServer) handleInvitations(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := s.store.GetInvitations(user.Email)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, invitations)
+}
+
+// handler for /me/invitations/{share_id}/accept
+func (s *APIServer) handleAcceptInvitation(w http.ResponseWriter, r *http.Request) error {
+	return s.answerInvitation(w, r, utils.ShareAccepted)
+}
+
+// handler for /me/invitations/{share_id}/decline, also leaves an accepted share
+func (s *APIServer) handleDeclineInvitation(w http.ResponseWriter, r *http.Request) error {
+	return s.answerInvitation(w, r, utils.ShareDeclined)
+}
+
+func (s *APIServer) answerInvitation(w http.ResponseWriter, r *http.Request, status utils.ShareStatus) error {
+	if r.Method != "POST" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetShareID(r)
+	if err != nil {
+		return err
+	}
+
+	share, err := s.store.GetShare(id)
+	if err != nil {
+		return err
+	}
+
+	// Pending invitations are for the email they were sent to, accepted
+	// ones for the user who accepted them even if their email has changed
+	invited := share.Status == utils.SharePending && share.Email == utils.NormalizeEmail(user.Email)
+	joined := share.Status == utils.ShareAccepted && share.UserID != nil && *share.UserID == user.ID
+	if !invited && !joined {
+		return sql.ErrNoRows
+	}
+
+	if status == utils.ShareAccepted && joined {
+		return utils.Conflict("invitation already accepted")
+	}
+
+	share.Status = status
+	share.UserID = &user.ID
+	if err := s.store.UpdateShare(share.ID, share); err != nil {
+		return err
+	}
+
+	updated, err := s.store.GetShare(share.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// handler for /me/shared, lists the shares the user has accepted
+func (s *APIServer) handleSharedWithMe(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	shares, err := s.store.GetSharedWithUser(user.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, shares)
+}
+
+// handler for /me/shared/tasks, lists the tasks of other users shared with
+// the user directly or through a project, with their role on each
+func (s *APIServer) handleSharedTasks(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := s.store.GetSharedTasks(user.ID)
+	if err != nil {
+		return err
+	}
+
+	loc := user.Location()
+	for i := range tasks {
+		tasks[i].InLocation(loc)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, tasks)
+}
@@ -0,0 +1,182 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Shares the task or project of path with the email, the invitation is left pending
+func invite(t *testing.T, h *apitest.Harness, token, path, email string, role utils.ShareRole) utils.Share {
+	t.Helper()
+
+	var share utils.Share
+	if status := h.Do("POST", path+"/shares", token, utils.ShareRequest{Email: email, Role: string(role)}, &share); status != http.StatusOK {
+		t.Fatalf("share %s with %s: status %d", path, email, status)
+	}
+
+	return share
+}
+
+func acceptShare(t *testing.T, h *apitest.Harness, token string, share utils.Share) {
+	t.Helper()
+
+	if status := h.Do("POST", "/me/invitations/"+share.ID.String()+"/accept", token, nil, nil); status != http.StatusOK {
+		t.Fatalf("accept share %s: status %d", share.ID, status)
+	}
+}
+
+type accessCase struct {
+	method string
+	path   string
+	body   any
+	want   int
+}
+
+func checkAccess(t *testing.T, h *apitest.Harness, who, token string, tests []accessCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		if status := h.Do(tt.method, tt.path, token, tt.body, nil); status != tt.want {
+			t.Errorf("%s: %s %s: status %d, want %d", who, tt.method, tt.path, status, tt.want)
+		}
+	}
+}
+
+func TestShareRoles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, viewerToken := h.RegisterAndLogin("viewer", "viewer@tasklist.com", "Example1")
+		_, editorToken := h.RegisterAndLogin("editor", "editor@tasklist.com", "Example1")
+
+		task := createTask(t, h, ownerToken, map[string]any{"title": "Shared task", "deadline": "2030-12-01"})
+		path := "/me/tasks/" + task.ID.String()
+
+		acceptShare(t, h, viewerToken, invite(t, h, ownerToken, path, "viewer@tasklist.com", utils.RoleViewer))
+		editorShare := invite(t, h, ownerToken, path, "editor@tasklist.com", utils.RoleEditor)
+		acceptShare(t, h, editorToken, editorShare)
+
+		checkAccess(t, h, "viewer", viewerToken, []accessCase{
+			{"GET", path, nil, http.StatusOK},
+			{"GET", path + "/checklist", nil, http.StatusOK},
+			{"PUT", path, map[string]any{"title": "Viewer was here"}, http.StatusForbidden},
+			{"POST", path + "/checklist", map[string]any{"title": "Viewer step"}, http.StatusForbidden},
+			{"DELETE", path, nil, http.StatusForbidden},
+			{"GET", path + "/shares", nil, http.StatusForbidden},
+		})
+
+		checkAccess(t, h, "editor", editorToken, []accessCase{
+			{"GET", path, nil, http.StatusOK},
+			{"PUT", path, map[string]any{"title": "Editor was here"}, http.StatusOK},
+			{"POST", path + "/checklist", map[string]any{"title": "Editor step"}, http.StatusOK},
+			{"DELETE", path, nil, http.StatusForbidden},
+			{"GET", path + "/shares", nil, http.StatusForbidden},
+			{"POST", path + "/shares", utils.ShareRequest{Email: "viewer@tasklist.com", Role: "editor"}, http.StatusForbidden},
+		})
+
+		// Lowering the role takes effect on the next request
+		if status := h.Do("PUT", path+"/shares/"+editorShare.ID.String(), ownerToken, utils.UpdateShareRequest{Role: "viewer"}, nil); status != http.StatusOK {
+			t.Fatalf("change role: status %d", status)
+		}
+		checkAccess(t, h, "demoted editor", editorToken, []accessCase{
+			{"GET", path, nil, http.StatusOK},
+			{"PUT", path, map[string]any{"title": "Editor was here"}, http.StatusForbidden},
+		})
+
+		var got utils.Task
+		if status := h.Do("GET", path, ownerToken, nil, &got); status != http.StatusOK || got.Title != "Editor was here" || len(got.Checklist) != 1 {
+			t.Errorf("owner GET: status %d, task %+v", status, got)
+		}
+		if status := h.Do("DELETE", path, ownerToken, nil, nil); status != http.StatusOK {
+			t.Errorf("owner DELETE: status %d", status)
+		}
+	})
+}
+
+// Without an accepted share the task is not found, as if it did not exist
+func TestShareWithoutAccess(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, userToken := h.RegisterAndLogin("user", "user@tasklist.com", "Example1")
+
+		task := createTask(t, h, ownerToken, map[string]any{"title": "Shared task", "deadline": "2030-12-01"})
+		path := "/me/tasks/" + task.ID.String()
+
+		notFound := []accessCase{
+			{"GET", path, nil, http.StatusNotFound},
+			{"GET", path + "/checklist", nil, http.StatusNotFound},
+			{"PUT", path, map[string]any{"title": "Taken over"}, http.StatusNotFound},
+			{"DELETE", path, nil, http.StatusNotFound},
+		}
+
+		share := invite(t, h, ownerToken, path, "user@tasklist.com", utils.RoleEditor)
+		checkAccess(t, h, "pending", userToken, notFound)
+
+		decline := func() {
+			t.Helper()
+			if status := h.Do("POST", "/me/invitations/"+share.ID.String()+"/decline", userToken, nil, nil); status != http.StatusOK {
+				t.Fatalf("decline: status %d", status)
+			}
+		}
+		revoke := func() {
+			t.Helper()
+			if status := h.Do("DELETE", path+"/shares/"+share.ID.String(), ownerToken, nil, nil); status != http.StatusOK {
+				t.Fatalf("revoke: status %d", status)
+			}
+		}
+
+		decline()
+		checkAccess(t, h, "declined", userToken, notFound)
+
+		// The declined share is kept until the owner removes it
+		revoke()
+		share = invite(t, h, ownerToken, path, "user@tasklist.com", utils.RoleEditor)
+		acceptShare(t, h, userToken, share)
+		checkAccess(t, h, "accepted", userToken, []accessCase{{"GET", path, nil, http.StatusOK}})
+
+		revoke()
+		checkAccess(t, h, "revoked", userToken, notFound)
+
+		share = invite(t, h, ownerToken, path, "user@tasklist.com", utils.RoleEditor)
+		acceptShare(t, h, userToken, share)
+		decline()
+		checkAccess(t, h, "left", userToken, notFound)
+
+		var shared []utils.SharedTask
+		if status := h.Do("GET", "/me/shared/tasks", userToken, nil, &shared); status != http.StatusOK || len(shared) != 0 {
+			t.Errorf("shared tasks after leaving: status %d, %+v", status, shared)
+		}
+	})
+}
+
+func TestProjectShare(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, userToken := h.RegisterAndLogin("user", "user@tasklist.com", "Example1")
+
+		var project utils.Project
+		if status := h.Do("POST", "/me/projects", ownerToken, map[string]any{"name": "School"}, &project); status != http.StatusOK {
+			t.Fatalf("create project: status %d", status)
+		}
+		inProject := createTask(t, h, ownerToken, map[string]any{"title": "Project task", "deadline": "2030-12-01", "project_id": project.ID})
+		outside := createTask(t, h, ownerToken, map[string]any{"title": "Other task", "deadline": "2030-12-01"})
+
+		acceptShare(t, h, userToken, invite(t, h, ownerToken, "/me/projects/"+project.ID.String(), "user@tasklist.com", utils.RoleViewer))
+
+		checkAccess(t, h, "project viewer", userToken, []accessCase{
+			{"GET", "/me/tasks/" + inProject.ID.String(), nil, http.StatusOK},
+			{"PUT", "/me/tasks/" + inProject.ID.String(), map[string]any{"title": "Viewer was here"}, http.StatusForbidden},
+			{"GET", "/me/tasks/" + outside.ID.String(), nil, http.StatusNotFound},
+		})
+
+		var shared []utils.SharedTask
+		if status := h.Do("GET", "/me/shared/tasks", userToken, nil, &shared); status != http.StatusOK {
+			t.Fatalf("shared tasks: status %d", status)
+		}
+		if len(shared) != 1 || shared[0].ID != inProject.ID || shared[0].Role != utils.RoleViewer {
+			t.Errorf("shared tasks %+v, want the project task as a viewer", shared)
+		}
+	})
+}
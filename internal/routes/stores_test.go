@@ -0,0 +1,24 @@
+package routes_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+)
+
+// Runs the test against the API on a fresh memory store and a fresh SQLite database
+func forEachStore(t *testing.T, test func(t *testing.T, h *apitest.Harness)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, apitest.New(t))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := db.NewSQLiteStore(filepath.Join(t.TempDir(), "tasklist.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, apitest.NewWithStore(t, store))
+	})
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Reported for a change based on an outdated copy of the task
+var errSyncConflict = errors.New("sync conflict")
+
+// handler for /sync, lets offline clients fetch the task changes since
+// their last sync and upload the changes they made meanwhile
+func (s *APIServer) handleSync(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetChanges(w, r)
+	case "POST":
+		return s.handlePushChanges(w, r)
+	default:
+		return utils.MethodNotAllowed(r.Method)
+	}
+}
+
+// Lists the changes after ?since=<token>, every task without a token.
+// ?limit= sets the page size (default 200).
+func (s *APIServer) handleGetChanges(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	limit := utils.DefaultSyncLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > utils.MaxSyncLimit {
+			return utils.BadRequest(fmt.Sprintf("limit must be between 1 and %d", utils.MaxSyncLimit))
+		}
+	}
+
+	changes, err := s.store.GetTaskChanges(user.ID, r.URL.Query().Get("since"), limit)
+	if err != nil {
+		return err
+	}
+
+	loc := user.Location()
+	for i := range changes.Tasks {
+		changes.Tasks[i].InLocation(loc)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, changes)
+}
+
+// Applies the changes of the client in order. Every change gets its own
+// result, a rejected or conflicting change does not stop the rest.
+func (s *APIServer) handlePushChanges(w http.ResponseWriter, r *http.Request) error {
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	var req utils.SyncRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	results := make([]utils.SyncResult, 0, len(req.Changes))
+	for i, change := range req.Changes {
+		results = append(results, s.applySyncChange(user, i, change))
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, map[string][]utils.SyncResult{"results": results})
+}
+
+func (s *APIServer) applySyncChange(user utils.User, index int, change utils.SyncChange) utils.SyncResult {
+	result := utils.SyncResult{Op: change.Op, ClientID: change.ClientID, TaskID: change.TaskID}
+
+	task, err := s.syncChange(user, change)
+
+	switch {
+	case err == nil:
+		result.Status = utils.SyncApplied
+	case errors.Is(err, errSyncConflict) || errors.Is(err, db.ErrVersionConflict),
+		// Edited on the client while it was deleted on the server
+		change.Op == utils.SyncUpdate && errors.Is(err, sql.ErrNoRows):
+		result.Status = utils.SyncConflict
+		// The client resolves the conflict against the current task, none if it was deleted
+		if current, _, err := s.store.GetAccessibleTask(user.ID, *change.TaskID); err == nil {
+			task = &current
+		}
+	default:
+		httpErr := toHTTPError(err)
+		if httpErr.Status >= http.StatusInternalServerError {
+			log.Printf("Applying sync change %d: %v", index, err)
+		}
+		result.Status = utils.SyncRejected
+		result.Error = &utils.APIError{Error: httpErr.Message, Code: httpErr.Code, Details: httpErr.Details}
+	}
+
+	if task != nil {
+		task.InLocation(user.Location())
+		result.Task = task
+		result.TaskID = &task.ID
+	}
+
+	return result
+}
+
+// Applies one change, returns the saved task
+func (s *APIServer) syncChange(user utils.User, change utils.SyncChange) (*utils.Task, error) {
+	if err := utils.Validate(change); err != nil {
+		return nil, err
+	}
+
+	// Rejects changes made to an older copy of the task than the stored one
+	check := func(task utils.Task) error {
+		if change.BaseVersion != nil && task.Version != *change.BaseVersion {
+			return errSyncConflict
+		}
+		if change.BaseUpdatedAt != nil && task.UpdatedAt.After(*change.BaseUpdatedAt) {
+			return errSyncConflict
+		}
+		return nil
+	}
+
+	switch change.Op {
+	case utils.SyncCreate:
+		return s.createTask(user, nil, change.Task)
+
+	case utils.SyncUpdate:
+		updated, _, err := s.updateTask(user, *change.TaskID, change.Task, check)
+		if err != nil {
+			return nil, err
+		}
+		return &updated, nil
+
+	case utils.SyncDelete:
+		task, err := s.accessTask(user, *change.TaskID, utils.RoleOwner)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted already, on another device or by an earlier attempt of this sync
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := check(task); err != nil {
+			return nil, err
+		}
+
+		recipients, err := s.taskRecipients(task)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.store.DeleteTask(task.UserID, task.ID); err != nil {
+			return nil, err
+		}
+		s.emitAll(recipients, utils.EventTaskDeleted, utils.JSONres{"task_id": task.ID})
+
+		return nil, nil
+	}
+
+	return nil, utils.InvalidField("op", "must be one of: create, update, delete")
+}
@@ -0,0 +1,119 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func pushChanges(t *testing.T, h *apitest.Harness, token string, changes ...map[string]any) []utils.SyncResult {
+	t.Helper()
+
+	var res struct {
+		Results []utils.SyncResult `json:"results"`
+	}
+	if status := h.Do("POST", "/sync", token, map[string]any{"changes": changes}, &res); status != http.StatusOK {
+		t.Fatalf("POST /sync: status %d", status)
+	}
+	if len(res.Results) != len(changes) {
+		t.Fatalf("%d results for %d changes", len(res.Results), len(changes))
+	}
+
+	return res.Results
+}
+
+func TestSyncConflicts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Math homework", "deadline": "2030-12-01"})
+		stale := task.Version
+
+		// Changed on another device after the client read it
+		var current utils.Task
+		if status := h.Do("PUT", "/me/tasks/"+task.ID.String(), token, map[string]any{"title": "Physics homework"}, &current); status != http.StatusOK {
+			t.Fatalf("PUT: status %d", status)
+		}
+
+		body := map[string]any{"title": "Chemistry homework", "deadline": "2030-12-01"}
+		results := pushChanges(t, h, token,
+			map[string]any{"op": "update", "task_id": task.ID, "base_version": stale, "task": body},
+			map[string]any{"op": "delete", "task_id": task.ID, "base_version": stale},
+			map[string]any{"op": "create", "client_id": "new-1", "task": body},
+			map[string]any{"op": "update", "task_id": uuid.New(), "task": body},
+			map[string]any{"op": "create", "client_id": "new-2", "task": map[string]any{"title": "x"}},
+		)
+
+		for i, result := range results[:2] {
+			if result.Status != utils.SyncConflict || result.Task == nil || result.Task.Title != "Physics homework" {
+				t.Errorf("stale change %d: result %+v, want a conflict with the current task", i, result)
+			}
+		}
+		if result := results[2]; result.Status != utils.SyncApplied || result.ClientID != "new-1" || result.TaskID == nil || result.Task == nil {
+			t.Errorf("create: result %+v", result)
+		}
+		if result := results[3]; result.Status != utils.SyncConflict || result.Task != nil {
+			t.Errorf("update of a missing task: result %+v", result)
+		}
+		if result := results[4]; result.Status != utils.SyncRejected || result.Error == nil || result.Error.Code != "validation_failed" {
+			t.Errorf("invalid create: result %+v", result)
+		}
+
+		var got utils.Task
+		h.Do("GET", "/me/tasks/"+task.ID.String(), token, nil, &got)
+		if got.Title != "Physics homework" {
+			t.Errorf("stale update was saved, title %q", got.Title)
+		}
+
+		// Based on the current version the changes apply
+		results = pushChanges(t, h, token,
+			map[string]any{"op": "update", "task_id": task.ID, "base_version": current.Version, "task": body},
+		)
+		if result := results[0]; result.Status != utils.SyncApplied || result.Task == nil || result.Task.Title != "Chemistry homework" {
+			t.Fatalf("current update: result %+v", result)
+		}
+
+		results = pushChanges(t, h, token,
+			map[string]any{"op": "delete", "task_id": task.ID, "base_version": results[0].Task.Version},
+			map[string]any{"op": "delete", "task_id": task.ID},
+		)
+		for i, result := range results {
+			if result.Status != utils.SyncApplied {
+				t.Errorf("delete %d: result %+v", i, result)
+			}
+		}
+		if status := h.Do("GET", "/me/tasks/"+task.ID.String(), token, nil, nil); status != http.StatusNotFound {
+			t.Errorf("GET after delete: status %d", status)
+		}
+	})
+}
+
+// The changes pushed by one device reach another one through GET /sync, deletions as tombstones
+func TestSyncRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+		task := createTask(t, h, token, map[string]any{"title": "Math homework", "deadline": "2030-12-01"})
+
+		var first utils.SyncChanges
+		if status := h.Do("GET", "/sync", token, nil, &first); status != http.StatusOK || len(first.Tasks) != 1 {
+			t.Fatalf("first sync: status %d, %+v", status, first)
+		}
+
+		pushChanges(t, h, token, map[string]any{"op": "delete", "task_id": task.ID, "base_version": task.Version})
+
+		var second utils.SyncChanges
+		if status := h.Do("GET", "/sync?since="+first.Token, token, nil, &second); status != http.StatusOK {
+			t.Fatalf("second sync: status %d", status)
+		}
+		if len(second.Tasks) != 0 || len(second.Deleted) != 1 || second.Deleted[0].TaskID != task.ID {
+			t.Errorf("second sync %+v", second)
+		}
+
+		if status := h.Do("GET", "/sync?since=not-a-token", token, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("invalid token: status %d", status)
+		}
+	})
+}
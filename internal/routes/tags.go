@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// handler for /me/tags && /me/tags/{tag_id} endpoints
+func (s *APIServer) handleTags(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["tag_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetTags(w, r)
+		case "POST":
+			return s.handleCreateTag(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "PUT":
+			return s.handleUpdateTag(w, r)
+		case "DELETE":
+			return s.handleDeleteTag(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetTags(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	tags, err := s.store.GetTags(userID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, tags)
+}
+
+func (s *APIServer) handleCreateTag(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.TagBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	tag := &utils.Tag{Name: utils.NormalizeTagName(req.Name), UserID: userID}
+	if err := utils.Validate(tag); err != nil {
+		return err
+	}
+
+	if err := s.store.CreateTag(tag); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, tag)
+}
+
+// Renames a tag, the tasks it is attached to show the new name
+func (s *APIServer) handleUpdateTag(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetTagID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.TagBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	tag := utils.Tag{Name: utils.NormalizeTagName(req.Name), UserID: userID}
+	if err := utils.Validate(tag); err != nil {
+		return err
+	}
+
+	if err := s.store.UpdateTag(userID, id, tag); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"updated": id})
+}
+
+// Deletes a tag and removes it from every task
+func (s *APIServer) handleDeleteTag(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetTagID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteTag(userID, id); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
+}
@@ -0,0 +1,201 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func createTask(t *testing.T, h *apitest.Harness, token string, body map[string]any) utils.Task {
+	t.Helper()
+
+	var task utils.Task
+	if status := h.Do("POST", "/me/tasks", token, body, &task); status != http.StatusOK {
+		t.Fatalf("create task %v: status %d", body, status)
+	}
+
+	return task
+}
+
+// Sends a request with a raw JSON body and the given headers, the JSON
+// content type is used unless the headers set another one
+func requestWithHeaders(t *testing.T, h *apitest.Harness, method, path, token, body string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, h.Server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "JWT "+token)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := h.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	return res
+}
+
+func TestTaskCRUD(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		user, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Math homework", "description": "Pages 4-5", "deadline": "2030-12-01"})
+		if task.UserID != user.ID || task.Status != utils.StatusTodo || task.Title != "Math homework" {
+			t.Fatalf("created task %+v", task)
+		}
+
+		path := "/me/tasks/" + task.ID.String()
+
+		var got utils.Task
+		if status := h.Do("GET", path, token, nil, &got); status != http.StatusOK || got.ID != task.ID {
+			t.Fatalf("GET: status %d, task %+v", status, got)
+		}
+
+		var tasks []utils.Task
+		if status := h.Do("GET", "/me/tasks", token, nil, &tasks); status != http.StatusOK || len(tasks) != 1 {
+			t.Fatalf("list: status %d, %d tasks", status, len(tasks))
+		}
+		if status := h.Do("GET", "/tasks/"+user.ID.String(), token, nil, &tasks); status != http.StatusOK || len(tasks) != 1 {
+			t.Fatalf("list by user ID: status %d, %d tasks", status, len(tasks))
+		}
+
+		if status := h.Do("PUT", path, token, map[string]any{"title": "Physics homework", "status": "in_progress"}, nil); status != http.StatusOK {
+			t.Fatalf("PUT: status %d", status)
+		}
+
+		var updated utils.Task
+		h.Do("GET", path, token, nil, &updated)
+		if updated.Title != "Physics homework" || updated.Status != utils.StatusInProgress || updated.Description != "Pages 4-5" {
+			t.Errorf("updated task %+v", updated)
+		}
+
+		if status := h.Do("DELETE", path, token, nil, nil); status != http.StatusOK {
+			t.Fatalf("DELETE: status %d", status)
+		}
+		if status := h.Do("GET", path, token, nil, nil); status != http.StatusNotFound {
+			t.Errorf("GET after delete: status %d", status)
+		}
+		if status := h.Do("DELETE", path, token, nil, nil); status != http.StatusNotFound {
+			t.Errorf("second DELETE: status %d", status)
+		}
+	})
+}
+
+// Tasks of other users are not found rather than forbidden, so their IDs are not revealed
+func TestTaskOwnership(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		owner, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, otherToken := h.RegisterAndLogin("other", "other@tasklist.com", "Example1")
+
+		task := createTask(t, h, ownerToken, map[string]any{"title": "Private task", "deadline": "2030-12-01"})
+		path := "/me/tasks/" + task.ID.String()
+
+		tests := []struct {
+			method string
+			path   string
+			body   any
+			want   int
+		}{
+			{"GET", path, nil, http.StatusNotFound},
+			{"PUT", path, map[string]any{"title": "Taken over"}, http.StatusNotFound},
+			{"DELETE", path, nil, http.StatusNotFound},
+			{"GET", path + "/checklist", nil, http.StatusNotFound},
+			{"GET", "/tasks/" + owner.ID.String() + "/" + task.ID.String(), nil, http.StatusForbidden},
+			{"GET", "/tasks/" + owner.ID.String(), nil, http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			if status := h.Do(tt.method, tt.path, otherToken, tt.body, nil); status != tt.want {
+				t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, status, tt.want)
+			}
+		}
+
+		var tasks []utils.Task
+		if status := h.Do("GET", "/me/tasks", otherToken, nil, &tasks); status != http.StatusOK || len(tasks) != 0 {
+			t.Errorf("other users listing: status %d, %d tasks", status, len(tasks))
+		}
+
+		var got utils.Task
+		if status := h.Do("GET", path, ownerToken, nil, &got); status != http.StatusOK || got.Title != "Private task" {
+			t.Errorf("owner GET: status %d, task %+v", status, got)
+		}
+	})
+}
+
+func TestTaskETags(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, token := h.RegisterAndLogin("example", "example@tasklist.com", "Example1")
+
+		task := createTask(t, h, token, map[string]any{"title": "Math homework", "deadline": "2030-12-01"})
+		path := "/me/tasks/" + task.ID.String()
+
+		res := requestWithHeaders(t, h, "GET", path, token, "", nil)
+		tag := res.Header.Get("ETag")
+		if res.StatusCode != http.StatusOK || tag == "" {
+			t.Fatalf("GET: status %d, ETag %q", res.StatusCode, tag)
+		}
+
+		// The client has the current version already
+		for _, header := range []string{tag, "W/" + tag, `"0", ` + tag, "*"} {
+			res := requestWithHeaders(t, h, "GET", path, token, "", http.Header{"If-None-Match": {header}})
+			if res.StatusCode != http.StatusNotModified || res.Header.Get("ETag") != tag {
+				t.Errorf("If-None-Match %s: status %d, ETag %q", header, res.StatusCode, res.Header.Get("ETag"))
+			}
+		}
+		if res := requestWithHeaders(t, h, "GET", path, token, "", http.Header{"If-None-Match": {`"0"`}}); res.StatusCode != http.StatusOK {
+			t.Errorf("If-None-Match of another version: status %d", res.StatusCode)
+		}
+
+		res = requestWithHeaders(t, h, "PUT", path, token, `{"title":"Physics homework"}`, http.Header{"If-Match": {tag}})
+		newTag := res.Header.Get("ETag")
+		if res.StatusCode != http.StatusOK || newTag == "" || newTag == tag {
+			t.Fatalf("PUT with the current ETag: status %d, ETag %q after %q", res.StatusCode, newTag, tag)
+		}
+		if res := requestWithHeaders(t, h, "GET", path, token, "", nil); res.Header.Get("ETag") != newTag {
+			t.Errorf("GET after PUT: ETag %q, want %q", res.Header.Get("ETag"), newTag)
+		}
+		if res := requestWithHeaders(t, h, "GET", path, token, "", http.Header{"If-None-Match": {tag}}); res.StatusCode != http.StatusOK {
+			t.Errorf("If-None-Match of the old version: status %d", res.StatusCode)
+		}
+
+		// Changes based on the old version are rejected and leave the task as it is
+		tests := []struct {
+			method string
+			body   string
+			header string
+		}{
+			{"PUT", `{"title":"Stale homework"}`, tag},
+			{"PUT", `{"title":"Stale homework"}`, "W/" + newTag},
+			{"DELETE", "", tag},
+		}
+		for _, tt := range tests {
+			var apiErr utils.APIError
+			res := requestWithHeaders(t, h, tt.method, path, token, tt.body, http.Header{"If-Match": {tt.header}})
+			if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusPreconditionFailed || apiErr.Code != utils.CodePreconditionFailed {
+				t.Errorf("%s with If-Match %s: status %d, code %s", tt.method, tt.header, res.StatusCode, apiErr.Code)
+			}
+		}
+
+		var got utils.Task
+		if status := h.Do("GET", path, token, nil, &got); status != http.StatusOK || got.Title != "Physics homework" {
+			t.Errorf("task after the stale changes: status %d, %+v", status, got)
+		}
+
+		if res := requestWithHeaders(t, h, "DELETE", path, token, "", http.Header{"If-Match": {newTag}}); res.StatusCode != http.StatusOK {
+			t.Errorf("DELETE with the current ETag: status %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+	"github.com/sunikka/tasklist-backendGo/internal/webhooks"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// Queues the event for the webhooks of the user
+func (s *APIServer) enqueueWebhooks(userID uuid.UUID, event string, data any) {
+	payload, err := json.Marshal(webhooks.NewPayload(event, data))
+	if err == nil {
+		err = s.store.EnqueueWebhookEvent(userID, event, payload)
+	}
+
+	if err != nil {
+		log.Printf("Queueing %s webhooks failed: %v", event, err)
+	}
+}
+
+// handler for /me/webhooks && /me/webhooks/{webhook_id} endpoints
+func (s *APIServer) handleWebhooks(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["webhook_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWebhooks(w, r)
+		case "POST":
+			return s.handleCreateWebhook(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWebhookByID(w, r)
+		case "PUT":
+			return s.handleUpdateWebhook(w, r)
+		case "DELETE":
+			return s.handleDeleteWebhook(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+// The secret is only shown in the response to creating the webhook
+func (s *APIServer) handleGetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := s.store.GetWebhooks(userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, hooks)
+}
+
+func (s *APIServer) handleGetWebhookByID(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetWebhookID(r)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := s.store.GetWebhook(userID, id)
+	if err != nil {
+		return err
+	}
+
+	webhook.Secret = ""
+	return utils.WriteJSON(w, http.StatusOK, webhook)
+}
+
+func (s *APIServer) handleCreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.WebhookBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	webhook, err := utils.NewWebhook(req, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.Validate(webhook); err != nil {
+		return err
+	}
+
+	if err := webhooks.CheckURL(webhook.URL); err != nil {
+		return err
+	}
+
+	if err := s.store.CreateWebhook(webhook); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, webhook)
+}
+
+// Changes the URL, events, secret or active state of the webhook
+func (s *APIServer) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetWebhookID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.WebhookBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	webhook, err := s.store.GetWebhook(userID, id)
+	if err != nil {
+		return err
+	}
+
+	webhook.ModifyWebhook(req)
+	if err := utils.Validate(webhook); err != nil {
+		return err
+	}
+
+	if err := webhooks.CheckURL(webhook.URL); err != nil {
+		return err
+	}
+
+	if err := s.store.UpdateWebhook(userID, id, webhook); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"updated": id})
+}
+
+func (s *APIServer) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetWebhookID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteWebhook(userID, id); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
+}
+
+// handler for /me/webhooks/{webhook_id}/deliveries && /me/webhooks/{webhook_id}/deliveries/{delivery_id},
+// the delivery log of a webhook. ?limit= sets how many of the latest deliveries are listed (default 50).
+func (s *APIServer) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := utils.GetWebhookID(r)
+	if err != nil {
+		return err
+	}
+
+	if _, hasID := mux.Vars(r)["delivery_id"]; hasID {
+		id, err := utils.GetDeliveryID(r)
+		if err != nil {
+			return err
+		}
+
+		delivery, err := s.store.GetWebhookDelivery(userID, webhookID, id)
+		if err != nil {
+			return err
+		}
+
+		return utils.WriteJSON(w, http.StatusOK, delivery)
+	}
+
+	limit := defaultDeliveryLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			return utils.BadRequest("limit must be between 1 and " + strconv.Itoa(maxDeliveryLimit))
+		}
+	}
+
+	deliveries, err := s.store.GetWebhookDeliveries(userID, webhookID, limit)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, deliveries)
+}
+
+// handler for /me/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver, sends
+// the payload of the delivery again and responds with the new delivery
+func (s *APIServer) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := utils.GetWebhookID(r)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetDeliveryID(r)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := s.store.RedeliverWebhookDelivery(userID, webhookID, id)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, delivery)
+}
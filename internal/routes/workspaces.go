@@ -0,0 +1,714 @@
+package routes
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Loads the workspace of the request path for the user, their role in it has
+// to be at least need. Workspaces they are not a member of are not found.
+func (s *APIServer) accessWorkspace(r *http.Request, need utils.WorkspaceRole) (utils.User, utils.Workspace, error) {
+	user, err := currentUser(r)
+	if err != nil {
+		return user, utils.Workspace{}, err
+	}
+
+	id, err := utils.GetWorkspaceID(r)
+	if err != nil {
+		return user, utils.Workspace{}, err
+	}
+
+	workspace, err := s.store.GetWorkspace(user.ID, id)
+	if err != nil {
+		return user, workspace, err
+	}
+
+	if !workspace.Role.Allows(need) {
+		return user, workspace, utils.Forbidden(fmt.Sprintf("%s role in the workspace required", need))
+	}
+
+	return user, workspace, nil
+}
+
+// handler for /me/workspaces && /me/workspaces/{workspace_id} endpoints
+func (s *APIServer) handleWorkspaces(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["workspace_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWorkspaces(w, r)
+		case "POST":
+			return s.handleCreateWorkspace(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWorkspaceByID(w, r)
+		case "PUT":
+			return s.handleUpdateWorkspace(w, r)
+		case "DELETE":
+			return s.handleDeleteWorkspace(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+// Lists the workspaces the user is a member of, with their role in each
+func (s *APIServer) handleGetWorkspaces(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	workspaces, err := s.store.GetWorkspaces(userID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, workspaces)
+}
+
+// Creates a workspace with the user as its owner
+func (s *APIServer) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.WorkspaceBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	workspace := utils.NewWorkspace(req.Name)
+	if err := utils.Validate(workspace); err != nil {
+		return err
+	}
+
+	if err := s.store.CreateWorkspace(workspace, userID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, workspace)
+}
+
+func (s *APIServer) handleGetWorkspaceByID(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, workspace)
+}
+
+// Renames the workspace, admins and owners can do it
+func (s *APIServer) handleUpdateWorkspace(w http.ResponseWriter, r *http.Request) error {
+	user, workspace, err := s.accessWorkspace(r, utils.WorkspaceAdmin)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.WorkspaceBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	workspace.Name = utils.NewWorkspace(req.Name).Name
+	if err := utils.Validate(workspace); err != nil {
+		return err
+	}
+
+	if err := s.store.UpdateWorkspace(workspace.ID, workspace); err != nil {
+		return err
+	}
+
+	updated, err := s.store.GetWorkspace(user.ID, workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// Deletes the workspace with its tasks and projects, only owners can do it
+func (s *APIServer) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceOwner)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteWorkspace(workspace.ID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": workspace.ID})
+}
+
+// handler for /me/workspaces/{workspace_id}/members && /me/workspaces/{workspace_id}/members/{member_id},
+// the member ID is the user ID of the member
+func (s *APIServer) handleWorkspaceMembers(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["member_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWorkspaceMembers(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "PUT":
+			return s.handleUpdateWorkspaceMember(w, r)
+		case "DELETE":
+			return s.handleRemoveWorkspaceMember(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetWorkspaceMembers(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return err
+	}
+
+	members, err := s.store.GetWorkspaceMembers(workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, members)
+}
+
+// Looks up the member of the request path in the workspace
+func (s *APIServer) getWorkspaceMember(r *http.Request, workspace utils.Workspace) (utils.WorkspaceMembership, error) {
+	memberID, err := utils.GetMemberID(r)
+	if err != nil {
+		return utils.WorkspaceMembership{}, err
+	}
+
+	return s.store.GetWorkspaceMember(workspace.ID, memberID)
+}
+
+// A workspace always keeps an owner, the last one cannot leave or step down
+func (s *APIServer) checkLastOwner(workspace utils.Workspace, member utils.WorkspaceMembership) error {
+	if member.Role != utils.WorkspaceOwner {
+		return nil
+	}
+
+	members, err := s.store.GetWorkspaceMembers(workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range members {
+		if other.Role == utils.WorkspaceOwner && other.UserID != member.UserID {
+			return nil
+		}
+	}
+
+	return utils.Conflict("the workspace needs another owner first")
+}
+
+// The account of the last owner of a workspace cannot be deleted, the
+// workspace has to get another owner or be deleted first
+func (s *APIServer) checkOwnedWorkspaces(userID uuid.UUID) error {
+	workspaces, err := s.store.GetWorkspaces(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, workspace := range workspaces {
+		if workspace.Role != utils.WorkspaceOwner {
+			continue
+		}
+
+		members, err := s.store.GetWorkspaceMembers(workspace.ID)
+		if err != nil {
+			return err
+		}
+
+		if !slices.ContainsFunc(members, func(other utils.WorkspaceMembership) bool {
+			return other.Role == utils.WorkspaceOwner && other.UserID != userID
+		}) {
+			return utils.Conflict("the workspace " + workspace.Name + " needs another owner or to be deleted first")
+		}
+	}
+
+	return nil
+}
+
+// Changes the role of a member, only owners can do it
+func (s *APIServer) handleUpdateWorkspaceMember(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceOwner)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.UpdateMemberRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	member, err := s.getWorkspaceMember(r, workspace)
+	if err != nil {
+		return err
+	}
+
+	role := utils.WorkspaceRole(req.Role)
+	if role != utils.WorkspaceOwner {
+		if err := s.checkLastOwner(workspace, member); err != nil {
+			return err
+		}
+	}
+
+	if err := s.store.UpdateWorkspaceMember(workspace.ID, member.UserID, role); err != nil {
+		return err
+	}
+
+	member.Role = role
+	return utils.WriteJSON(w, http.StatusOK, member)
+}
+
+// Removes a member from the workspace, or lets the user leave it. Owners can
+// remove anyone, admins only the members below them.
+func (s *APIServer) handleRemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) error {
+	user, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return err
+	}
+
+	member, err := s.getWorkspaceMember(r, workspace)
+	if err != nil {
+		return err
+	}
+
+	if member.UserID != user.ID && workspace.Role != utils.WorkspaceOwner {
+		if !workspace.Role.Allows(utils.WorkspaceAdmin) {
+			return utils.Forbidden("admin role in the workspace required")
+		}
+		if !workspace.Role.Outranks(member.Role) {
+			return utils.Forbidden(fmt.Sprintf("only owners can remove a member with the %s role", member.Role))
+		}
+	}
+
+	if err := s.checkLastOwner(workspace, member); err != nil {
+		return err
+	}
+
+	if err := s.store.RemoveWorkspaceMember(workspace.ID, member.UserID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": member.UserID})
+}
+
+// handler for /me/workspaces/{workspace_id}/invitations && /me/workspaces/{workspace_id}/invitations/{invitation_id}
+func (s *APIServer) handleWorkspaceInvitations(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["invitation_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWorkspaceInvitations(w, r)
+		case "POST":
+			return s.handleCreateWorkspaceInvitation(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "DELETE":
+			return s.handleDeleteWorkspaceInvitation(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetWorkspaceInvitations(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceAdmin)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := s.store.GetWorkspaceInvitations(workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, invitations)
+}
+
+// Invites the email to the workspace as a member, or as an admin when an
+// owner invites them
+func (s *APIServer) handleCreateWorkspaceInvitation(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceAdmin)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.WorkspaceInvitationRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	invitation := utils.NewWorkspaceInvitation(req, workspace.ID)
+	if invitation.Role == utils.WorkspaceAdmin && workspace.Role != utils.WorkspaceOwner {
+		return utils.Forbidden("only owners can invite admins")
+	}
+
+	if invitee, err := s.store.GetUserByEmail(invitation.Email); err == nil {
+		if _, err := s.store.GetWorkspaceMember(workspace.ID, invitee.ID); err == nil {
+			return utils.Conflict("already a member of the workspace")
+		}
+	}
+
+	if err := s.store.CreateWorkspaceInvitation(invitation); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, invitation)
+}
+
+// Withdraws an invitation to the workspace
+func (s *APIServer) handleDeleteWorkspaceInvitation(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceAdmin)
+	if err != nil {
+		return err
+	}
+
+	id, err := utils.GetInvitationID(r)
+	if err != nil {
+		return err
+	}
+
+	invitation, err := s.store.GetWorkspaceInvitation(id)
+	if err != nil {
+		return err
+	}
+
+	if invitation.WorkspaceID != workspace.ID {
+		return sql.ErrNoRows
+	}
+
+	if err := s.store.DeleteWorkspaceInvitation(id); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": id})
+}
+
+// handler for /me/workspace-invitations, lists the invitations sent to the users email
+func (s *APIServer) handleMyWorkspaceInvitations(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := s.store.GetWorkspaceInvitationsByEmail(user.Email)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, invitations)
+}
+
+// Looks up an invitation sent to the users email, other invitations are not found
+func (s *APIServer) getMyWorkspaceInvitation(r *http.Request, user utils.User) (utils.WorkspaceInvitation, error) {
+	id, err := utils.GetInvitationID(r)
+	if err != nil {
+		return utils.WorkspaceInvitation{}, err
+	}
+
+	invitation, err := s.store.GetWorkspaceInvitation(id)
+	if err != nil {
+		return invitation, err
+	}
+
+	if invitation.Email != utils.NormalizeEmail(user.Email) {
+		return utils.WorkspaceInvitation{}, sql.ErrNoRows
+	}
+
+	return invitation, nil
+}
+
+// handler for /me/workspace-invitations/{invitation_id}/accept, joins the
+// workspace and responds with it
+func (s *APIServer) handleAcceptWorkspaceInvitation(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	invitation, err := s.getMyWorkspaceInvitation(r, user)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.AcceptWorkspaceInvitation(invitation.ID, user.ID); err != nil {
+		return err
+	}
+
+	workspace, err := s.store.GetWorkspace(user.ID, invitation.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, workspace)
+}
+
+// handler for /me/workspace-invitations/{invitation_id}/decline
+func (s *APIServer) handleDeclineWorkspaceInvitation(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return utils.MethodNotAllowed(r.Method)
+	}
+
+	user, err := currentUser(r)
+	if err != nil {
+		return err
+	}
+
+	invitation, err := s.getMyWorkspaceInvitation(r, user)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteWorkspaceInvitation(invitation.ID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"declined": invitation.ID})
+}
+
+// handler for /me/workspaces/{workspace_id}/tasks, lists the tasks of the
+// workspace with the same query parameters as the users task listing, or
+// creates a task in it. The tasks are then read and changed through
+// /me/tasks/{task_id} like personal ones.
+func (s *APIServer) handleWorkspaceTasks(w http.ResponseWriter, r *http.Request) error {
+	user, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case "GET":
+		filter, err := parseTaskFilter(r, user.Location())
+		if err != nil {
+			return err
+		}
+
+		page, err := s.store.GetWorkspaceTasks(workspace.ID, filter)
+		if err != nil {
+			return err
+		}
+
+		return writeTaskPage(w, r, user, page)
+
+	case "POST":
+		req := new(utils.TaskBodyRequest)
+		if err := utils.DecodeJSON(r, req); err != nil {
+			return err
+		}
+
+		created, err := s.createTask(user, &workspace.ID, req)
+		if err != nil {
+			return err
+		}
+
+		created.InLocation(user.Location())
+		return utils.WriteJSON(w, http.StatusOK, created)
+
+	default:
+		return utils.MethodNotAllowed(r.Method)
+	}
+}
+
+// handler for /me/workspaces/{workspace_id}/projects && /me/workspaces/{workspace_id}/projects/{project_id}
+func (s *APIServer) handleWorkspaceProjects(w http.ResponseWriter, r *http.Request) error {
+	_, hasID := mux.Vars(r)["project_id"]
+
+	if !hasID {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWorkspaceProjects(w, r)
+		case "POST":
+			return s.handleCreateWorkspaceProject(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+
+	} else {
+		switch r.Method {
+		case "GET":
+			return s.handleGetWorkspaceProject(w, r)
+		case "PUT":
+			return s.handleUpdateWorkspaceProject(w, r)
+		case "DELETE":
+			return s.handleDeleteWorkspaceProject(w, r)
+		default:
+			return utils.MethodNotAllowed(r.Method)
+		}
+	}
+}
+
+func (s *APIServer) handleGetWorkspaceProjects(w http.ResponseWriter, r *http.Request) error {
+	_, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return err
+	}
+
+	projects, err := s.store.GetWorkspaceProjects(workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, projects)
+}
+
+func (s *APIServer) handleCreateWorkspaceProject(w http.ResponseWriter, r *http.Request) error {
+	user, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return err
+	}
+
+	req := new(utils.ProjectBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	project := utils.NewProject(req.Name, req.Description, user.ID)
+	project.WorkspaceID = &workspace.ID
+	if err := utils.Validate(project); err != nil {
+		return err
+	}
+
+	if err := s.store.CreateProject(project); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, project)
+}
+
+// Looks up the project of the request path in the workspace. Unless manage
+// is false, the user has to have created it or be an admin of the workspace.
+func (s *APIServer) getWorkspaceProject(r *http.Request, manage bool) (utils.Workspace, utils.Project, error) {
+	user, workspace, err := s.accessWorkspace(r, utils.WorkspaceMember)
+	if err != nil {
+		return workspace, utils.Project{}, err
+	}
+
+	id, err := utils.GetProjectID(r)
+	if err != nil {
+		return workspace, utils.Project{}, err
+	}
+
+	project, err := s.store.GetWorkspaceProject(workspace.ID, id)
+	if err != nil {
+		return workspace, project, err
+	}
+
+	if manage && project.UserID != user.ID && !workspace.Role.Allows(utils.WorkspaceAdmin) {
+		return workspace, project, utils.Forbidden("admin role in the workspace required")
+	}
+
+	return workspace, project, nil
+}
+
+func (s *APIServer) handleGetWorkspaceProject(w http.ResponseWriter, r *http.Request) error {
+	_, project, err := s.getWorkspaceProject(r, false)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, project)
+}
+
+func (s *APIServer) handleUpdateWorkspaceProject(w http.ResponseWriter, r *http.Request) error {
+	req := new(utils.ProjectBodyRequest)
+	if err := utils.DecodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	_, project, err := s.getWorkspaceProject(r, true)
+	if err != nil {
+		return err
+	}
+
+	project.ModifyProject(req)
+	if err := utils.Validate(project); err != nil {
+		return err
+	}
+
+	if err := s.store.UpdateProject(project.UserID, project.ID, project); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"updated": project.ID})
+}
+
+// Deletes the project of the workspace, ?tasks= works like for personal projects
+func (s *APIServer) handleDeleteWorkspaceProject(w http.ResponseWriter, r *http.Request) error {
+	deleteTasks, err := parseTasksMode(r)
+	if err != nil {
+		return err
+	}
+
+	workspace, project, err := s.getWorkspaceProject(r, true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteWorkspaceProject(workspace.ID, project.ID, deleteTasks); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, utils.JSONres{"deleted": project.ID})
+}
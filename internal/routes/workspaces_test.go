@@ -0,0 +1,192 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/apitest"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func createWorkspace(t *testing.T, h *apitest.Harness, token, name string) string {
+	t.Helper()
+
+	var workspace utils.Workspace
+	if status := h.Do("POST", "/me/workspaces", token, utils.WorkspaceBodyRequest{Name: name}, &workspace); status != http.StatusOK {
+		t.Fatalf("create workspace: status %d", status)
+	}
+
+	return "/me/workspaces/" + workspace.ID.String()
+}
+
+// Invites the email to the workspace of path and accepts the invitation with token
+func joinWorkspace(t *testing.T, h *apitest.Harness, ownerToken, path, token, email string) {
+	t.Helper()
+
+	var invitation utils.WorkspaceInvitation
+	if status := h.Do("POST", path+"/invitations", ownerToken, utils.WorkspaceInvitationRequest{Email: email}, &invitation); status != http.StatusOK {
+		t.Fatalf("invite %s: status %d", email, status)
+	}
+	if status := h.Do("POST", "/me/workspace-invitations/"+invitation.ID.String()+"/accept", token, nil, nil); status != http.StatusOK {
+		t.Fatalf("accept invitation of %s: status %d", email, status)
+	}
+}
+
+func createWorkspaceProject(t *testing.T, h *apitest.Harness, token, path, name string) string {
+	t.Helper()
+
+	var project utils.Project
+	if status := h.Do("POST", path+"/projects", token, map[string]any{"name": name}, &project); status != http.StatusOK {
+		t.Fatalf("create project %s: status %d", name, status)
+	}
+
+	return path + "/projects/" + project.ID.String()
+}
+
+func setMemberRole(t *testing.T, h *apitest.Harness, ownerToken, path string, memberID uuid.UUID, role utils.WorkspaceRole) {
+	t.Helper()
+
+	if status := h.Do("PUT", path+"/members/"+memberID.String(), ownerToken, utils.UpdateMemberRequest{Role: string(role)}, nil); status != http.StatusOK {
+		t.Fatalf("change role to %s: status %d", role, status)
+	}
+}
+
+// Outside the workspace its tasks and projects are not found, as if they did not exist
+func TestWorkspaceIsolation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, memberToken := h.RegisterAndLogin("member", "member@tasklist.com", "Example1")
+		_, outsiderToken := h.RegisterAndLogin("outsider", "outsider@tasklist.com", "Example1")
+
+		path := createWorkspace(t, h, ownerToken, "Study group")
+		joinWorkspace(t, h, ownerToken, path, memberToken, "member@tasklist.com")
+
+		var task utils.Task
+		if status := h.Do("POST", path+"/tasks", ownerToken, map[string]any{"title": "Group task", "deadline": "2030-12-01"}, &task); status != http.StatusOK {
+			t.Fatalf("create workspace task: status %d", status)
+		}
+		taskPath := "/me/tasks/" + task.ID.String()
+		projectPath := createWorkspaceProject(t, h, ownerToken, path, "Group project")
+
+		checkAccess(t, h, "outsider", outsiderToken, []accessCase{
+			{"GET", path, nil, http.StatusNotFound},
+			{"GET", path + "/members", nil, http.StatusNotFound},
+			{"GET", path + "/tasks", nil, http.StatusNotFound},
+			{"POST", path + "/tasks", map[string]any{"title": "Outsider task", "deadline": "2030-12-01"}, http.StatusNotFound},
+			{"GET", path + "/projects", nil, http.StatusNotFound},
+			{"POST", path + "/projects", map[string]any{"name": "Outsider project"}, http.StatusNotFound},
+			{"GET", projectPath, nil, http.StatusNotFound},
+			{"DELETE", projectPath, nil, http.StatusNotFound},
+			{"GET", taskPath, nil, http.StatusNotFound},
+			{"PUT", taskPath, map[string]any{"title": "Taken over"}, http.StatusNotFound},
+			{"DELETE", taskPath, nil, http.StatusNotFound},
+		})
+
+		var listed []utils.Task
+		if status := h.Do("GET", path+"/tasks", memberToken, nil, &listed); status != http.StatusOK || len(listed) != 1 || listed[0].ID != task.ID {
+			t.Errorf("member listing: status %d, tasks %+v", status, listed)
+		}
+
+		// Workspace tasks stay out of the personal listings
+		if status := h.Do("GET", "/me/tasks", ownerToken, nil, &listed); status != http.StatusOK || len(listed) != 0 {
+			t.Errorf("personal listing: status %d, tasks %+v", status, listed)
+		}
+	})
+}
+
+func TestWorkspaceRoles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		owner, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		_, creatorToken := h.RegisterAndLogin("creator", "creator@tasklist.com", "Example1")
+		member, memberToken := h.RegisterAndLogin("member", "member@tasklist.com", "Example1")
+
+		path := createWorkspace(t, h, ownerToken, "Study group")
+		joinWorkspace(t, h, ownerToken, path, creatorToken, "creator@tasklist.com")
+		joinWorkspace(t, h, ownerToken, path, memberToken, "member@tasklist.com")
+
+		var task utils.Task
+		if status := h.Do("POST", path+"/tasks", creatorToken, map[string]any{"title": "Creator task", "deadline": "2030-12-01"}, &task); status != http.StatusOK {
+			t.Fatalf("create workspace task: status %d", status)
+		}
+		taskPath := "/me/tasks/" + task.ID.String()
+		projectPath := createWorkspaceProject(t, h, creatorToken, path, "Creator project")
+
+		// Members change the tasks of others but only admins delete them or manage their projects
+		checkAccess(t, h, "member", memberToken, []accessCase{
+			{"GET", taskPath, nil, http.StatusOK},
+			{"PUT", taskPath, map[string]any{"title": "Member was here"}, http.StatusOK},
+			{"DELETE", taskPath, nil, http.StatusForbidden},
+			{"GET", projectPath, nil, http.StatusOK},
+			{"PUT", projectPath, map[string]any{"name": "Member project"}, http.StatusForbidden},
+			{"DELETE", projectPath, nil, http.StatusForbidden},
+			{"GET", path + "/invitations", nil, http.StatusForbidden},
+			{"PUT", path, utils.WorkspaceBodyRequest{Name: "Member group"}, http.StatusForbidden},
+			{"PUT", path + "/members/" + member.ID.String(), utils.UpdateMemberRequest{Role: "owner"}, http.StatusForbidden},
+		})
+
+		// Role changes take effect on the next request
+		setMemberRole(t, h, ownerToken, path, member.ID, utils.WorkspaceAdmin)
+		checkAccess(t, h, "admin", memberToken, []accessCase{
+			{"PUT", projectPath, map[string]any{"name": "Admin project"}, http.StatusOK},
+			{"GET", path + "/invitations", nil, http.StatusOK},
+			{"DELETE", path + "/members/" + owner.ID.String(), nil, http.StatusForbidden},
+			{"PUT", path + "/members/" + member.ID.String(), utils.UpdateMemberRequest{Role: "owner"}, http.StatusForbidden},
+		})
+
+		setMemberRole(t, h, ownerToken, path, member.ID, utils.WorkspaceMember)
+		checkAccess(t, h, "demoted admin", memberToken, []accessCase{
+			{"DELETE", taskPath, nil, http.StatusForbidden},
+			{"DELETE", projectPath, nil, http.StatusForbidden},
+			{"GET", path + "/invitations", nil, http.StatusForbidden},
+		})
+
+		setMemberRole(t, h, ownerToken, path, member.ID, utils.WorkspaceAdmin)
+		checkAccess(t, h, "admin again", memberToken, []accessCase{
+			{"DELETE", projectPath, nil, http.StatusOK},
+			{"DELETE", taskPath, nil, http.StatusOK},
+		})
+
+		if status := h.Do("PUT", path+"/members/"+owner.ID.String(), ownerToken, utils.UpdateMemberRequest{Role: "admin"}, nil); status != http.StatusConflict {
+			t.Errorf("last owner stepping down: status %d, want 409", status)
+		}
+	})
+}
+
+// A removed member loses access to the workspace right away
+func TestWorkspaceMemberRemoval(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h *apitest.Harness) {
+		_, ownerToken := h.RegisterAndLogin("owner", "owner@tasklist.com", "Example1")
+		member, memberToken := h.RegisterAndLogin("member", "member@tasklist.com", "Example1")
+
+		path := createWorkspace(t, h, ownerToken, "Study group")
+		joinWorkspace(t, h, ownerToken, path, memberToken, "member@tasklist.com")
+
+		var task utils.Task
+		if status := h.Do("POST", path+"/tasks", memberToken, map[string]any{"title": "Member task", "deadline": "2030-12-01"}, &task); status != http.StatusOK {
+			t.Fatalf("create workspace task: status %d", status)
+		}
+		taskPath := "/me/tasks/" + task.ID.String()
+
+		checkAccess(t, h, "member", memberToken, []accessCase{
+			{"GET", path + "/tasks", nil, http.StatusOK},
+			{"GET", taskPath, nil, http.StatusOK},
+		})
+
+		if status := h.Do("DELETE", path+"/members/"+member.ID.String(), ownerToken, nil, nil); status != http.StatusOK {
+			t.Fatalf("remove member: status %d", status)
+		}
+
+		checkAccess(t, h, "removed member", memberToken, []accessCase{
+			{"GET", path, nil, http.StatusNotFound},
+			{"GET", path + "/tasks", nil, http.StatusNotFound},
+			{"GET", path + "/projects", nil, http.StatusNotFound},
+			{"GET", taskPath, nil, http.StatusNotFound},
+			{"PUT", taskPath, map[string]any{"title": "Still here"}, http.StatusNotFound},
+			{"DELETE", taskPath, nil, http.StatusNotFound},
+		})
+
+		// The task stays in the workspace
+		checkAccess(t, h, "owner", ownerToken, []accessCase{{"GET", taskPath, nil, http.StatusOK}})
+	})
+}
@@ -0,0 +1,253 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used for
+// recurring tasks: DAILY, WEEKLY and MONTHLY frequencies with INTERVAL,
+// BYDAY (plain weekdays, no ordinals) and COUNT or UNTIL.
+//
+//	rule, err := rrule.Parse("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH")
+//	next, ok := rule.Next(deadline)
+//
+// Occurrences keep the time of day and location of the time they are computed from.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Upper bound of the periods searched for the next occurrence, so that a rule
+// that never matches (e.g. the 31st of every second February) cannot loop forever
+const maxPeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Weekdays in the order they appear in a week, weeks start on Monday like in RFC 5545
+var weekOrder = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+type Rule struct {
+	Freq Frequency
+	// Number of periods between occurrences, at least 1
+	Interval int
+	// Limits the occurrences to these weekdays. A weekly rule without
+	// ByDay repeats on the weekday it is computed from.
+	ByDay []time.Weekday
+	// Total number of occurrences, 0 for no limit
+	Count int
+	// Last possible occurrence (inclusive), zero for no limit
+	Until time.Time
+}
+
+// Parses a rule like "FREQ=DAILY;INTERVAL=2;COUNT=10", an "RRULE:" prefix is allowed
+func Parse(s string) (Rule, error) {
+	rule := Rule{Interval: 1}
+
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	if s == "" {
+		return rule, errors.New("empty recurrence rule")
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf("invalid rule part: %q", part)
+		}
+		if seen[key] {
+			return rule, fmt.Errorf("duplicate rule part: %s", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return rule, fmt.Errorf("unsupported FREQ: %s", value)
+			}
+
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return rule, fmt.Errorf("invalid INTERVAL: %s", value)
+			}
+			rule.Interval = interval
+
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return rule, fmt.Errorf("unsupported BYDAY value: %s", day)
+				}
+				if !slices.Contains(rule.ByDay, weekday) {
+					rule.ByDay = append(rule.ByDay, weekday)
+				}
+			}
+
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return rule, fmt.Errorf("invalid COUNT: %s", value)
+			}
+			rule.Count = count
+
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = until
+
+		default:
+			return rule, fmt.Errorf("unsupported rule part: %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, errors.New("COUNT and UNTIL cannot be used together")
+	}
+
+	return rule, nil
+}
+
+// UNTIL is either a UTC date-time (20240131T120000Z) or a date (20240131),
+// a date covers the whole day
+func parseUntil(value string) (time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return until, nil
+	}
+
+	until, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid UNTIL: %s", value)
+	}
+
+	return until.Add(24*time.Hour - time.Second), nil
+}
+
+// Formats the rule in its canonical form, which Parse reads back
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, weekday := range weekOrder {
+			if slices.Contains(r.ByDay, weekday) {
+				days = append(days, strings.ToUpper(weekday.String()[:2]))
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Returns the first occurrence after the given occurrence, false when the
+// rule has ended by UNTIL. COUNT is not checked here since it depends on the
+// number of earlier occurrences, which only the caller knows.
+func (r Rule) Next(after time.Time) (time.Time, bool) {
+	interval := max(r.Interval, 1)
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.period(after, period*interval) {
+			if !candidate.After(after) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Occurrences in the period offset periods from the one containing start, in order
+func (r Rule) period(start time.Time, offset int) []time.Time {
+	year, month, day := start.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	switch r.Freq {
+	case Daily:
+		date := at(year, month, day+offset)
+		if len(r.ByDay) > 0 && !slices.Contains(r.ByDay, date.Weekday()) {
+			return nil
+		}
+		return []time.Time{date}
+
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+
+		// Days since the Monday of the week
+		sinceMonday := (int(start.Weekday()) + 6) % 7
+		monday := day - sinceMonday + offset*7
+
+		var dates []time.Time
+		for i, weekday := range weekOrder {
+			if slices.Contains(days, weekday) {
+				dates = append(dates, at(year, month, monday+i))
+			}
+		}
+		return dates
+
+	case Monthly:
+		first := at(year, month+time.Month(offset), 1)
+		daysInMonth := at(first.Year(), first.Month()+1, 0).Day()
+
+		if len(r.ByDay) == 0 {
+			// Months without the day are skipped like RFC 5545 does
+			if day > daysInMonth {
+				return nil
+			}
+			return []time.Time{at(first.Year(), first.Month(), day)}
+		}
+
+		var dates []time.Time
+		for d := 1; d <= daysInMonth; d++ {
+			date := at(first.Year(), first.Month(), d)
+			if slices.Contains(r.ByDay, date.Weekday()) {
+				dates = append(dates, date)
+			}
+		}
+		return dates
+	}
+
+	return nil
+}
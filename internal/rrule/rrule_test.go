@@ -0,0 +1,252 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=weekly;byday=th,mo", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"FREQ=DAILY;INTERVAL=1", "FREQ=DAILY"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,MO,MO", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU"},
+		{"FREQ=MONTHLY;COUNT=3", "FREQ=MONTHLY;COUNT=3"},
+		{"FREQ=DAILY;UNTIL=20240131", "FREQ=DAILY;UNTIL=20240131T235959Z"},
+		{"FREQ=DAILY;UNTIL=20240131T120000Z", "FREQ=DAILY;UNTIL=20240131T120000Z"},
+	}
+
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.rule, got, tt.want)
+		}
+
+		// The canonical form is read back to the same rule
+		again, err := Parse(rule.String())
+		if err != nil || again.String() != tt.want {
+			t.Errorf("Parse(%q) = %q, %v", rule.String(), again.String(), err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"FREQ",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYDAY=MO,XX",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;UNTIL=2024-01-01",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;BYMONTH=1",
+	} {
+		if _, err := Parse(rule); err == nil {
+			t.Errorf("Parse(%q) accepted the rule", rule)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	helsinki, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utc := func(date string) time.Time {
+		d, err := time.Parse("2006-01-02 15:04", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	in := func(loc *time.Location, date string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", date, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		name  string
+		rule  string
+		after time.Time
+		want  []time.Time
+		// Whether the rule has no occurrences after the wanted ones
+		ended bool
+	}{
+		{
+			name:  "daily",
+			rule:  "FREQ=DAILY",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-03 09:00"), utc("2024-01-04 09:00"), utc("2024-01-05 09:00")},
+		},
+		{
+			name:  "daily interval",
+			rule:  "FREQ=DAILY;INTERVAL=3",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-05 09:00"), utc("2024-01-08 09:00"), utc("2024-01-11 09:00")},
+		},
+		{
+			name:  "daily on weekdays",
+			rule:  "FREQ=DAILY;BYDAY=MO,WE,FR",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-03 09:00"), utc("2024-01-05 09:00"), utc("2024-01-08 09:00")},
+		},
+		{
+			name:  "weekly on the same weekday",
+			rule:  "FREQ=WEEKLY",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-09 09:00"), utc("2024-01-16 09:00")},
+		},
+		{
+			name:  "weekly by day",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-04 09:00"), utc("2024-01-08 09:00"), utc("2024-01-11 09:00")},
+		},
+		{
+			name:  "every second week by day",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-04 09:00"), utc("2024-01-15 09:00"), utc("2024-01-18 09:00"), utc("2024-01-29 09:00")},
+		},
+		{
+			name:  "weeks start on monday",
+			rule:  "FREQ=WEEKLY;BYDAY=SU",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-07 09:00"), utc("2024-01-14 09:00")},
+		},
+		{
+			name:  "monthly skips months without the day",
+			rule:  "FREQ=MONTHLY",
+			after: utc("2024-01-31 09:00"),
+			want:  []time.Time{utc("2024-03-31 09:00"), utc("2024-05-31 09:00"), utc("2024-07-31 09:00"), utc("2024-08-31 09:00")},
+		},
+		{
+			name:  "monthly on the 29th in a leap year",
+			rule:  "FREQ=MONTHLY;INTERVAL=12",
+			after: utc("2024-02-29 09:00"),
+			want:  []time.Time{utc("2028-02-29 09:00")},
+		},
+		{
+			name:  "every second month",
+			rule:  "FREQ=MONTHLY;INTERVAL=2",
+			after: utc("2024-01-15 09:00"),
+			want:  []time.Time{utc("2024-03-15 09:00"), utc("2024-05-15 09:00")},
+		},
+		{
+			name:  "monthly by day",
+			rule:  "FREQ=MONTHLY;BYDAY=FR",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-05 09:00"), utc("2024-01-12 09:00"), utc("2024-01-19 09:00"), utc("2024-01-26 09:00"), utc("2024-02-02 09:00")},
+		},
+		{
+			name:  "until a date covers the day",
+			rule:  "FREQ=DAILY;UNTIL=20240104",
+			after: utc("2024-01-02 23:00"),
+			want:  []time.Time{utc("2024-01-03 23:00"), utc("2024-01-04 23:00")},
+			ended: true,
+		},
+		{
+			name:  "until a time is inclusive",
+			rule:  "FREQ=DAILY;UNTIL=20240103T090000Z",
+			after: utc("2024-01-02 09:00"),
+			want:  []time.Time{utc("2024-01-03 09:00")},
+			ended: true,
+		},
+		{
+			name:  "until before the next occurrence",
+			rule:  "FREQ=WEEKLY;UNTIL=20240105",
+			after: utc("2024-01-02 09:00"),
+			ended: true,
+		},
+		{
+			name:  "keeps the local time when DST starts",
+			rule:  "FREQ=DAILY",
+			after: in(newYork, "2024-03-09 09:00"),
+			want:  []time.Time{in(newYork, "2024-03-10 09:00"), in(newYork, "2024-03-11 09:00")},
+		},
+		{
+			name:  "keeps the local time when DST ends",
+			rule:  "FREQ=WEEKLY",
+			after: in(helsinki, "2024-10-21 08:00"),
+			want:  []time.Time{in(helsinki, "2024-10-28 08:00"), in(helsinki, "2024-11-04 08:00")},
+		},
+		{
+			// Every seventh day is a Tuesday, the search gives up after maxPeriods
+			name:  "never matching rule",
+			rule:  "FREQ=DAILY;INTERVAL=7;BYDAY=MO",
+			after: utc("2024-01-02 09:00"),
+			ended: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			after := tt.after
+			for i, want := range tt.want {
+				next, ok := rule.Next(after)
+				if !ok {
+					t.Fatalf("occurrence %d: rule ended, want %s", i+1, want)
+				}
+				if !next.Equal(want) {
+					t.Fatalf("occurrence %d: %s, want %s", i+1, next, want)
+				}
+				if next.Location() != after.Location() {
+					t.Errorf("occurrence %d is in %s, want %s", i+1, next.Location(), after.Location())
+				}
+				after = next
+			}
+
+			if next, ok := rule.Next(after); tt.ended && ok {
+				t.Errorf("rule did not end, next occurrence %s", next)
+			} else if !tt.ended && !ok {
+				t.Error("rule ended")
+			}
+		})
+	}
+}
+
+// The DST cases above only check the local time if the offset changes between the occurrences
+func TestNextAcrossDSTChangesUTCOffset(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Date(2024, 3, 9, 9, 0, 0, 0, newYork)
+	next, _ := rule.Next(before)
+
+	if got := next.Sub(before); got != 23*time.Hour {
+		t.Errorf("a day across the start of DST is %s, want 23h", got)
+	}
+}
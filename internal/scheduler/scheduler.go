@@ -0,0 +1,144 @@
+// Package scheduler runs background jobs of the server. Every instance runs
+// its own scheduler, jobs claim their work from the database so that it is
+// done once even when several instances share it.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/notify"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+	"github.com/sunikka/tasklist-backendGo/internal/webhooks"
+)
+
+// Sends due reminders through a notifier and delivers queued webhook events
+type Scheduler struct {
+	store    db.Storage
+	notifier notify.Notifier
+	client   *http.Client
+
+	// Time between polls for due work
+	Interval time.Duration
+	// Reminders or webhook deliveries claimed per poll
+	BatchSize int
+	// How long claimed work is held before another instance may
+	// claim it, must be longer than a delivery can take
+	Lease time.Duration
+	// Deliveries of a reminder or webhook event before it is given up on
+	MaxAttempts int
+	// Delay of the first retry, doubled for every failed attempt
+	RetryDelay time.Duration
+	// Longest delay between retries
+	MaxRetryDelay time.Duration
+}
+
+func New(store db.Storage, notifier notify.Notifier) *Scheduler {
+	return &Scheduler{
+		store:         store,
+		notifier:      notifier,
+		client:        webhooks.NewClient(10 * time.Second),
+		Interval:      30 * time.Second,
+		BatchSize:     50,
+		Lease:         2 * time.Minute,
+		MaxAttempts:   5,
+		RetryDelay:    time.Minute,
+		MaxRetryDelay: time.Hour,
+	}
+}
+
+// Sends due reminders and webhook events every Interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SendReminders(ctx); err != nil {
+			log.Println("Sending reminders failed:", err)
+		}
+		if _, err := s.DeliverWebhooks(ctx); err != nil {
+			log.Println("Delivering webhooks failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sends the reminders that are due now, returns how many were claimed.
+// Deliveries that fail are scheduled for a retry with a growing delay.
+func (s *Scheduler) SendReminders(ctx context.Context) (int, error) {
+	reminders, err := s.store.ClaimDueReminders(s.BatchSize, s.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, reminder := range reminders {
+		if ctx.Err() != nil {
+			// The rest are claimed again after the lease
+			break
+		}
+
+		if err := s.send(ctx, reminder); err != nil {
+			log.Printf("Reminder %s: %v", reminder.ID, err)
+		}
+	}
+
+	return len(reminders), nil
+}
+
+func (s *Scheduler) send(ctx context.Context, reminder utils.Reminder) error {
+	task, err := s.store.GetTaskById(reminder.UserID, reminder.TaskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The task was deleted with its reminders after the claim
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if task.Status == utils.StatusDone || task.Status == utils.StatusCancelled {
+		return s.store.FailReminder(reminder.ID, fmt.Sprintf("task is %s", task.Status))
+	}
+
+	user, err := s.store.GetUserById(reminder.UserID)
+	if err != nil {
+		return err
+	}
+
+	loc := user.Location()
+	task.InLocation(loc)
+	reminder.InLocation(loc)
+
+	sendCtx, cancel := context.WithTimeout(ctx, s.Lease/2)
+	defer cancel()
+
+	err = s.notifier.Notify(sendCtx, notify.Message{Reminder: reminder, Task: task, User: user})
+	if err == nil {
+		return s.store.MarkReminderSent(reminder.ID)
+	}
+
+	if reminder.Attempts+1 >= s.MaxAttempts {
+		return errors.Join(err, s.store.FailReminder(reminder.ID, err.Error()))
+	}
+
+	return errors.Join(err, s.store.RetryReminder(reminder.ID, err.Error(), time.Now().Add(s.retryDelay(reminder.Attempts))))
+}
+
+// Delay before retrying work that has failed attempts times before
+func (s *Scheduler) retryDelay(attempts int) time.Duration {
+	delay := s.RetryDelay
+	for i := 0; i < attempts && delay < s.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.MaxRetryDelay)
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+	"github.com/sunikka/tasklist-backendGo/internal/webhooks"
+)
+
+// Sends the webhook deliveries that are due now, returns how many were claimed.
+// A delivery succeeds when the webhook responds with a 2xx status, other
+// responses and network errors are retried with a growing delay.
+func (s *Scheduler) DeliverWebhooks(ctx context.Context) (int, error) {
+	deliveries, err := s.store.ClaimDueWebhookDeliveries(s.BatchSize, s.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err := s.deliver(ctx, delivery); err != nil {
+			log.Printf("Webhook delivery %s: %v", delivery.ID, err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (s *Scheduler) deliver(ctx context.Context, delivery utils.WebhookDelivery) error {
+	webhook, err := s.store.GetWebhook(delivery.UserID, delivery.WebhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted with its deliveries after the claim
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !webhook.Active {
+		return s.store.FailWebhookDelivery(delivery.ID, nil, "webhook is disabled")
+	}
+
+	status, err := s.post(ctx, webhook, delivery)
+	if err == nil {
+		return s.store.MarkWebhookDelivered(delivery.ID, *status)
+	}
+
+	if delivery.Attempts+1 >= s.MaxAttempts {
+		return errors.Join(err, s.store.FailWebhookDelivery(delivery.ID, status, err.Error()))
+	}
+
+	retryAt := time.Now().Add(s.retryDelay(delivery.Attempts))
+	return errors.Join(err, s.store.RetryWebhookDelivery(delivery.ID, status, err.Error(), retryAt))
+}
+
+// Sends the delivery, returns the response status if there was a response
+func (s *Scheduler) post(ctx context.Context, webhook utils.Webhook, delivery utils.WebhookDelivery) (*int, error) {
+	req, err := webhooks.NewRequest(ctx, webhook, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Reading the body lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	status := res.StatusCode
+	if status < 200 || status > 299 {
+		return &status, fmt.Errorf("webhook responded with %s", res.Status)
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+	"github.com/sunikka/tasklist-backendGo/internal/webhooks"
+)
+
+func createTestWebhook(t *testing.T, store db.Storage, userID uuid.UUID, url string) utils.Webhook {
+	t.Helper()
+
+	webhook := utils.Webhook{UserID: userID, URL: url, Events: []string{"task.created"}, Secret: "whsec-0123456789abcdef", Active: true}
+	if err := store.CreateWebhook(&webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	return webhook
+}
+
+func onlyDelivery(t *testing.T, store db.Storage, webhook utils.Webhook) utils.WebhookDelivery {
+	t.Helper()
+
+	deliveries, err := store.GetWebhookDeliveries(webhook.UserID, webhook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("webhook has %d deliveries, want 1", len(deliveries))
+	}
+
+	return deliveries[0]
+}
+
+func TestDeliverWebhooksRetries(t *testing.T) {
+	var flakyCalls, brokenCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Tasklist-Timestamp"), 10, 64)
+		if r.Header.Get("X-Tasklist-Signature") != webhooks.Sign("whsec-0123456789abcdef", timestamp, body) {
+			t.Errorf("%s: signature does not match the body", r.URL.Path)
+		}
+
+		// The flaky webhook fails once, the broken one every time
+		switch r.URL.Path {
+		case "/flaky":
+			if flakyCalls.Add(1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		case "/broken":
+			brokenCalls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	store := db.NewMemoryStore()
+	if err := store.CreateUser(&utils.User{Name: "example", Email: "example@tasklist.com", HashedPw: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.GetUserByEmail("example@tasklist.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flaky := createTestWebhook(t, store, user.ID, server.URL+"/flaky")
+	broken := createTestWebhook(t, store, user.ID, server.URL+"/broken")
+	if err := store.EnqueueWebhookEvent(user.ID, "task.created", []byte(`{"event":"task.created"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test server listens on the loopback interface the default client refuses.
+	// Retries are due right away.
+	s := New(store, nil)
+	s.client = server.Client()
+	s.MaxAttempts = 2
+	s.RetryDelay = -time.Second
+	s.MaxRetryDelay = 0
+
+	if n, err := s.DeliverWebhooks(context.Background()); n != 2 || err != nil {
+		t.Fatalf("first round: %d claimed, %v", n, err)
+	}
+
+	for _, webhook := range []utils.Webhook{flaky, broken} {
+		delivery := onlyDelivery(t, store, webhook)
+		if delivery.Status != utils.DeliveryPending || delivery.Attempts != 1 || delivery.NextAttemptAt == nil ||
+			delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusInternalServerError || delivery.LastError == "" {
+			t.Errorf("%s after the first attempt: %+v", webhook.URL, delivery)
+		}
+	}
+
+	if n, err := s.DeliverWebhooks(context.Background()); n != 2 || err != nil {
+		t.Fatalf("second round: %d claimed, %v", n, err)
+	}
+
+	delivered := onlyDelivery(t, store, flaky)
+	if delivered.Status != utils.DeliverySucceeded || delivered.Attempts != 2 || delivered.DeliveredAt == nil ||
+		delivered.NextAttemptAt != nil || *delivered.ResponseStatus != http.StatusOK || delivered.LastError != "" {
+		t.Errorf("retried delivery %+v", delivered)
+	}
+
+	failed := onlyDelivery(t, store, broken)
+	if failed.Status != utils.DeliveryFailed || failed.Attempts != 2 || failed.DeliveredAt != nil ||
+		failed.NextAttemptAt != nil || *failed.ResponseStatus != http.StatusInternalServerError {
+		t.Errorf("delivery out of attempts %+v", failed)
+	}
+
+	// Neither is sent again
+	if n, err := s.DeliverWebhooks(context.Background()); n != 0 || err != nil {
+		t.Errorf("third round: %d claimed, %v", n, err)
+	}
+	if flakyCalls.Load() != 2 || brokenCalls.Load() != 2 {
+		t.Errorf("webhooks called %d and %d times, want 2 each", flakyCalls.Load(), brokenCalls.Load())
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	s := &Scheduler{RetryDelay: time.Minute, MaxRetryDelay: 10 * time.Minute}
+
+	for attempts, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute} {
+		if got := s.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
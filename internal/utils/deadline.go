@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"time"
+)
+
+// Time of day of deadlines that are given as a date only
+const (
+	allDayHour   = 23
+	allDayMinute = 59
+)
+
+// Layouts of deadlines without a UTC offset, read in the time zone of the user
+var localDeadlineLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// Parses a deadline in one of the accepted formats:
+//
+//	2024-01-21                 all day, due at 23:59 in loc
+//	2024-01-21T14:30           in loc, seconds are optional
+//	2024-01-21T14:30:00+02:00  RFC 3339 with an offset or Z
+func ParseDeadline(s string, loc *time.Location) (deadline time.Time, allDay bool, err error) {
+	if date, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		year, month, day := date.Date()
+		return time.Date(year, month, day, allDayHour, allDayMinute, 0, 0, loc), true, nil
+	}
+
+	if deadline, ok := parseTime(s, loc); ok {
+		return deadline, false, nil
+	}
+
+	return time.Time{}, false, InvalidField("deadline", "must be a date (YYYY-MM-DD), a local time (YYYY-MM-DDTHH:MM) or an RFC 3339 timestamp")
+}
+
+// Parses a local time in loc or an RFC 3339 timestamp, the forms of ParseDeadline without a date only
+func parseTime(s string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range localDeadlineLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+// The time zone of the user, UTC if none is set
+func (u *User) Location() *time.Location {
+	if u.TimeZone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// Converts the timestamps of the task to loc, e.g. the time zone of the user it is shown to.
+// Recurrences are also computed in the zone of the deadline.
+func (t *Task) InLocation(loc *time.Location) {
+	t.Deadline = t.Deadline.In(loc)
+	t.CreatedAt = t.CreatedAt.In(loc)
+	t.UpdatedAt = t.UpdatedAt.In(loc)
+
+	if t.CompletedAt != nil {
+		completedAt := t.CompletedAt.In(loc)
+		t.CompletedAt = &completedAt
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Machine-readable error codes sent in the "code" field of error responses
+type ErrorCode string
+
+const (
+	CodeBadRequest       ErrorCode = "bad_request"
+	CodeNotFound         ErrorCode = "not_found"
+	CodeConflict         ErrorCode = "conflict"
+	CodeValidationFailed ErrorCode = "validation_failed"
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	CodeUnsupportedMedia ErrorCode = "unsupported_media_type"
+	// If-Match was missing or did not match the current version
+	CodePreconditionFailed   ErrorCode = "precondition_failed"
+	CodePreconditionRequired ErrorCode = "precondition_required"
+	CodeInternal             ErrorCode = "internal"
+)
+
+// An error that knows how it is reported to the client. Handlers return these
+// and createHandler turns them into an APIError response with Status.
+type HTTPError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Details any
+	// Underlying cause, logged but never sent to the client
+	Err error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Describes why a single request field was rejected
+type FieldError struct {
+	Field string `json:"field"`
+	// Name of the failed validation rule, e.g. "required" or "max"
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+func BadRequest(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: message}
+}
+
+func NotFound(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+func Conflict(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusConflict, Code: CodeConflict, Message: message}
+}
+
+func ValidationFailed(fields ...FieldError) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    CodeValidationFailed,
+		Message: "validation failed",
+		Details: fields,
+	}
+}
+
+// Validation error for a single field
+func InvalidField(field, message string) *HTTPError {
+	return ValidationFailed(FieldError{Field: field, Message: message})
+}
+
+func Unauthorized(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: message}
+}
+
+func Forbidden(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusForbidden, Code: CodeForbidden, Message: message}
+}
+
+func MethodNotAllowed(method string) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    CodeMethodNotAllowed,
+		Message: fmt.Sprintf("method not allowed: %s", method),
+	}
+}
+
+func UnsupportedMediaType(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusUnsupportedMediaType, Code: CodeUnsupportedMedia, Message: message}
+}
+
+func PreconditionFailed(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusPreconditionFailed, Code: CodePreconditionFailed, Message: message}
+}
+
+func PreconditionRequired(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusPreconditionRequired, Code: CodePreconditionRequired, Message: message}
+}
+
+// Wraps an unexpected error, the client only sees a generic message
+func Internal(err error) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusInternalServerError,
+		Code:    CodeInternal,
+		Message: "internal server error",
+		Err:     err,
+	}
+}
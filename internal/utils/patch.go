@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The fields of a task that a PATCH can change. The patch is applied to this
+// document built from the stored task, so a member the patch removes or sets
+// to null is cleared rather than left unchanged.
+type TaskPatch struct {
+	Title       string `json:"title" validate:"required,min=5,max=30"`
+	Description string `json:"description" validate:"max=100"`
+	// A date for all day tasks, otherwise an RFC 3339 timestamp in the users time zone
+	Deadline   string     `json:"deadline" validate:"required"`
+	Status     string     `json:"status" validate:"required,oneof=todo in_progress done cancelled"`
+	ProjectID  *uuid.UUID `json:"project_id"`
+	Recurrence string     `json:"recurrence"`
+	Tags       []string   `json:"tags" validate:"max=20,dive,min=1,max=30"`
+}
+
+// The fields of a user that a PATCH can change. The password is never shown,
+// a patch that adds one sets a new password.
+type UserPatch struct {
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	TimeZone string `json:"timezone" validate:"omitempty,timezone"`
+	Password string `json:"password,omitempty" validate:"omitempty,min=8,max=72"`
+}
+
+// The document a patch to the task is applied to, the deadline is shown in loc
+func (t *Task) PatchDocument(loc *time.Location) TaskPatch {
+	deadline := t.Deadline.In(loc).Format(time.RFC3339)
+	if t.AllDay {
+		deadline = t.Deadline.In(loc).Format(time.DateOnly)
+	}
+
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return TaskPatch{
+		Title:       t.Title,
+		Description: t.Description,
+		Deadline:    deadline,
+		Status:      string(t.Status),
+		ProjectID:   t.ProjectID,
+		Recurrence:  t.Recurrence,
+		Tags:        tags,
+	}
+}
+
+// Replaces the fields of the task with the patched document, a deadline is read in loc
+func (t *Task) ApplyPatch(p *TaskPatch, loc *time.Location) error {
+	deadline, allDay, err := ParseDeadline(p.Deadline, loc)
+	if err != nil {
+		return err
+	}
+
+	status, err := ParseTaskStatus(p.Status)
+	if err != nil {
+		return InvalidField("status", err.Error())
+	}
+
+	if p.Recurrence != t.Recurrence {
+		if err := t.SetRecurrence(p.Recurrence); err != nil {
+			return err
+		}
+	}
+
+	t.Title = p.Title
+	t.Description = p.Description
+	t.Deadline = deadline
+	t.AllDay = allDay
+	t.SetStatus(status)
+	t.ProjectID = p.ProjectID
+	t.Tags = NormalizeTags(p.Tags)
+
+	return nil
+}
+
+func (u *User) PatchDocument() UserPatch {
+	return UserPatch{
+		Username: u.Name,
+		Email:    u.Email,
+		TimeZone: u.TimeZone,
+	}
+}
+
+// Replaces the fields of the user with the patched document, a removed time zone falls back to UTC
+func (u *User) ApplyPatch(p *UserPatch) error {
+	u.Name = p.Username
+	u.Email = p.Email
+	u.TimeZone = p.TimeZone
+
+	if p.Password != "" {
+		return u.SetPassword(p.Password)
+	}
+
+	return nil
+}
+
+func (u *User) SetPassword(password string) error {
+	hashPw, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	u.HashedPw = string(hashPw)
+	return nil
+}
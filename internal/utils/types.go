@@ -1,39 +1,481 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/rrule"
 	"golang.org/x/crypto/bcrypt"
 )
 
+type TaskStatus string
+
+// Task lifecycle states
+const (
+	StatusTodo       TaskStatus = "todo"
+	StatusInProgress TaskStatus = "in_progress"
+	StatusDone       TaskStatus = "done"
+	StatusCancelled  TaskStatus = "cancelled"
+)
+
+func ParseTaskStatus(s string) (TaskStatus, error) {
+	status := TaskStatus(strings.ToLower(strings.TrimSpace(s)))
+	switch status {
+	case StatusTodo, StatusInProgress, StatusDone, StatusCancelled:
+		return status, nil
+	}
+
+	return "", fmt.Errorf("invalid task status: %s", s)
+}
+
 type Task struct {
 	ID          uuid.UUID `json:"task_id"`
-	Title       string    `json:"title" validate:"min=5, max=30"`
+	Title       string    `json:"title" validate:"required,min=5,max=30"`
 	Description string    `json:"description" validate:"max=100"`
 	Deadline    time.Time `json:"deadline"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	UserID      uuid.UUID `json:"user_id"`
+	// Set when the deadline was given as a date, it is then due at 23:59 in the users time zone
+	AllDay      bool       `json:"all_day"`
+	Status      TaskStatus `json:"status" validate:"oneof=todo in_progress done cancelled"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UserID      uuid.UUID  `json:"user_id"`
+	// Tasks without a project are listed only in the users task listing
+	ProjectID *uuid.UUID `json:"project_id"`
+	// Tasks in a workspace are listed only in the workspace, the user is the member who created it
+	WorkspaceID *uuid.UUID `json:"workspace_id"`
+	// Names of the tags attached to the task, sorted
+	Tags []string `json:"tags" validate:"max=20,dive,min=1,max=30"`
+	// Steps of the task in order, read only, changed through the checklist endpoints
+	Checklist []ChecklistItem `json:"checklist"`
+	// Percentage of checklist items done, null when the task has no checklist
+	Progress *int `json:"progress"`
+	// RRULE of a recurring task, completing it creates the next occurrence
+	Recurrence string `json:"recurrence"`
+	// Shared by every occurrence of a recurring task, numbered from 1
+	SeriesID   *uuid.UUID `json:"series_id"`
+	Occurrence int        `json:"occurrence"`
+	// Sync version of the last change to the task, grows with every change the user makes
+	Version int64 `json:"version"`
 }
 
-// Contains task fields without the ID
-type TaskBodyRequest struct {
-	Title       string    `json:"title" validate:"min=5, max=30"`
+// An upcoming occurrence of a recurring task
+type Occurrence struct {
+	Occurrence int       `json:"occurrence"`
+	Deadline   time.Time `json:"deadline"`
+}
+
+// A step of a task that can be ticked off
+type ChecklistItem struct {
+	ID        uuid.UUID `json:"item_id"`
+	TaskID    uuid.UUID `json:"task_id"`
+	Title     string    `json:"title" validate:"required,min=1,max=100"`
+	Done      bool      `json:"done"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Empty fields are left unchanged on update
+type ChecklistItemBodyRequest struct {
+	Title string `json:"title" validate:"max=100"`
+	Done  *bool  `json:"done"`
+}
+
+// New order of the checklist, must list every item of the task exactly once
+type ReorderChecklistRequest struct {
+	ItemIDs []uuid.UUID `json:"item_ids" validate:"required"`
+}
+
+// A notification about a task, sent at RemindAt by the reminder scheduler
+type Reminder struct {
+	ID       uuid.UUID `json:"reminder_id"`
+	TaskID   uuid.UUID `json:"task_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	RemindAt time.Time `json:"remind_at"`
+	// Minutes before the deadline, the reminder moves with the deadline. Null for a fixed time.
+	OffsetMinutes *int `json:"offset_minutes"`
+	// Failed deliveries are retried until the attempts run out
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error"`
+	SentAt    *time.Time `json:"sent_at"`
+	FailedAt  *time.Time `json:"failed_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Either remind_at or offset_minutes is required
+type ReminderBodyRequest struct {
+	// Local time in the users time zone or an RFC 3339 timestamp
+	RemindAt      string `json:"remind_at"`
+	OffsetMinutes *int   `json:"offset_minutes" validate:"omitempty,min=0,max=43200"`
+}
+
+// Event types webhooks can subscribe to
+const (
+	EventTaskCreated = "task.created"
+	EventTaskUpdated = "task.updated"
+	EventTaskDeleted = "task.deleted"
+	EventUserUpdated = "user.updated"
+)
+
+// A URL of a user that is notified of events on their tasks and account
+type Webhook struct {
+	ID     uuid.UUID `json:"webhook_id"`
+	UserID uuid.UUID `json:"user_id"`
+	URL    string    `json:"url" validate:"required,http_url,max=2048"`
+	Events []string  `json:"events" validate:"required,min=1,dive,oneof=task.created task.updated task.deleted user.updated"`
+	// Key of the HMAC-SHA256 request signatures, only shown when the webhook is created
+	Secret    string    `json:"secret,omitempty" validate:"required,min=16,max=100"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Empty fields are left unchanged on update. A secret is generated
+// for a new webhook when none is given.
+type WebhookBodyRequest struct {
+	URL    string   `json:"url" validate:"omitempty,http_url,max=2048"`
+	Events []string `json:"events" validate:"omitempty,dive,oneof=task.created task.updated task.deleted user.updated"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=100"`
+	Active *bool    `json:"active"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// An event sent to a webhook, failed attempts are retried with a growing delay
+type WebhookDelivery struct {
+	ID        uuid.UUID       `json:"delivery_id"`
+	WebhookID uuid.UUID       `json:"webhook_id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    DeliveryStatus  `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// HTTP status of the last attempt, null if it got no response
+	ResponseStatus *int   `json:"response_status"`
+	LastError      string `json:"last_error"`
+	// Null once the delivery has succeeded or failed for good
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// A label of a user that can be attached to any number of their tasks
+type Tag struct {
+	ID        uuid.UUID `json:"tag_id"`
+	Name      string    `json:"name" validate:"required,min=1,max=30"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TagBodyRequest struct {
+	Name string `json:"name"`
+}
+
+// A list of tasks of a user, e.g. "Work" or "Groceries"
+type Project struct {
+	ID          uuid.UUID `json:"project_id"`
+	Name        string    `json:"name" validate:"required,min=1,max=50"`
 	Description string    `json:"description" validate:"max=100"`
-	Deadline    string    `json:"deadline"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
 	UserID      uuid.UUID `json:"user_id"`
+	// Set for the projects of a workspace, UserID is the member who created it
+	WorkspaceID *uuid.UUID `json:"workspace_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Empty fields are left unchanged on update
+type ProjectBodyRequest struct {
+	Name        string `json:"name" validate:"omitempty,max=50"`
+	Description string `json:"description" validate:"max=100"`
+}
+
+// Moves a task to another project, a null project_id removes it from its project
+type MoveTaskRequest struct {
+	ProjectID *uuid.UUID `json:"project_id"`
+}
+
+// Role of a user on a task or project. Viewers can read it, editors can
+// also change it. Only the owner can delete, move or share it.
+type ShareRole string
+
+const (
+	RoleViewer ShareRole = "viewer"
+	RoleEditor ShareRole = "editor"
+	// Never given through a share, the user the task belongs to
+	RoleOwner ShareRole = "owner"
+)
+
+type ShareStatus string
+
+const (
+	SharePending  ShareStatus = "pending"
+	ShareAccepted ShareStatus = "accepted"
+	ShareDeclined ShareStatus = "declined"
+)
+
+// A task or project shared with another user. Either TaskID or ProjectID is
+// set, sharing a project shares every task in it. The invitation is sent to
+// an email, UserID is set when it is accepted.
+type Share struct {
+	ID        uuid.UUID   `json:"share_id"`
+	OwnerID   uuid.UUID   `json:"owner_id"`
+	TaskID    *uuid.UUID  `json:"task_id"`
+	ProjectID *uuid.UUID  `json:"project_id"`
+	Email     string      `json:"email"`
+	UserID    *uuid.UUID  `json:"user_id"`
+	Role      ShareRole   `json:"role"`
+	Status    ShareStatus `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type ShareRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"required,oneof=viewer editor"`
+}
+
+type UpdateShareRequest struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor"`
+}
+
+// A task shared with the user, with the role they have on it
+type SharedTask struct {
+	Task
+	Role ShareRole `json:"role"`
+}
+
+// Role of a member in a workspace. Members can create and change the tasks
+// and projects of the workspace, admins can also delete them and invite and
+// remove members. Owners manage the roles and can delete the workspace.
+type WorkspaceRole string
+
+const (
+	WorkspaceMember WorkspaceRole = "member"
+	WorkspaceAdmin  WorkspaceRole = "admin"
+	WorkspaceOwner  WorkspaceRole = "owner"
+)
+
+// A group of users sharing their tasks and projects, nothing in it is
+// visible outside of it
+type Workspace struct {
+	ID   uuid.UUID `json:"workspace_id"`
+	Name string    `json:"name" validate:"required,min=1,max=50"`
+	// Role of the user the workspace was loaded for
+	Role      WorkspaceRole `json:"role"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type WorkspaceBodyRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type WorkspaceMembership struct {
+	WorkspaceID uuid.UUID     `json:"workspace_id"`
+	UserID      uuid.UUID     `json:"user_id"`
+	Username    string        `json:"username"`
+	Email       string        `json:"email"`
+	Role        WorkspaceRole `json:"role"`
+	JoinedAt    time.Time     `json:"joined_at"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// An invitation to join a workspace, sent to an email. It is removed when
+// the user with that email accepts or declines it.
+type WorkspaceInvitation struct {
+	ID            uuid.UUID     `json:"invitation_id"`
+	WorkspaceID   uuid.UUID     `json:"workspace_id"`
+	WorkspaceName string        `json:"workspace_name"`
+	Email         string        `json:"email"`
+	Role          WorkspaceRole `json:"role"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type WorkspaceInvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"omitempty,oneof=admin member"`
+}
+
+// Field a task listing is ordered by
+type TaskSort string
+
+const (
+	SortCreatedAt TaskSort = "created_at"
+	SortUpdatedAt TaskSort = "updated_at"
+	SortDeadline  TaskSort = "deadline"
+	SortTitle     TaskSort = "title"
+)
+
+const (
+	DefaultTaskLimit = 50
+	MaxTaskLimit     = 200
+)
+
+// Filters, ordering and pagination for listing a users tasks,
+// the zero value returns the first page of every task by creation time
+type TaskFilter struct {
+	Statuses []TaskStatus
+	// Only tasks in the given project
+	ProjectID *uuid.UUID
+	// Only tasks with any of the tags, or all of them with TagMatchAll
+	Tags           []string
+	TagMatchAll    bool
+	DeadlineBefore *time.Time
+	DeadlineAfter  *time.Time
+	// Case-insensitive text match on the title or description
+	Search string
+
+	Sort       TaskSort
+	Descending bool
+	Limit      int
+	// Opaque position of the page, the NextCursor of the previous page
+	Cursor string
+}
+
+// One page of a task listing, NextCursor is empty on the last page
+type TaskPage struct {
+	Tasks      []Task `json:"tasks"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const (
+	DefaultSyncLimit = 200
+	MaxSyncLimit     = 1000
+	// Client changes accepted in one sync request
+	MaxSyncBatch = 100
+)
+
+// A deleted task, kept so that the deletion reaches the other devices of the user
+type TaskTombstone struct {
+	TaskID    uuid.UUID `json:"task_id"`
+	Version   int64     `json:"version"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Task changes since a sync token, ordered by version. The client stores
+// Token and sends it as since on the next sync, HasMore tells to fetch the
+// rest right away.
+type SyncChanges struct {
+	Tasks   []Task          `json:"tasks"`
+	Deleted []TaskTombstone `json:"deleted"`
+	Token   string          `json:"token"`
+	HasMore bool            `json:"has_more"`
+}
+
+type SyncOp string
+
+const (
+	SyncCreate SyncOp = "create"
+	SyncUpdate SyncOp = "update"
+	SyncDelete SyncOp = "delete"
+)
+
+// A change a client made offline. Updates and deletes are rejected as
+// conflicts when the task has changed since the version or update time the
+// client based it on, without either one the change is applied regardless.
+type SyncChange struct {
+	Op SyncOp `json:"op" validate:"oneof=create update delete"`
+	// Echoed in the result so that the client can map a created task to its server ID
+	ClientID      string           `json:"client_id" validate:"max=100"`
+	TaskID        *uuid.UUID       `json:"task_id" validate:"required_unless=Op create"`
+	BaseVersion   *int64           `json:"base_version"`
+	BaseUpdatedAt *time.Time       `json:"base_updated_at"`
+	Task          *TaskBodyRequest `json:"task" validate:"required_unless=Op delete"`
+}
+
+type SyncRequest struct {
+	// Applied in order, every change is validated on its own
+	Changes []SyncChange `json:"changes" validate:"required,max=100"`
+}
+
+type SyncStatus string
+
+const (
+	SyncApplied  SyncStatus = "applied"
+	SyncConflict SyncStatus = "conflict"
+	SyncRejected SyncStatus = "rejected"
+)
+
+// Outcome of a SyncChange. Task is the saved task when the change was
+// applied and the current server task on a conflict.
+type SyncResult struct {
+	Op       SyncOp     `json:"op"`
+	ClientID string     `json:"client_id,omitempty"`
+	TaskID   *uuid.UUID `json:"task_id"`
+	Status   SyncStatus `json:"status"`
+	Task     *Task      `json:"task,omitempty"`
+	Error    *APIError  `json:"error,omitempty"`
+}
+
+func ParseTaskSort(s string) (TaskSort, error) {
+	sort := TaskSort(s)
+	switch sort {
+	case SortCreatedAt, SortUpdatedAt, SortDeadline, SortTitle:
+		return sort, nil
+	}
+
+	return "", fmt.Errorf("invalid sort field: %s", s)
+}
+
+// Contains task fields without the ID, empty fields are left unchanged on update
+type TaskBodyRequest struct {
+	Title       string `json:"title" validate:"omitempty,min=5,max=30"`
+	Description string `json:"description" validate:"max=100"`
+	Deadline    string `json:"deadline"`
+	Status      string `json:"status" validate:"omitempty,oneof=todo in_progress done cancelled"`
+	// Only read when creating a task, tasks are moved with MoveTaskRequest
+	ProjectID *uuid.UUID `json:"project_id"`
+	// RFC 5545 RRULE, e.g. "FREQ=WEEKLY;BYDAY=MO". An empty string stops the recurrence.
+	Recurrence *string `json:"recurrence"`
+	// Replaces the tags of the task, tags that do not exist yet are created.
+	// Left unchanged on update when omitted, an empty list removes every tag.
+	Tags      *[]string `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
 }
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"username"`
-	Email     string    `json:"email"`
-	HashedPw  string    `json:"-"`
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"username"`
+	Email    string    `json:"email"`
+	HashedPw string    `json:"-"`
+	// IANA time zone name, deadlines are read and shown in this zone
+	TimeZone  string    `json:"timezone"`
+	Role      UserRole  `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Grows with every update, the ETag of the user
+	Version int64 `json:"version"`
+}
+
+// Role of a user on the whole API. Admins can list and delete every user
+// and read every task, regular users only reach their own data.
+type UserRole string
+
+const (
+	UserRegular UserRole = "user"
+	UserAdmin   UserRole = "admin"
+)
+
+type UserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user admin"`
 }
 
 type LoginRequest struct {
@@ -41,26 +483,62 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// Empty fields are left unchanged
 type UserBodyRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"omitempty,min=3,max=50"`
+	Email    string `json:"email" validate:"omitempty,email,max=255"`
+	Password string `json:"password" validate:"omitempty,min=8,max=72"`
+	TimeZone string `json:"timezone" validate:"omitempty,timezone"`
 }
 
 type RegisterUserRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	// bcrypt only uses the first 72 bytes of a password
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	// Optional, UTC by default
+	TimeZone string `json:"timezone" validate:"omitempty,timezone"`
 }
 
 type LoginResponse struct {
-	Username string `json:"username"`
-	Token    string `json:"token"`
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// Lifetime of the access token in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
-func NewTask(title, description, deadline string, userID uuid.UUID) (*Task, error) {
-	// Time of day for the deadline currently hardcoded into 23:59 PM
-	dlParsed, err := time.Parse(time.RFC3339, deadline+"T23:59:00Z")
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// A login of a user, refreshing the access token keeps the session alive
+// until it expires or is revoked
+type Session struct {
+	ID         uuid.UUID  `json:"session_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Set on the session the request was made with
+	Current bool `json:"current"`
+}
+
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// Creates a task for the user, the deadline is read in the time zone of the user (see ParseDeadline)
+func NewTask(title, description, deadline string, user User) (*Task, error) {
+	dlParsed, allDay, err := ParseDeadline(deadline, user.Location())
 	if err != nil {
 		return nil, err
 	}
@@ -69,10 +547,31 @@ func NewTask(title, description, deadline string, userID uuid.UUID) (*Task, erro
 		Title:       title,
 		Description: description,
 		Deadline:    dlParsed,
-		UserID:      userID,
+		AllDay:      allDay,
+		Status:      StatusTodo,
+		UserID:      user.ID,
 	}, nil
 }
-func (t *Task) ModifyTask(req *TaskBodyRequest) error {
+
+// Moves the task to the given state, completed_at is set when the task
+// becomes done and cleared if it is reopened
+func (t *Task) SetStatus(status TaskStatus) {
+	if status == t.Status {
+		return
+	}
+
+	if status == StatusDone {
+		now := time.Now().UTC()
+		t.CompletedAt = &now
+	} else {
+		t.CompletedAt = nil
+	}
+
+	t.Status = status
+}
+
+// Applies the fields set in the request, a deadline is read in loc
+func (t *Task) ModifyTask(req *TaskBodyRequest, loc *time.Location) error {
 	if req.Title != "" {
 		t.Title = req.Title
 	}
@@ -82,16 +581,375 @@ func (t *Task) ModifyTask(req *TaskBodyRequest) error {
 	}
 
 	if req.Deadline != "" {
-		dlParsed, err := time.Parse(time.RFC3339, req.Deadline)
+		dlParsed, allDay, err := ParseDeadline(req.Deadline, loc)
 		if err != nil {
 			return err
 		}
 		t.Deadline = dlParsed
+		t.AllDay = allDay
+	}
+
+	if req.Status != "" {
+		status, err := ParseTaskStatus(req.Status)
+		if err != nil {
+			return InvalidField("status", err.Error())
+		}
+		t.SetStatus(status)
+	}
+
+	if req.Tags != nil {
+		t.Tags = NormalizeTags(*req.Tags)
+	}
+
+	if req.Recurrence != nil {
+		if err := t.SetRecurrence(*req.Recurrence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Makes the task recurring with the RRULE, an empty rule stops the recurrence.
+// The task becomes the first occurrence of a new series unless it is in one already.
+func (t *Task) SetRecurrence(rule string) error {
+	if strings.TrimSpace(rule) == "" {
+		t.Recurrence = ""
+		return nil
+	}
+
+	parsed, err := rrule.Parse(rule)
+	if err != nil {
+		return InvalidField("recurrence", err.Error())
+	}
+
+	t.Recurrence = parsed.String()
+	if t.SeriesID == nil {
+		seriesID := uuid.New()
+		t.SeriesID = &seriesID
+		t.Occurrence = 1
 	}
 
 	return nil
 }
 
+// Returns up to limit occurrences of a recurring task that follow this one
+func (t *Task) UpcomingOccurrences(limit int) []Occurrence {
+	occurrences := []Occurrence{}
+	if t.Recurrence == "" {
+		return occurrences
+	}
+
+	rule, err := rrule.Parse(t.Recurrence)
+	if err != nil {
+		return occurrences
+	}
+
+	deadline := t.Deadline
+	for n := t.Occurrence + 1; len(occurrences) < limit; n++ {
+		if rule.Count > 0 && n > rule.Count {
+			break
+		}
+
+		next, ok := rule.Next(deadline)
+		if !ok {
+			break
+		}
+
+		occurrences = append(occurrences, Occurrence{Occurrence: n, Deadline: next})
+		deadline = next
+	}
+
+	return occurrences
+}
+
+// Builds the occurrence that follows this one, false when the recurrence has ended.
+// Title, description, project and tags are carried over.
+func (t *Task) NextOccurrence() (*Task, bool) {
+	upcoming := t.UpcomingOccurrences(1)
+	if len(upcoming) == 0 {
+		return nil, false
+	}
+
+	return &Task{
+		Title:       t.Title,
+		Description: t.Description,
+		Deadline:    upcoming[0].Deadline,
+		AllDay:      t.AllDay,
+		Status:      StatusTodo,
+		UserID:      t.UserID,
+		ProjectID:   t.ProjectID,
+		WorkspaceID: t.WorkspaceID,
+		Tags:        slices.Clone(t.Tags),
+		Recurrence:  t.Recurrence,
+		SeriesID:    t.SeriesID,
+		Occurrence:  upcoming[0].Occurrence,
+	}, true
+}
+
+// Sets the checklist of the task and computes its progress
+func (t *Task) SetChecklist(items []ChecklistItem) {
+	t.Checklist = items
+	if t.Checklist == nil {
+		t.Checklist = []ChecklistItem{}
+	}
+
+	t.Progress = nil
+	if len(items) == 0 {
+		return
+	}
+
+	done := 0
+	for _, item := range items {
+		if item.Done {
+			done++
+		}
+	}
+
+	progress := done * 100 / len(items)
+	t.Progress = &progress
+}
+
+func (i *ChecklistItem) ModifyChecklistItem(req *ChecklistItemBodyRequest) {
+	if req.Title != "" {
+		i.Title = req.Title
+	}
+
+	if req.Done != nil {
+		i.Done = *req.Done
+	}
+}
+
+// Creates a reminder for the task, remind_at is read in loc. The reminder
+// must be due in the future.
+func NewReminder(req *ReminderBodyRequest, task Task, loc *time.Location) (*Reminder, error) {
+	reminder := &Reminder{TaskID: task.ID, UserID: task.UserID}
+
+	switch {
+	case req.RemindAt != "" && req.OffsetMinutes != nil:
+		return nil, InvalidField("remind_at", "cannot be used together with offset_minutes")
+
+	case req.RemindAt != "":
+		remindAt, ok := parseTime(req.RemindAt, loc)
+		if !ok {
+			return nil, InvalidField("remind_at", "must be a local time (YYYY-MM-DDTHH:MM) or an RFC 3339 timestamp")
+		}
+		if !remindAt.After(time.Now()) {
+			return nil, InvalidField("remind_at", "must be in the future")
+		}
+		reminder.RemindAt = remindAt
+
+	case req.OffsetMinutes != nil:
+		reminder.OffsetMinutes = req.OffsetMinutes
+		reminder.FollowDeadline(task.Deadline)
+		if !reminder.RemindAt.After(time.Now()) {
+			return nil, InvalidField("offset_minutes", "the reminder time has already passed")
+		}
+
+	default:
+		return nil, InvalidField("remind_at", "either remind_at or offset_minutes is required")
+	}
+
+	return reminder, nil
+}
+
+// Moves an offset reminder to its time before the deadline, reports whether it changed.
+// A reminder that was already sent or given up on is sent again if its new time is still ahead.
+func (r *Reminder) FollowDeadline(deadline time.Time) bool {
+	if r.OffsetMinutes == nil {
+		return false
+	}
+
+	remindAt := deadline.Add(-time.Duration(*r.OffsetMinutes) * time.Minute)
+	if remindAt.Equal(r.RemindAt) {
+		return false
+	}
+
+	r.RemindAt = remindAt
+	if (r.SentAt != nil || r.FailedAt != nil) && remindAt.After(time.Now()) {
+		r.SentAt = nil
+		r.FailedAt = nil
+		r.Attempts = 0
+		r.LastError = ""
+	}
+
+	return true
+}
+
+// Converts the timestamps of the reminder to loc like Task.InLocation
+func (r *Reminder) InLocation(loc *time.Location) {
+	r.RemindAt = r.RemindAt.In(loc)
+	r.CreatedAt = r.CreatedAt.In(loc)
+
+	if r.SentAt != nil {
+		sentAt := r.SentAt.In(loc)
+		r.SentAt = &sentAt
+	}
+	if r.FailedAt != nil {
+		failedAt := r.FailedAt.In(loc)
+		r.FailedAt = &failedAt
+	}
+}
+
+// Tag names are case-insensitive and stored in lower case
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Normalizes the tag names and drops duplicates, the result is sorted
+func NormalizeTags(names []string) []string {
+	tags := []string{}
+	for _, name := range names {
+		tags = append(tags, NormalizeTagName(name))
+	}
+
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+func NewProject(name, description string, userID uuid.UUID) *Project {
+	return &Project{
+		Name:        strings.TrimSpace(name),
+		Description: description,
+		UserID:      userID,
+	}
+}
+
+func (p *Project) ModifyProject(req *ProjectBodyRequest) {
+	if name := strings.TrimSpace(req.Name); name != "" {
+		p.Name = name
+	}
+
+	if req.Description != "" {
+		p.Description = req.Description
+	}
+}
+
+// Creates a pending invitation to the task or project of the owner
+func NewShare(req *ShareRequest, ownerID uuid.UUID, taskID, projectID *uuid.UUID) *Share {
+	return &Share{
+		OwnerID:   ownerID,
+		TaskID:    taskID,
+		ProjectID: projectID,
+		Email:     NormalizeEmail(req.Email),
+		Role:      ShareRole(req.Role),
+		Status:    SharePending,
+	}
+}
+
+// Invitations are matched to users by email regardless of case
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Reports whether the role is at least need
+func (r ShareRole) Allows(need ShareRole) bool {
+	return r.rank() >= need.rank()
+}
+
+func (r ShareRole) rank() int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleEditor:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Reports whether the role is at least need
+func (r WorkspaceRole) Allows(need WorkspaceRole) bool {
+	return r.rank() >= need.rank()
+}
+
+// Reports whether the role is above other, only then can a member remove the other
+func (r WorkspaceRole) Outranks(other WorkspaceRole) bool {
+	return r.rank() > other.rank()
+}
+
+func (r WorkspaceRole) rank() int {
+	switch r {
+	case WorkspaceOwner:
+		return 3
+	case WorkspaceAdmin:
+		return 2
+	case WorkspaceMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Role of the member on a task of the workspace. The member who created a
+// task and the admins own it, other members can edit it.
+func (r WorkspaceRole) TaskRole(creator bool) ShareRole {
+	if creator || r.Allows(WorkspaceAdmin) {
+		return RoleOwner
+	}
+	return RoleEditor
+}
+
+func NewWorkspace(name string) *Workspace {
+	return &Workspace{Name: strings.TrimSpace(name)}
+}
+
+// Creates an invitation to the workspace, members are invited unless the request asks for an admin
+func NewWorkspaceInvitation(req *WorkspaceInvitationRequest, workspaceID uuid.UUID) *WorkspaceInvitation {
+	role := WorkspaceRole(req.Role)
+	if role == "" {
+		role = WorkspaceMember
+	}
+
+	return &WorkspaceInvitation{
+		WorkspaceID: workspaceID,
+		Email:       NormalizeEmail(req.Email),
+		Role:        role,
+	}
+}
+
+// Creates an active webhook from the request, with a random secret if none is given
+func NewWebhook(req *WebhookBodyRequest, userID uuid.UUID) (*Webhook, error) {
+	webhook := &Webhook{UserID: userID, Active: true, Secret: req.Secret}
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	webhook.ModifyWebhook(req)
+	return webhook, nil
+}
+
+func (w *Webhook) ModifyWebhook(req *WebhookBodyRequest) {
+	if req.URL != "" {
+		w.URL = req.URL
+	}
+
+	if len(req.Events) > 0 {
+		events := slices.Clone(req.Events)
+		slices.Sort(events)
+		w.Events = slices.Compact(events)
+	}
+
+	if req.Secret != "" {
+		w.Secret = req.Secret
+	}
+
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+}
+
+// Reports whether the webhook is sent the event
+func (w *Webhook) Subscribed(event string) bool {
+	return w.Active && slices.Contains(w.Events, event)
+}
+
 func (u *User) ModifyUser(req *UserBodyRequest) error {
 	if req.Username != "" {
 		u.Name = req.Username
@@ -101,13 +959,12 @@ func (u *User) ModifyUser(req *UserBodyRequest) error {
 		u.Email = req.Email
 	}
 
-	if req.Password != "" {
-		hashPw, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
+	if req.TimeZone != "" {
+		u.TimeZone = req.TimeZone
+	}
 
-		u.HashedPw = string(hashPw)
+	if req.Password != "" {
+		return u.SetPassword(req.Password)
 	}
 
 	return nil
@@ -123,6 +980,8 @@ func NewUser(name, email, password string) (*User, error) {
 		Name:     name,
 		Email:    email,
 		HashedPw: string(hashPw),
+		TimeZone: "UTC",
+		Role:     UserRegular,
 	}, nil
 
 }
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func recurringTask(t *testing.T, rule string) Task {
+	t.Helper()
+
+	task := Task{
+		Title:    "Weekly review",
+		Deadline: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		UserID:   uuid.New(),
+		Tags:     []string{"work"},
+	}
+	if err := task.SetRecurrence(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	return task
+}
+
+func TestUpcomingOccurrences(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       string
+		occurrence int
+		limit      int
+		want       []int
+	}{
+		{"limit", "FREQ=DAILY", 1, 3, []int{2, 3, 4}},
+		{"count", "FREQ=DAILY;COUNT=3", 1, 5, []int{2, 3}},
+		{"count from a later occurrence", "FREQ=DAILY;COUNT=3", 2, 5, []int{3}},
+		{"count reached", "FREQ=DAILY;COUNT=3", 3, 5, []int{}},
+		{"until", "FREQ=DAILY;UNTIL=20240103", 1, 5, []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := recurringTask(t, tt.rule)
+			task.Occurrence = tt.occurrence
+
+			got := task.UpcomingOccurrences(tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d occurrences %+v, want %v", len(got), got, tt.want)
+			}
+
+			deadline := task.Deadline
+			for i, occurrence := range got {
+				if occurrence.Occurrence != tt.want[i] {
+					t.Errorf("occurrence %d numbered %d, want %d", i, occurrence.Occurrence, tt.want[i])
+				}
+				if !occurrence.Deadline.After(deadline) {
+					t.Errorf("occurrence %d deadline %s is not after %s", i, occurrence.Deadline, deadline)
+				}
+				deadline = occurrence.Deadline
+			}
+		})
+	}
+}
+
+func TestUpcomingOccurrencesWithoutRecurrence(t *testing.T) {
+	task := Task{Deadline: time.Now()}
+
+	if got := task.UpcomingOccurrences(5); len(got) != 0 {
+		t.Errorf("task without recurrence has occurrences %+v", got)
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	task := recurringTask(t, "FREQ=WEEKLY;COUNT=2")
+	task.Status = StatusDone
+
+	next, ok := task.NextOccurrence()
+	if !ok {
+		t.Fatal("no next occurrence")
+	}
+
+	if next.Title != task.Title || next.UserID != task.UserID || next.Recurrence != task.Recurrence {
+		t.Errorf("next occurrence %+v does not carry over %+v", next, task)
+	}
+	if next.SeriesID == nil || *next.SeriesID != *task.SeriesID || next.Occurrence != 2 {
+		t.Errorf("next occurrence is %v #%d, want %v #2", next.SeriesID, next.Occurrence, *task.SeriesID)
+	}
+	if next.Status != StatusTodo {
+		t.Errorf("next occurrence status %q", next.Status)
+	}
+	if want := task.Deadline.AddDate(0, 0, 7); !next.Deadline.Equal(want) {
+		t.Errorf("next occurrence deadline %s, want %s", next.Deadline, want)
+	}
+
+	// The tags are copied, not shared with the completed task
+	next.Tags[0] = "home"
+	if task.Tags[0] != "work" {
+		t.Error("next occurrence shares the tags of the task")
+	}
+
+	if _, ok := next.NextOccurrence(); ok {
+		t.Error("next occurrence after the last one of COUNT=2")
+	}
+}
@@ -2,15 +2,17 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
+// Body of every error response
 type APIError struct {
-	Error string `json:"error"`
+	Error   string    `json:"error"`
+	Code    ErrorCode `json:"code"`
+	Details any       `json:"details,omitempty"`
 }
 
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
@@ -19,15 +21,32 @@ func WriteJSON(w http.ResponseWriter, status int, v any) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
+func WriteError(w http.ResponseWriter, err *HTTPError) error {
+	return WriteJSON(w, err.Status, APIError{Error: err.Message, Code: err.Code, Details: err.Details})
+}
+
 func ResponsePermDenied(w http.ResponseWriter) {
-	WriteJSON(w, http.StatusForbidden, APIError{Error: "permission denied"})
+	WriteError(w, Forbidden("permission denied"))
+}
+
+func ResponseUnauthorized(w http.ResponseWriter) {
+	WriteError(w, Unauthorized("authentication required"))
+}
+
+// Decodes the JSON request body into v, malformed bodies are reported as bad requests
+func DecodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return BadRequest("invalid JSON body: " + err.Error())
+	}
+
+	return nil
 }
 
 func GetUserID(r *http.Request) (uuid.UUID, error) {
 	idStr := mux.Vars(r)["user_id"]
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return id, fmt.Errorf("invalid user ID: %s", idStr)
+		return id, BadRequest("invalid user ID: " + idStr)
 	}
 
 	return id, nil
@@ -37,7 +56,128 @@ func GetTaskID(r *http.Request) (uuid.UUID, error) {
 	idStr := mux.Vars(r)["task_id"]
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return id, fmt.Errorf("invalid task ID: %s", idStr)
+		return id, BadRequest("invalid task ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetProjectID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["project_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid project ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetTagID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["tag_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid tag ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetChecklistItemID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["item_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid checklist item ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetReminderID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["reminder_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid reminder ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetWebhookID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["webhook_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid webhook ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetDeliveryID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["delivery_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid delivery ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetSessionID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["session_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid session ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetShareID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["share_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid share ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetWorkspaceID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["workspace_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid workspace ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetMemberID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["member_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid member ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+func GetInvitationID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["invitation_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid invitation ID: " + idStr)
+	}
+
+	return id, nil
+}
+
+// The user an admin route acts on, {user_id} is reserved for the token's own user
+func GetAccountID(r *http.Request) (uuid.UUID, error) {
+	idStr := mux.Vars(r)["account_id"]
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return id, BadRequest("invalid user ID: " + idStr)
 	}
 
 	return id, nil
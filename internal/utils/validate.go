@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names, those are what the client sent
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	return v
+}
+
+// Checks v against its validate struct tags. Invalid input is returned as a
+// validation_failed HTTPError that lists every rejected field.
+func Validate(v any) error {
+	err := validate.Struct(v)
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldErr.Field(),
+				Rule:    fieldErr.Tag(),
+				Message: validationMessage(fieldErr),
+			})
+		}
+		return ValidationFailed(fields...)
+	}
+
+	return err
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	isString := fieldErr.Kind() == reflect.String
+
+	switch fieldErr.Tag() {
+	case "required", "required_unless":
+		return "is required"
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "email":
+		return "must be a valid email address"
+	case "timezone":
+		return "must be an IANA time zone, e.g. Europe/Helsinki"
+	case "http_url":
+		return "must be an http or https URL"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Webhook URLs are given by users, requests to them must not reach the
+// loopback interface, private networks or cloud metadata endpoints
+var ErrBlockedAddress = errors.New("webhook address is not public")
+
+// Ranges that are not reachable on the internet, besides the ones netip
+// reports as private, loopback, link-local, multicast or unspecified
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Reports whether webhooks may be sent to the address
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks the URL of a webhook when it is saved. Host names are resolved only
+// when a delivery is sent, NewClient blocks the ones that resolve to a
+// non-public address.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return utils.InvalidField("url", "must be an http or https URL")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return utils.InvalidField("url", "must be a public address")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !PublicAddr(addr) {
+		return utils.InvalidField("url", "must be a public address")
+	}
+
+	return nil
+}
+
+// Client for sending webhook requests. Connections are checked after DNS
+// resolution, so every address a host name resolves to has to be public.
+// Redirects are not followed and proxies from the environment are not used,
+// either would send the request to an address that has not been checked.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !PublicAddr(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"127.8.8.8", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"224.0.0.1", false},
+		{"198.51.100.7", false},
+	}
+
+	for _, tt := range tests {
+		if got := PublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://hooks.example.com/tasklist", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"http://localhost/hook", false},
+		{"http://LOCALHOST./hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[::ffff:169.254.169.254]/", false},
+		{"http://100.64.0.1/hook", false},
+		{"ftp://hooks.example.com/tasklist", false},
+		{"hooks.example.com/tasklist", false},
+		{"https:///tasklist", false},
+	}
+
+	for _, tt := range tests {
+		if err := CheckURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%s): error %v", tt.url, err)
+		}
+	}
+}
+
+// Host names that pass CheckURL can still resolve to the loopback interface
+func TestClientBlocksLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the loopback server")
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second).Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("error %v, want ErrBlockedAddress", err)
+	}
+}
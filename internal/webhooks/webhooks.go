@@ -0,0 +1,71 @@
+// Package webhooks builds the signed requests sent to the webhooks of users.
+// Every request is a POST with a JSON Payload body and the headers
+//
+//	X-Tasklist-Event      event type, e.g. task.created
+//	X-Tasklist-Delivery   ID of the delivery, the same on every retry
+//	X-Tasklist-Timestamp  Unix time the request was signed at
+//	X-Tasklist-Signature  sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret>
+//
+// Receivers should compare the signature in constant time and reject old timestamps.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+// Body of a webhook request
+type Payload struct {
+	ID        uuid.UUID `json:"event_id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	// The created or updated resource, only the ID of a deleted one
+	Data any `json:"data"`
+}
+
+func NewPayload(event string, data any) Payload {
+	return Payload{
+		ID:        uuid.New(),
+		Event:     event,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Data:      data,
+	}
+}
+
+// Signature of a request body sent at timestamp, in the form of the X-Tasklist-Signature header
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Builds the signed request of a delivery to the webhook
+func NewRequest(ctx context.Context, webhook utils.Webhook, delivery utils.WebhookDelivery) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tasklist-Webhooks")
+	req.Header.Set("X-Tasklist-Event", delivery.Event)
+	req.Header.Set("X-Tasklist-Delivery", delivery.ID.String())
+	req.Header.Set("X-Tasklist-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Tasklist-Signature", Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	return req, nil
+}
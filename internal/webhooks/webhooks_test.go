@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sunikka/tasklist-backendGo/internal/utils"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"task.created"}`)
+
+	// HMAC-SHA256 of `1700000000.{"event":"task.created"}` computed outside of Go
+	want := "sha256=eb7900d952319959e5007252344ebc14cbc2e9d1bb854cf53da25a1b557f273d"
+	if got := Sign("whsec-0123456789abcdef", 1700000000, body); got != want {
+		t.Errorf("signature %s, want %s", got, want)
+	}
+
+	if Sign("whsec-0123456789abcdef", 1700000001, body) == want {
+		t.Error("signature does not cover the timestamp")
+	}
+	if Sign("another-secret-0123", 1700000000, body) == want {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	webhook := utils.Webhook{URL: "https://hooks.example.com/tasklist", Secret: "whsec-0123456789abcdef"}
+	delivery := utils.WebhookDelivery{ID: uuid.New(), Event: "task.created", Payload: []byte(`{"event":"task.created"}`)}
+
+	req, err := NewRequest(context.Background(), webhook, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, err := strconv.ParseInt(req.Header.Get("X-Tasklist-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "POST" || req.URL.String() != webhook.URL {
+		t.Errorf("request %s %s", req.Method, req.URL)
+	}
+	if got := req.Header.Get("X-Tasklist-Signature"); got != Sign(webhook.Secret, timestamp, delivery.Payload) {
+		t.Errorf("signature %s does not match the timestamp %d", got, timestamp)
+	}
+	if req.Header.Get("X-Tasklist-Event") != "task.created" || req.Header.Get("X-Tasklist-Delivery") != delivery.ID.String() {
+		t.Errorf("headers %v", req.Header)
+	}
+}
@@ -6,28 +6,75 @@ package main
 // https://www.youtube.com/watch?v=pwZuNmAzaH8&list=PL0xRBLFXXsP6nudFDqMXzrvQCZrxSOm-2
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
+	// Time zone database for user time zones on hosts without one
+	_ "time/tzdata"
 
+	"github.com/joho/godotenv"
 	"github.com/sunikka/tasklist-backendGo/internal/db"
+	"github.com/sunikka/tasklist-backendGo/internal/notify"
+	"github.com/sunikka/tasklist-backendGo/internal/policy"
 	"github.com/sunikka/tasklist-backendGo/internal/routes"
+	"github.com/sunikka/tasklist-backendGo/internal/scheduler"
 )
 
 func main() {
-	store, err := db.NewStore()
+	// The .env file is optional, the variables can also come from the environment
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file loaded:", err)
+	}
+
+	store, err := db.Open()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(store, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		case "admin":
+			if err := runAdmin(store, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("unknown command: %s\n%s\n%s", os.Args[1], migrateUsage, adminUsage)
+		}
+		return
+	}
+
+	// Applies pending schema migrations
 	err = store.InitDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	notifier, err := notify.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Sends due reminders in the background while the server runs
+	reminders := scheduler.New(store, notifier)
+	if interval := os.Getenv("REMINDER_INTERVAL"); interval != "" {
+		if reminders.Interval, err = time.ParseDuration(interval); err != nil || reminders.Interval <= 0 {
+			log.Fatalf("invalid REMINDER_INTERVAL: %s", interval)
+		}
+	}
+	go reminders.Run(context.Background())
+
 	port := string(os.Getenv("SERVERPORT"))
 
-	store.Connect()
 	server := routes.NewAPIServer(port, store)
+	server.RequireIfMatch = os.Getenv("REQUIRE_IF_MATCH") == "true"
+	if server.Policy, err = policy.FromEnv(); err != nil {
+		log.Fatal(err)
+	}
 	server.Run()
 
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sunikka/tasklist-backendGo/internal/db"
+)
+
+const migrateUsage = "usage: tasklist_backendGo migrate [up | down [steps] | status]"
+
+// Handles the migrate subcommand, the server itself also migrates up on startup
+func runMigrate(store db.Storage, args []string) error {
+	migrator, ok := store.(db.Migrator)
+	if !ok {
+		return errors.New("the selected storage does not use migrations")
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migrator.MigrateUp()
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		return migrator.MigrateDown(steps)
+
+	case "status":
+		statuses, err := migrator.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d %-40s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}